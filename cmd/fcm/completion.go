@@ -0,0 +1,366 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const completionUsage = `usage: fcm completion bash|zsh|fish
+
+Print the shell completion script of fcm, which completes the commands, the
+flags, the template categories and the history. It also completes fcm run as
+"git fcm".
+
+  bash  add to ~/.bashrc:                 eval "$(fcm completion bash)"
+  zsh   add to ~/.zshrc, after compinit:  eval "$(fcm completion zsh)"
+  fish  run once:                         fcm completion fish > ~/.config/fish/completions/fcm.fish
+`
+
+// completionNode is a command as shell completion sees it.
+type completionNode struct {
+	name        string
+	description string
+	children    []completionNode
+	// flagSet returns the flags of the command. Those that are not boolean
+	// take a value.
+	flagSet func() *flag.FlagSet
+	// values complete the values of flags, by name. The values of the other
+	// flags are left to the shell, which completes file names.
+	values map[string]func() []string
+	// args completes the arguments after the children. nil leaves them to
+	// the shell as well.
+	args func() []string
+}
+
+// completionTree returns the commands of fcm with their flags and arguments.
+func completionTree() completionNode {
+	root := completionNode{
+		flagSet: func() *flag.FlagSet {
+			return flag.CommandLine
+		},
+	}
+	for _, cmd := range commands {
+		if cmd.hidden {
+			continue
+		}
+		node := commandCompletions[cmd.name]
+		node.name = cmd.name
+		node.description = cmd.summary
+		root.children = append(root.children, node)
+	}
+	return root
+}
+
+var commandCompletions = map[string]completionNode{
+	"history": {children: []completionNode{
+		{name: "list", description: "list all entries", flagSet: historyListFlagSet},
+		{name: "search", description: "list the entries containing every word", flagSet: historySearchFlagSet},
+		{name: "rm", description: "remove entries", flagSet: historyRemoveFlagSet, args: historyMessages},
+		{name: "edit", description: "edit an entry", flagSet: historyEditFlagSet, args: historyMessages},
+		{
+			name:        "promote",
+			description: "copy an entry into ~/.fcm",
+			flagSet:     (&historyPromoteFlags{}).flagSet,
+			values:      map[string]func() []string{"category": templateCategories},
+			args:        historyMessages,
+		},
+		{name: "prune", description: "apply the retention settings", flagSet: (&historyPruneFlags{}).flagSet},
+	}},
+	"templates": {children: []completionNode{
+		{name: "list", description: "list the candidates"},
+		{name: "categories", description: "list the category headers"},
+	}},
+	"config": {children: []completionNode{
+		{name: "list", description: "print the settings in effect"},
+		{name: "path", description: "print the path of the config file"},
+	}},
+	"hook": {children: []completionNode{
+		{name: "install", description: "install hooks", flagSet: (&hookInstallFlags{}).flagSet, args: hookNames},
+		{name: "uninstall", description: "remove the hooks of fcm", args: hookNames},
+		{name: "status", description: "show which hooks are installed"},
+	}},
+	"import": {
+		flagSet: (&importFlags{}).flagSet,
+		values:  map[string]func() []string{"strategy": mergeStrategies},
+	},
+	"export": {flagSet: (&exportFlags{}).flagSet},
+	"init": {
+		flagSet: (&initFlags{}).flagSet,
+		values:  map[string]func() []string{"preset": presetNames, "hook": hookNames},
+	},
+	"doctor":     {flagSet: (&doctorFlags{}).flagSet},
+	"help":       {args: commandNames},
+	"completion": {args: shells},
+}
+
+// flags returns the flags of the command as the usages write them: with two
+// dashes before the command and one after it, but for single letters.
+func (n completionNode) flags() []string {
+	if n.flagSet == nil {
+		return nil
+	}
+	dashes := "-"
+	if len(n.name) == 0 {
+		dashes = "--"
+	}
+	var flags []string
+	n.flagSet().VisitAll(func(f *flag.Flag) {
+		if len(f.Name) == 1 {
+			flags = append(flags, "-"+f.Name)
+		} else {
+			flags = append(flags, dashes+f.Name)
+		}
+	})
+	return flags
+}
+
+// complete returns the candidates for the last of words, the arguments of
+// fcm up to the word being completed, as "candidate\tdescription" lines.
+// Nothing is returned where file names are expected.
+func (n completionNode) complete(words []string) []string {
+	node := n
+	inArgs := false
+	for i := 0; i < len(words)-1; i++ {
+		word := words[i]
+		if value, ok := node.flagValue(word); ok {
+			if i == len(words)-2 {
+				return filterCompletions(value, words[len(words)-1])
+			}
+			i++
+			continue
+		}
+		if strings.HasPrefix(word, "-") {
+			continue
+		}
+		if child, ok := node.child(word); ok && !inArgs {
+			node = child
+			continue
+		}
+		inArgs = true
+	}
+
+	current := words[len(words)-1]
+	if strings.HasPrefix(current, "-") {
+		return filterCompletions(node.flags, current)
+	}
+	var candidates []string
+	if !inArgs {
+		for _, child := range node.children {
+			candidates = append(candidates, child.name+"\t"+child.description)
+		}
+	}
+	if node.args != nil {
+		candidates = append(candidates, node.args()...)
+	}
+	return filterCompletions(func() []string { return candidates }, current)
+}
+
+// flagValue returns the completion of the value of a flag, and whether word
+// is a flag taking a value that is not given in the same word.
+func (n completionNode) flagValue(word string) (func() []string, bool) {
+	if n.flagSet == nil || !strings.HasPrefix(word, "-") || strings.Contains(word, "=") {
+		return nil, false
+	}
+	// The flag package accepts one or two dashes.
+	name := strings.TrimPrefix(strings.TrimPrefix(word, "-"), "-")
+	f := n.flagSet().Lookup(name)
+	if f == nil {
+		return nil, false
+	}
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+		return nil, false
+	}
+	if value, ok := n.values[name]; ok {
+		return value, true
+	}
+	return func() []string { return nil }, true
+}
+
+func (n completionNode) child(name string) (completionNode, bool) {
+	for _, child := range n.children {
+		if child.name == name {
+			return child, true
+		}
+	}
+	return completionNode{}, false
+}
+
+// filterCompletions returns the candidates that start with prefix, ignoring
+// a quote the shell has not removed yet.
+func filterCompletions(candidates func() []string, prefix string) []string {
+	prefix = strings.TrimLeft(prefix, `'"`)
+	var filtered []string
+	for _, c := range candidates() {
+		if strings.HasPrefix(c, prefix) {
+			filtered = append(filtered, c)
+		}
+	}
+	return filtered
+}
+
+func historyMessages() []string {
+	entries, err := client.History()
+	if err != nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var messages []string
+	// The latest entries first, as the shell keeps the order in menus.
+	for i := len(entries) - 1; i >= 0; i-- {
+		if m := entries[i].Message; !seen[m] {
+			seen[m] = true
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func templateCategories() []string {
+	categories, err := client.Categories()
+	if err != nil {
+		return nil
+	}
+	return categories
+}
+
+func hookNames() []string {
+	return hooks
+}
+
+func mergeStrategies() []string {
+	return []string{
+		string(fuzzyfindmessage.MergeSkipDuplicates),
+		string(fuzzyfindmessage.MergeAppend),
+		string(fuzzyfindmessage.MergeReplace),
+	}
+}
+
+func commandNames() []string {
+	var names []string
+	for _, cmd := range commands {
+		if !cmd.hidden {
+			names = append(names, cmd.name)
+		}
+	}
+	return names
+}
+
+func shells() []string {
+	return []string{"bash", "zsh", "fish"}
+}
+
+func runCompletion(args []string) int {
+	fs := newFlagSet("fcm completion", completionUsage)
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	script, ok := completionScripts[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fcm completion: unknown shell %q\n\n%s", fs.Arg(0), completionUsage)
+		return ExitCodeError
+	}
+	fmt.Print(script)
+	return ExitCodeSuccess
+}
+
+// runComplete prints the completions of the words after fcm, the last of
+// which is being completed. The completion scripts call it as fcm __complete.
+func runComplete(args []string) int {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	for _, c := range completionTree().complete(args) {
+		fmt.Println(c)
+	}
+	return ExitCodeSuccess
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for fcm, generated by fcm completion bash.
+
+_fcm_complete() {
+	local i start=0 candidate
+	# The words after fcm, which may be run as "git fcm".
+	for ((i = 0; i < COMP_CWORD; i++)); do
+		if [[ ${COMP_WORDS[i]} == fcm || ${COMP_WORDS[i]} == */fcm ]]; then
+			start=$i
+			break
+		fi
+	done
+
+	COMPREPLY=()
+	while IFS= read -r candidate; do
+		candidate=${candidate%%$'\t'*}
+		if [[ $candidate == *[[:space:]]* ]]; then
+			candidate=$(printf '%q' "$candidate")
+		fi
+		COMPREPLY+=("$candidate")
+	done < <(fcm __complete "${COMP_WORDS[@]:start+1:COMP_CWORD-start}" 2>/dev/null)
+}
+
+complete -o default -F _fcm_complete fcm
+
+# git-completion.bash calls _git_fcm for "git fcm".
+_git_fcm() {
+	_fcm_complete
+}
+`,
+	"zsh": `#compdef fcm
+# zsh completion for fcm, generated by fcm completion zsh.
+
+_fcm() {
+	local -a out described
+	local line tab=$'\t'
+	# The words after fcm, which may be run as "git fcm".
+	local -i start=${words[(i)(fcm|*/fcm)]}
+	out=(${(f)"$(fcm __complete "${(@)words[start+1,CURRENT]}" 2>/dev/null)"})
+	if (( ${#out} == 0 )); then
+		_files
+		return
+	fi
+	for line in $out; do
+		if [[ $line == *$tab* ]]; then
+			described+=("${${line%%$tab*}//:/\\:}:${line#*$tab}")
+		else
+			described+=("${line//:/\\:}")
+		fi
+	done
+	_describe -t fcm fcm described
+}
+
+# _git calls _git-fcm for "git fcm".
+_git-fcm() {
+	_fcm
+}
+
+compdef _fcm fcm
+`,
+	"fish": `# fish completion for fcm, generated by fcm completion fish.
+
+function __fcm_complete
+	set -l tokens (commandline -opc)
+	# The words after fcm, which may be run as "git fcm".
+	set -l start (contains -i -- fcm $tokens)
+	or set start 1
+	set -e tokens[1..$start]
+	set -l out (fcm __complete $tokens (commandline -ct) 2>/dev/null)
+	if test (count $out) -eq 0
+		__fish_complete_path (commandline -ct)
+		return
+	end
+	printf '%s\n' $out
+end
+
+complete -c fcm -f -a '(__fcm_complete)'
+complete -c git -n '__fish_seen_subcommand_from fcm' -f -a '(__fcm_complete)'
+`,
+}
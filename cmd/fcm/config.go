@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+const configUsage = `usage: fcm config [<command>]
+
+commands:
+  list  print the settings in effect, defaults included (the default)
+  path  print the path of the config file, which need not exist
+`
+
+func runConfig(args []string) int {
+	if len(args) == 0 {
+		return runConfigList()
+	}
+
+	switch args[0] {
+	case "-h", "--help", "help":
+		fmt.Print(configUsage)
+		return ExitCodeSuccess
+	case "list":
+		return runConfigList()
+	case "path":
+		return runConfigPath()
+	}
+
+	fmt.Fprintf(os.Stderr, "fcm config: unknown command %q\n\n%s", args[0], configUsage)
+	return ExitCodeError
+}
+
+func runConfigList() int {
+	cfg, err := client.Config()
+	if err != nil {
+		return exitCode(err)
+	}
+
+	finder := cfg.Finder
+	if len(finder) == 0 {
+		finder = "builtin"
+	}
+	editor := cfg.Editor
+	if len(editor) == 0 {
+		editor = "git"
+	}
+	gitmoji := cfg.Gitmoji
+	if len(gitmoji) == 0 {
+		gitmoji = "off"
+	}
+	fmt.Printf("history.max_entries = %d\n", cfg.HistoryMaxEntries)
+	fmt.Printf("history.max_age = %s\n", formatAge(cfg.HistoryMaxAge))
+	for _, s := range cfg.Sources {
+		fmt.Printf("source = %s\n", s)
+	}
+	fmt.Printf("source.timeout = %s\n", cfg.SourceTimeout)
+	fmt.Printf("finder = %s\n", finder)
+	fmt.Printf("finder.new_message = %t\n", cfg.NewMessages)
+	fmt.Printf("editor = %s\n", editor)
+	fmt.Printf("gitmoji = %s\n", gitmoji)
+	return ExitCodeSuccess
+}
+
+func runConfigPath() int {
+	paths, err := client.Paths()
+	if err != nil {
+		return exitCode(err)
+	}
+
+	fmt.Println(paths.Config)
+	return ExitCodeSuccess
+}
+
+// formatAge formats an age the way history.max_age is written, in days when
+// it is a whole number of them.
+func formatAge(d time.Duration) string {
+	if d != 0 && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const doctorUsage = `usage: fcm doctor [--fix] [--run-sources]
+
+Check the template, history and config files, git, the hooks and the
+terminal, e.g. to find out why the list of fcm commit is empty. fcm doctor
+exits with 1 when a check fails. The exec sources of the config file are
+listed, and only run with --run-sources.
+`
+
+// doctorFlags are the flags of fcm doctor.
+type doctorFlags struct {
+	fix        bool
+	runSources bool
+}
+
+func (f *doctorFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("fcm doctor", doctorUsage)
+	fs.BoolVar(&f.fix, "fix", false, "remove the quotes fcm 1.0 and older recorded around the history entries")
+	fs.BoolVar(&f.runSources, "run-sources", false, "run the exec sources to count their candidates")
+	return fs
+}
+
+func runDoctor(args []string) int {
+	var f doctorFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	if f.fix {
+		repaired, err := client.RepairHistory()
+		if err != nil {
+			return exitCode(err)
+		}
+		fmt.Printf("repaired %d history entries\n", len(repaired))
+	}
+
+	code := ExitCodeSuccess
+	for _, check := range client.Doctor(context.Background(), fuzzyfindmessage.DoctorOptions{RunSources: f.runSources}) {
+		fmt.Printf("%-7s  %-23s  %s\n", check.Status, check.Name, check.Detail)
+		if check.Status == fuzzyfindmessage.CheckError {
+			code = ExitCodeError
+		}
+	}
+	return code
+}
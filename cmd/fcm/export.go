@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const exportUsage = `usage: fcm export [-name <name>] [-version <version>] [-author <author>] [-description <text>] [-o <file>]
+
+Write the templates of ~/.fcm as a pack that fcm import --pack reads.
+`
+
+// exportFlags are the flags of fcm export.
+type exportFlags struct {
+	meta   fuzzyfindmessage.Pack
+	output string
+}
+
+func (f *exportFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("fcm export", exportUsage)
+	fs.StringVar(&f.meta.Name, "name", "", "pack name")
+	fs.StringVar(&f.meta.Version, "version", "", "pack version")
+	fs.StringVar(&f.meta.Author, "author", "", "pack author")
+	fs.StringVar(&f.meta.Description, "description", "", "pack description")
+	fs.StringVar(&f.output, "o", "", "write the pack to this file instead of stdout")
+	return fs
+}
+
+func runExport(args []string) int {
+	var f exportFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+
+	var err error
+	if len(f.output) != 0 {
+		err = exportToFile(f.output, f.meta)
+	} else {
+		err = client.ExportPack(os.Stdout, f.meta)
+	}
+	if err != nil {
+		return exitCode(err)
+	}
+	return ExitCodeSuccess
+}
+
+// exportToFile writes the pack to fileName. The error of closing the file is
+// returned as well, as the last writes may only fail then.
+func exportToFile(fileName string, meta fuzzyfindmessage.Pack) (err error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = f.Close()
+			return
+		}
+		f.Close()
+	}()
+
+	return client.ExportPack(f, meta)
+}
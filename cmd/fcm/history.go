@@ -0,0 +1,310 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const historyUsage = `usage: fcm history <command> [<args>]
+
+commands:
+  list                list all entries, oldest first
+  search <query>      list the entries containing every word of the query
+  rm [<message>...]   remove entries; without arguments pick them with the fuzzy finder
+  edit [<message>]    edit an entry in the git editor; without arguments pick it with the fuzzy finder
+  promote [<message>] copy an entry into ~/.fcm; without arguments pick it with the fuzzy finder
+                      -category <name>  category header to add it under (picked with the fuzzy finder if omitted)
+                      -generalize       replace ticket IDs and file names with {ticket} and {file}
+  prune               apply the retention settings and drop duplicate messages
+`
+
+func runHistory(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, historyUsage)
+		return ExitCodeError
+	}
+
+	switch args[0] {
+	case "-h", "--help", "help":
+		fmt.Print(historyUsage)
+		return ExitCodeSuccess
+	case "list":
+		return runHistoryList(args[1:])
+	case "search":
+		return runHistorySearch(args[1:])
+	case "rm":
+		return runHistoryRemove(args[1:])
+	case "edit":
+		return runHistoryEdit(args[1:])
+	case "promote":
+		return runHistoryPromote(args[1:])
+	case "prune":
+		return runHistoryPrune(args[1:])
+	}
+
+	fmt.Fprintf(os.Stderr, "fcm history: unknown command %q\n\n%s", args[0], historyUsage)
+	return ExitCodeError
+}
+
+const historyListUsage = `usage: fcm history list
+
+List all entries of the history, oldest first.
+`
+
+func historyListFlagSet() *flag.FlagSet {
+	return newFlagSet("fcm history list", historyListUsage)
+}
+
+func runHistoryList(args []string) int {
+	fs := historyListFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	entries, err := client.History()
+	if err != nil {
+		return exitCode(err)
+	}
+
+	for _, e := range entries {
+		fmt.Println(formatEntry(e))
+	}
+	return ExitCodeSuccess
+}
+
+const historySearchUsage = `usage: fcm history search <query>
+
+List the entries of the history containing every word of the query.
+`
+
+func historySearchFlagSet() *flag.FlagSet {
+	return newFlagSet("fcm history search", historySearchUsage)
+}
+
+func runHistorySearch(args []string) int {
+	fs := historySearchFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	entries, err := client.SearchHistory(strings.Join(fs.Args(), " "))
+	if err != nil {
+		return exitCode(err)
+	}
+
+	for _, e := range entries {
+		fmt.Println(formatEntry(e))
+	}
+	return ExitCodeSuccess
+}
+
+const historyRemoveUsage = `usage: fcm history rm [<message>...]
+
+Remove entries from the history. Without messages, pick them with the fuzzy
+finder. Put -- before a message starting with a dash.
+`
+
+func historyRemoveFlagSet() *flag.FlagSet {
+	return newFlagSet("fcm history rm", historyRemoveUsage)
+}
+
+func runHistoryRemove(args []string) int {
+	fs := historyRemoveFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+
+	messages := fs.Args()
+	if len(messages) == 0 {
+		selected, err := client.SelectHistory(true)
+		if err != nil {
+			return exitCode(err)
+		}
+		for _, e := range selected {
+			messages = append(messages, e.Message)
+		}
+	}
+
+	removed, err := client.RemoveHistory(messages...)
+	if err != nil {
+		return exitCode(err)
+	}
+
+	for _, e := range removed {
+		fmt.Println(formatEntry(e))
+	}
+	fmt.Printf("%d entries removed\n", len(removed))
+	return ExitCodeSuccess
+}
+
+const historyEditUsage = `usage: fcm history edit [<message>]
+
+Edit an entry of the history in the git editor. Without a message, pick it
+with the fuzzy finder. Put -- before a message starting with a dash.
+`
+
+func historyEditFlagSet() *flag.FlagSet {
+	return newFlagSet("fcm history edit", historyEditUsage)
+}
+
+func runHistoryEdit(args []string) int {
+	fs := historyEditFlagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+
+	var message string
+	switch fs.NArg() {
+	case 0:
+		selected, err := client.SelectHistory(false)
+		if err != nil {
+			return exitCode(err)
+		}
+		if len(selected) == 0 {
+			return ExitCodeSuccess
+		}
+		message = selected[0].Message
+	case 1:
+		message = fs.Arg(0)
+	default:
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	edited, err := client.EditMessage(message)
+	if err != nil {
+		return exitCode(err)
+	}
+	if err := client.EditHistory(message, edited); err != nil {
+		return exitCode(err)
+	}
+	return ExitCodeSuccess
+}
+
+const historyPromoteUsage = `usage: fcm history promote [-category <name>] [-generalize] [<message>]
+
+Copy an entry of the history into ~/.fcm. Without a message, pick it with the
+fuzzy finder; without a category, pick that as well.
+`
+
+// historyPromoteFlags are the flags of fcm history promote.
+type historyPromoteFlags struct {
+	category   string
+	generalize bool
+}
+
+func (f *historyPromoteFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("fcm history promote", historyPromoteUsage)
+	fs.StringVar(&f.category, "category", "", "category header to add the message under")
+	fs.BoolVar(&f.generalize, "generalize", false, "replace ticket IDs and file names with placeholders")
+	return fs
+}
+
+func runHistoryPromote(args []string) int {
+	var f historyPromoteFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+
+	var message string
+	switch fs.NArg() {
+	case 0:
+		selected, err := client.SelectHistory(false)
+		if err != nil {
+			return exitCode(err)
+		}
+		if len(selected) == 0 {
+			return ExitCodeSuccess
+		}
+		message = selected[0].Message
+	case 1:
+		message = fs.Arg(0)
+	default:
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	if len(f.category) == 0 {
+		c, err := client.SelectCategory()
+		if err != nil {
+			return exitCode(err)
+		}
+		f.category = c
+	}
+
+	promoted, err := client.PromoteMessage(message, f.category, f.generalize)
+	if err != nil {
+		return exitCode(err)
+	}
+	fmt.Printf("added to %q: %s\n", f.category, promoted)
+	return ExitCodeSuccess
+}
+
+const historyPruneUsage = `usage: fcm history prune [-dry-run]
+
+Apply history.max_entries and history.max_age of the config file and drop
+duplicate messages.
+`
+
+// historyPruneFlags are the flags of fcm history prune.
+type historyPruneFlags struct {
+	dryRun bool
+}
+
+func (f *historyPruneFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("fcm history prune", historyPruneUsage)
+	fs.BoolVar(&f.dryRun, "dry-run", false, "show the entries that would be removed without removing them")
+	return fs
+}
+
+func runHistoryPrune(args []string) int {
+	var f historyPruneFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	removed, err := client.PruneHistory(f.dryRun)
+	if err != nil {
+		return exitCode(err)
+	}
+
+	for _, e := range removed {
+		fmt.Println(formatEntry(e))
+	}
+	if f.dryRun {
+		fmt.Printf("%d entries would be removed\n", len(removed))
+	} else {
+		fmt.Printf("%d entries removed\n", len(removed))
+	}
+
+	return ExitCodeSuccess
+}
+
+func formatEntry(e fuzzyfindmessage.HistoryEntry) string {
+	s := fmt.Sprintf("%-*s  %s", len(fuzzyfindmessage.HistoryTimeFormat), "-", e.Message)
+	if !e.Time.IsZero() {
+		s = fmt.Sprintf("%s  %s", e.Time.Format(fuzzyfindmessage.HistoryTimeFormat), e.Message)
+	}
+	if len(e.Repo) != 0 {
+		s += "  (" + e.Repo + ")"
+	}
+	return s
+}
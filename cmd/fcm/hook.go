@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const hookUsage = `usage: fcm hook <command> [<args>]
+
+commands:
+  install [--force] [<hook>...]  install hooks into the current repository (prepare-commit-msg by default)
+  uninstall [<hook>...]          remove the hooks installed by fcm (all by default)
+  status                         show which hooks are installed
+  run <hook> <args>...           what the installed hooks run
+
+hooks:
+  prepare-commit-msg  choose the message of a plain git commit with fcm before the editor opens
+  commit-msg          reject messages fcm lint finds problems in
+`
+
+var hooks = []string{fuzzyfindmessage.PrepareCommitMsgHook, fuzzyfindmessage.CommitMsgHook}
+
+func runHook(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, hookUsage)
+		return ExitCodeError
+	}
+
+	switch args[0] {
+	case "-h", "--help", "help":
+		fmt.Print(hookUsage)
+		return ExitCodeSuccess
+	case "install":
+		return runHookInstall(args[1:])
+	case "uninstall":
+		return runHookUninstall(args[1:])
+	case "status":
+		return runHookStatus(args[1:])
+	case "run":
+		return runHookRun(args[1:])
+	}
+
+	fmt.Fprintf(os.Stderr, "fcm hook: unknown command %q\n\n%s", args[0], hookUsage)
+	return ExitCodeError
+}
+
+// hookInstallFlags are the flags of fcm hook install.
+type hookInstallFlags struct {
+	force bool
+}
+
+func (f *hookInstallFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("fcm hook install", hookUsage)
+	fs.BoolVar(&f.force, "force", false, "replace hooks that fcm did not write")
+	return fs
+}
+
+func runHookInstall(args []string) int {
+	var f hookInstallFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	names := fs.Args()
+	if len(names) == 0 {
+		names = []string{fuzzyfindmessage.PrepareCommitMsgHook}
+	}
+
+	for _, name := range names {
+		path, err := client.InstallHook(context.Background(), name, f.force)
+		if err != nil {
+			return exitCode(err)
+		}
+		fmt.Printf("installed %s\n", path)
+	}
+	return ExitCodeSuccess
+}
+
+func runHookUninstall(args []string) int {
+	names := args
+	if len(names) == 0 {
+		names = hooks
+	}
+
+	for _, name := range names {
+		state, _, err := client.HookStatus(context.Background(), name)
+		if err != nil {
+			return exitCode(err)
+		}
+		// Hooks of others are only worth a complaint when asked for.
+		if state == fuzzyfindmessage.HookMissing || (state == fuzzyfindmessage.HookForeign && len(args) == 0) {
+			continue
+		}
+		path, err := client.UninstallHook(context.Background(), name)
+		if err != nil {
+			return exitCode(err)
+		}
+		fmt.Printf("removed %s\n", path)
+	}
+	return ExitCodeSuccess
+}
+
+func runHookStatus(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: fcm hook status")
+		return ExitCodeError
+	}
+
+	for _, name := range hooks {
+		state, path, err := client.HookStatus(context.Background(), name)
+		if err != nil {
+			return exitCode(err)
+		}
+		fmt.Printf("%-18s  %-9s  %s\n", name, state, path)
+	}
+	return ExitCodeSuccess
+}
+
+func runHookRun(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: fcm hook run <hook> <message file> [<args>...]")
+		return ExitCodeError
+	}
+
+	switch args[0] {
+	case fuzzyfindmessage.PrepareCommitMsgHook:
+		err := client.PrepareMessage(context.Background(), args[1])
+		// Leaving the finder leaves the message to the editor of git.
+		if err != nil && !errors.Is(err, fuzzyfindmessage.ErrAborted) {
+			return exitCode(err)
+		}
+		return ExitCodeSuccess
+	case fuzzyfindmessage.CommitMsgHook:
+		ok, err := lintFile(args[1])
+		if err != nil {
+			return exitCode(err)
+		}
+		if !ok {
+			return ExitCodeError
+		}
+		return ExitCodeSuccess
+	}
+
+	fmt.Fprintf(os.Stderr, "fcm hook run: unknown hook %q\n", args[0])
+	return ExitCodeError
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const importUsage = `usage: fcm import --from-git [--author <pattern>] [--since <date>] [--bodies] [--all-repos <dir>] [<repo>...]
+       fcm import --pack <file> [--strategy append|replace|skip]
+
+Harvest commit messages from git log into the history, or merge a template
+pack written by fcm export into ~/.fcm.
+`
+
+// importFlags are the flags of fcm import.
+type importFlags struct {
+	fromGit  bool
+	log      fuzzyfindmessage.GitLogOptions
+	allRepos string
+	pack     string
+	strategy string
+}
+
+func (f *importFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("fcm import", importUsage)
+	fs.BoolVar(&f.fromGit, "from-git", false, "import commit messages from git log")
+	fs.StringVar(&f.log.Author, "author", "", `only commits by this author ("me" for your user.email)`)
+	fs.StringVar(&f.log.Since, "since", "", "only commits more recent than this date (e.g. 6.months)")
+	fs.BoolVar(&f.log.Bodies, "bodies", false, "import whole messages instead of subject lines")
+	fs.StringVar(&f.allRepos, "all-repos", "", "import every repository found under this directory")
+	fs.StringVar(&f.pack, "pack", "", "import a template pack")
+	fs.StringVar(&f.strategy, "strategy", string(fuzzyfindmessage.MergeSkipDuplicates), "how to merge the pack: append, replace or skip (duplicates)")
+	return fs
+}
+
+func runImport(args []string) int {
+	var f importFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if len(f.pack) != 0 {
+		return importPack(f.pack, fuzzyfindmessage.MergeStrategy(f.strategy))
+	}
+	if !f.fromGit {
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	dirs := fs.Args()
+	if len(f.allRepos) != 0 {
+		repos, err := client.FindRepositories(f.allRepos)
+		if err != nil {
+			return exitCode(err)
+		}
+		dirs = append(dirs, repos...)
+	}
+	if len(dirs) == 0 {
+		dirs = []string{"."}
+	}
+
+	imported, err := client.ImportGitLog(dirs, f.log)
+	if err != nil {
+		return exitCode(err)
+	}
+
+	fmt.Printf("%d messages imported from %d repositories\n", len(imported), len(dirs))
+	return ExitCodeSuccess
+}
+
+func importPack(fileName string, strategy fuzzyfindmessage.MergeStrategy) int {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return exitCode(err)
+	}
+	defer f.Close()
+
+	pack, added, err := client.ImportPack(f, strategy)
+	if err != nil {
+		return exitCode(err)
+	}
+
+	fmt.Printf("%d templates added from %s %s\n", added, pack.Name, pack.Version)
+	return ExitCodeSuccess
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const initUsage = `usage: fcm init [--preset <name>] [--hook <hook>,...] [--force]
+
+Write a .fcm of templates and a .fcm_config into the top level directory of
+the repository, for the team to commit and share. fcm offers those templates
+next to ~/.fcm. The history stays personal: .fcm_history is added to
+.gitignore. Without --preset, the preset is chosen with the finder.
+`
+
+// initFlags are the flags of fcm init.
+type initFlags struct {
+	preset string
+	hook   string
+	force  bool
+}
+
+func (f *initFlags) flagSet() *flag.FlagSet {
+	fs := newFlagSet("fcm init", initUsage+presetList())
+	fs.StringVar(&f.preset, "preset", "", "the templates to start with: "+strings.Join(presetNames(), ", "))
+	fs.StringVar(&f.hook, "hook", "", "the hooks to install, separated by commas")
+	fs.BoolVar(&f.force, "force", false, "replace an existing .fcm and .fcm_config")
+	return fs
+}
+
+func runInit(args []string) int {
+	var f initFlags
+	fs := f.flagSet()
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return ExitCodeError
+	}
+
+	opts := fuzzyfindmessage.InitOptions{Preset: f.preset, Force: f.force}
+	if len(f.hook) != 0 {
+		opts.Hooks = strings.Split(f.hook, ",")
+	}
+	written, err := client.Init(context.Background(), opts)
+	for _, path := range written {
+		fmt.Printf("wrote %s\n", path)
+	}
+	if err != nil {
+		return exitCode(err)
+	}
+	return ExitCodeSuccess
+}
+
+// presetList returns the presets as shown in the usage of fcm init.
+func presetList() string {
+	var list strings.Builder
+	list.WriteString("\npresets:\n")
+	for _, p := range fuzzyfindmessage.Presets() {
+		fmt.Fprintf(&list, "  %-12s  %s\n", p.Name, p.Description)
+	}
+	return list.String()
+}
+
+func presetNames() []string {
+	var names []string
+	for _, p := range fuzzyfindmessage.Presets() {
+		names = append(names, p.Name)
+	}
+	return names
+}
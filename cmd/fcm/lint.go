@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const lintUsage = `usage: fcm lint [<file>...]
+
+Check the commit messages in the files, or on the standard input without
+any or for "-". Lines starting with "#" are ignored as git does. fcm lint
+exits with 1 when it finds a problem: an empty message, a subject longer
+than 72 characters or ending with a period, no blank line after the subject
+or a placeholder such as {ticket} that was not filled in.
+`
+
+func runLint(args []string) int {
+	fs := newFlagSet("fcm lint", lintUsage)
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	code := ExitCodeSuccess
+	for _, fileName := range files {
+		ok, err := lintFile(fileName)
+		if err != nil {
+			return exitCode(err)
+		}
+		if !ok {
+			code = ExitCodeError
+		}
+	}
+	return code
+}
+
+// lintFile prints the problems of the message in fileName and tells whether
+// there were none.
+func lintFile(fileName string) (bool, error) {
+	var b []byte
+	var err error
+	if fileName == "-" {
+		b, err = ioutil.ReadAll(os.Stdin)
+		fileName = "<stdin>"
+	} else {
+		b, err = ioutil.ReadFile(fileName)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	problems := fuzzyfindmessage.LintMessage(string(b))
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", fileName, p)
+	}
+	return len(problems) == 0, nil
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
 )
@@ -13,17 +15,134 @@ const (
 	ExitCodeSuccess = 0
 	// ExitCodeError indicates that some error occurred during processing
 	ExitCodeError = 1
+	// ExitCodeNothingStaged indicates that git had nothing to commit
+	ExitCodeNothingStaged = 3
+	// ExitCodeNotARepo indicates that fcm was not run in a git repository
+	ExitCodeNotARepo = 4
+	// ExitCodeGitFailed indicates that git exited with a failure
+	ExitCodeGitFailed = 5
+	// ExitCodeAborted indicates that the user left without choosing, as fzf
+	// does on Esc
+	ExitCodeAborted = 130
 	// Version is application version
 	Version = "1.0.2"
 )
 
+const globalUsage = `usage: fcm [<global flags>] [<command>] [<args>]
+
+Choose a commit message from your templates and history with a fuzzy finder.
+
+commands:
+%s
+global flags:
+  --config <file>  read the settings from <file> instead of ~/.fcm_config
+  --repo <dir>     run as if fcm was started in <dir>
+  --no-history     do not add the committed message to the history
+  --go-git         read the repository with go-git instead of the git binary
+  -v, --version    show version
+
+Run "fcm help <command>" for the arguments of a command.
+`
+
+// command is a subcommand of fcm.
+type command struct {
+	name string
+	// summary is the line shown in the list of commands.
+	summary string
+	run     func(args []string) int
+	// hidden commands are left out of the help and of the completion.
+	hidden bool
+}
+
 var (
 	showVersion bool
+	configFile  string
+	repoDir     string
+	noHistory   bool
+	goGit       bool
+
+	// commands are the subcommands in the order of the help. The first one
+	// runs when none is given.
+	commands []command
+
+	// client is the Client configured by the global flags.
+	client *fuzzyfindmessage.Client
 )
 
 func init() {
 	flag.BoolVar(&showVersion, "v", false, "show version (short)")
 	flag.BoolVar(&showVersion, "version", false, "show version")
+	flag.StringVar(&configFile, "config", "", "config file")
+	flag.StringVar(&repoDir, "repo", "", "repository to run in")
+	flag.BoolVar(&noHistory, "no-history", false, "do not add the message to the history")
+	flag.BoolVar(&goGit, "go-git", false, "read the repository with go-git")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage())
+	}
+
+	commands = []command{
+		{name: "commit", summary: "choose a message and commit (the default)", run: runCommit},
+		{name: "history", summary: "list, search, edit and prune the history", run: runHistory},
+		{name: "templates", summary: "list the candidates and the template categories", run: runTemplates},
+		{name: "config", summary: "show the config file and the settings", run: runConfig},
+		{name: "lint", summary: "check commit messages", run: runLint},
+		{name: "hook", summary: "install, remove or run the git hooks of fcm", run: runHook},
+		{name: "import", summary: "import messages from git log or a template pack", run: runImport},
+		{name: "export", summary: "write the templates as a pack", run: runExport},
+		{name: "init", summary: "write the templates and settings of a team into the repository", run: runInit},
+		{name: "doctor", summary: "check the files, git and the terminal", run: runDoctor},
+		{name: "completion", summary: "print the shell completion script", run: runCompletion},
+		{name: "help", summary: "show the help of fcm or of a command", run: runHelp},
+		{name: "__complete", run: runComplete, hidden: true},
+	}
+}
+
+// usage returns the help of fcm with the list of commands.
+func usage() string {
+	var list strings.Builder
+	for _, cmd := range commands {
+		if cmd.hidden {
+			continue
+		}
+		fmt.Fprintf(&list, "  %-10s  %s\n", cmd.name, cmd.summary)
+	}
+	return fmt.Sprintf(globalUsage, list.String())
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// newFlagSet returns the flag set of a command, which prints usage and the
+// defaults of its flags for -h.
+func newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) {
+			hasFlags = true
+		})
+		if hasFlags {
+			fmt.Fprintln(fs.Output(), "\nflags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parseError returns the exit code for an error of FlagSet.Parse, which has
+// printed the usage already.
+func parseError(err error) int {
+	if err == flag.ErrHelp {
+		return ExitCodeSuccess
+	}
+	return ExitCodeError
 }
 
 func run() int {
@@ -33,14 +152,100 @@ func run() int {
 		return ExitCodeSuccess
 	}
 
-	if err := fuzzyfindmessage.Commit(); err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
+	if len(repoDir) != 0 {
+		if err := os.Chdir(repoDir); err != nil {
+			return exitCode(err)
+		}
+	}
+	var opts []fuzzyfindmessage.Option
+	if len(configFile) != 0 {
+		opts = append(opts, fuzzyfindmessage.WithConfigFile(configFile))
+	}
+	if noHistory {
+		opts = append(opts, fuzzyfindmessage.WithoutHistory())
+	}
+	if goGit {
+		opts = append(opts, fuzzyfindmessage.WithGoGit())
+	}
+	client = fuzzyfindmessage.New(opts...)
+
+	if flag.NArg() == 0 {
+		return commands[0].run(nil)
+	}
+	cmd, ok := findCommand(flag.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "fcm: unknown command %q\n\n%s", flag.Arg(0), usage())
+		return ExitCodeError
+	}
+	return cmd.run(flag.Args()[1:])
+}
+
+const commitUsage = `usage: fcm commit
+
+Choose a message from the templates and the history with the fuzzy finder
+and commit the staged changes with it. This is what fcm does without a
+command.
+`
+
+func runCommit(args []string) int {
+	fs := newFlagSet("fcm commit", commitUsage)
+	if err := fs.Parse(args); err != nil {
+		return parseError(err)
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
 		return ExitCodeError
 	}
 
+	if err := client.Commit(); err != nil {
+		return exitCode(err)
+	}
 	return ExitCodeSuccess
 }
 
+const helpUsage = `usage: fcm help [<command>]
+
+Show the help of fcm, or of a command.
+`
+
+func runHelp(args []string) int {
+	switch len(args) {
+	case 0:
+		fmt.Print(usage())
+		return ExitCodeSuccess
+	case 1:
+		cmd, ok := findCommand(args[0])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "fcm help: unknown command %q\n", args[0])
+			return ExitCodeError
+		}
+		// Let the command print its flags as well.
+		return cmd.run([]string{"-h"})
+	}
+	fmt.Fprint(os.Stderr, helpUsage)
+	return ExitCodeError
+}
+
+// exitCode prints err, unless the user aborted, and returns the exit code
+// for it.
+func exitCode(err error) int {
+	if errors.Is(err, fuzzyfindmessage.ErrAborted) {
+		return ExitCodeAborted
+	}
+	fmt.Fprintln(os.Stderr, err.Error())
+
+	var gitErr *fuzzyfindmessage.GitError
+	switch {
+	case errors.Is(err, fuzzyfindmessage.ErrNothingStaged):
+		return ExitCodeNothingStaged
+	case errors.Is(err, fuzzyfindmessage.ErrNotARepo):
+		return ExitCodeNotARepo
+	case errors.As(err, &gitErr):
+		return ExitCodeGitFailed
+	}
+	return ExitCodeError
+}
+
 func main() {
 	os.Exit(run())
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/wataboru/git-fuzzy-find-commit-message/fuzzyfindmessage"
+)
+
+const templatesUsage = `usage: fcm templates [<command>]
+
+commands:
+  list        list the candidates offered by fcm commit and where they came from (the default)
+  categories  list the category headers of ~/.fcm
+`
+
+func runTemplates(args []string) int {
+	if len(args) == 0 {
+		return runTemplatesList()
+	}
+
+	switch args[0] {
+	case "-h", "--help", "help":
+		fmt.Print(templatesUsage)
+		return ExitCodeSuccess
+	case "list":
+		return runTemplatesList()
+	case "categories":
+		return runTemplatesCategories()
+	}
+
+	fmt.Fprintf(os.Stderr, "fcm templates: unknown command %q\n\n%s", args[0], templatesUsage)
+	return ExitCodeError
+}
+
+func runTemplatesList() int {
+	candidates, err := client.Samples(context.Background())
+	if err != nil {
+		return exitCode(err)
+	}
+
+	for _, c := range candidates {
+		fmt.Println(formatCandidate(c))
+	}
+	return ExitCodeSuccess
+}
+
+func runTemplatesCategories() int {
+	categories, err := client.Categories()
+	if err != nil {
+		return exitCode(err)
+	}
+
+	for _, c := range categories {
+		fmt.Println(c)
+	}
+	return ExitCodeSuccess
+}
+
+func formatCandidate(c fuzzyfindmessage.Candidate) string {
+	if len(c.Origin) == 0 {
+		return c.Message
+	}
+	return c.Message + "  (" + c.Origin + ")"
+}
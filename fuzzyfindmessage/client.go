@@ -0,0 +1,294 @@
+package fuzzyfindmessage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// Client runs fcm with its own files, finder, external commands, clock and
+// standard streams. Clients do not share state, so several of them can be
+// used side by side, e.g. by parallel tests or by a tool embedding fcm.
+//
+// The package level functions such as Commit use a Client created with the
+// default options.
+type Client struct {
+	osOpen               func(name string) (*os.File, error)
+	osOpenFile           func(name string, flag int, perm os.FileMode) (*os.File, error)
+	osCreate             func(name string) (*os.File, error)
+	osStat               func(name string) (os.FileInfo, error)
+	osRemove             func(name string) error
+	osRename             func(oldpath, newpath string) error
+	bufioNewScanner      func(r io.Reader) *bufio.Scanner
+	fmtFprintf           func(w io.Writer, format string, a ...interface{}) (n int, err error)
+	fmtFprintln          func(w io.Writer, a ...interface{}) (n int, err error)
+	ioutilTempFile       func(dir, pattern string) (f *os.File, err error)
+	fileWrite            func(file *os.File, b []byte) (n int, err error)
+	fileClose            func(file *os.File) error
+	scannerScan          func(scanner *bufio.Scanner) bool
+	scannerText          func(scanner *bufio.Scanner) string
+	homeDir              func() (string, error)
+	home                 string
+	userCurrent          func() (*user.User, error)
+	osUserConfigDir      func() (string, error)
+	osMkdirAll           func(path string, perm os.FileMode) error
+	resolveOnce          sync.Once
+	resolveErr           error
+	exampleFilePath      string
+	historyFilePath      string
+	configFilePath       string
+	samples              func(ctx context.Context) ([]Candidate, error)
+	saveHistory          func(ctx context.Context) (err error)
+	createTemplate       func(message string) (f *os.File, err error)
+	createDefaultFile    func(filePath string) error
+	removeDuplicate      func(slice []string) []string
+	exists               func(filename string) bool
+	createEmptyHistory   func() (err error)
+	createDefaultExample func() (err error)
+	finder               Finder
+	git                  Git
+	editor               Editor
+	newMessages          bool
+	noHistory            bool
+	screen               screen
+	ioutilReadFile       func(filename string) ([]byte, error)
+	ioutilReadDir        func(dirname string) ([]os.FileInfo, error)
+	includeTemplates     func(directive, path, from string, chain []string) ([]Candidate, error)
+	readTemplateFile     func(path string, chain []string) ([]Candidate, error)
+	envTemplates         func() ([]Candidate, error)
+	configTemplates      func(ctx context.Context) ([]Candidate, error)
+	historyTemplates     func() ([]Candidate, error)
+	osGetenv             func(key string) string
+	osGetwd              func() (dir string, err error)
+	sources              func(ctx context.Context) ([]Source, error)
+	extraSources         []Source
+	runEditor            func(ctx context.Context, fileName string) error
+	tmpFileName          func(f *os.File) string
+	lockHistory          func(exclusive bool) (unlock func() error, err error)
+	rewriteHistory       func(write func(w io.Writer) error) (err error)
+	writeFileAtomic      func(filePath string, write func(w io.Writer) error) (err error)
+	loadConfig           func() (*Config, error)
+	readHistory          func() ([]HistoryEntry, error)
+	pruneHistory         func(dryRun bool) ([]HistoryEntry, error)
+	timeNow              func() time.Time
+	execCommandContext   func(ctx context.Context, name string, arg ...string) *exec.Cmd
+	commandRun           func(cmd *exec.Cmd) error
+	commandOutput        func(cmd *exec.Cmd) ([]byte, error)
+	stdin                io.Reader
+	stdout               io.Writer
+	stderr               io.Writer
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// FileSystem is the file access of a Client. It can wrap the os package,
+// e.g. to confine fcm to a directory or to observe the files it writes.
+type FileSystem interface {
+	Open(name string) (*os.File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+	Create(name string) (*os.File, error)
+	Stat(name string) (os.FileInfo, error)
+	Remove(name string) error
+	Rename(oldpath, newpath string) error
+	TempFile(dir, pattern string) (*os.File, error)
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+}
+
+// Runner runs the external commands of a Client: git, the editor and the
+// exec sources of the config file.
+type Runner interface {
+	// Run runs cmd with its standard streams already attached.
+	Run(cmd *exec.Cmd) error
+	// Output runs cmd and returns its standard output.
+	Output(cmd *exec.Cmd) ([]byte, error)
+}
+
+var (
+	defaultClientOnce sync.Once
+	defaultClient     *Client
+)
+
+// New returns a Client that uses the files in the home directory, the
+// finder named in the config file (the builtin one by default), git from
+// $PATH, the os package and the standard streams unless opts say otherwise.
+// The home directory is looked up on first use, so New succeeds even when it
+// cannot be found.
+func New(opts ...Option) *Client {
+	c := &Client{}
+	c.initDefaults()
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// std returns the Client behind the package level functions.
+func std() *Client {
+	defaultClientOnce.Do(func() {
+		defaultClient = New()
+	})
+	return defaultClient
+}
+
+// WithTemplateFile replaces ~/.fcm.
+func WithTemplateFile(path string) Option {
+	return func(c *Client) {
+		c.exampleFilePath = path
+	}
+}
+
+// WithHistoryFile replaces ~/.fcm_history. The lock file is placed next to it.
+func WithHistoryFile(path string) Option {
+	return func(c *Client) {
+		c.historyFilePath = path
+	}
+}
+
+// WithConfigFile replaces ~/.fcm_config.
+func WithConfigFile(path string) Option {
+	return func(c *Client) {
+		c.configFilePath = path
+	}
+}
+
+// WithoutHistory commits without adding the message to the history.
+func WithoutHistory() Option {
+	return func(c *Client) {
+		c.noHistory = true
+	}
+}
+
+// WithFinder replaces the builtin finder.
+func WithFinder(f Finder) Option {
+	return func(c *Client) {
+		c.finder = f
+	}
+}
+
+// WithRunner replaces os/exec for running git and the other commands.
+func WithRunner(r Runner) Option {
+	return func(c *Client) {
+		c.commandRun = r.Run
+		c.commandOutput = r.Output
+	}
+}
+
+// WithFileSystem replaces the os package for file access.
+func WithFileSystem(fs FileSystem) Option {
+	return func(c *Client) {
+		c.osOpen = fs.Open
+		c.osOpenFile = fs.OpenFile
+		c.osCreate = fs.Create
+		c.osStat = fs.Stat
+		c.osRemove = fs.Remove
+		c.osRename = fs.Rename
+		c.ioutilTempFile = fs.TempFile
+		c.ioutilReadFile = fs.ReadFile
+		c.ioutilReadDir = fs.ReadDir
+	}
+}
+
+// WithClock replaces time.Now, which timestamps the history.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) {
+		c.timeNow = now
+	}
+}
+
+// WithStdio replaces the standard streams handed to git, the editor and the
+// external finders, and read and written by the prompt finder.
+func WithStdio(stdin io.Reader, stdout, stderr io.Writer) Option {
+	return func(c *Client) {
+		c.stdin = stdin
+		c.stdout = stdout
+		c.stderr = stderr
+	}
+}
+
+// WithSources adds candidate sources after the built-in ones and those of
+// RegisterSource.
+func WithSources(sources ...Source) Option {
+	return func(c *Client) {
+		c.extraSources = append(c.extraSources, sources...)
+	}
+}
+
+func (c *Client) initDefaults() {
+	c.osOpen = os.Open
+	c.osOpenFile = os.OpenFile
+	c.osCreate = os.Create
+	c.osStat = os.Stat
+	c.osRemove = os.Remove
+	c.osRename = os.Rename
+	c.bufioNewScanner = bufio.NewScanner
+	c.fmtFprintf = fmt.Fprintf
+	c.fmtFprintln = fmt.Fprintln
+	c.ioutilTempFile = ioutil.TempFile
+	c.fileWrite = func(file *os.File, b []byte) (n int, err error) {
+		return file.Write(b)
+	}
+	c.fileClose = func(file *os.File) error {
+		return file.Close()
+	}
+	c.scannerScan = func(scanner *bufio.Scanner) bool {
+		return scanner.Scan()
+	}
+	c.scannerText = func(scanner *bufio.Scanner) string {
+		return scanner.Text()
+	}
+	c.userCurrent = user.Current
+	c.homeDir = c._homeDir
+	c.osUserConfigDir = os.UserConfigDir
+	c.osMkdirAll = os.MkdirAll
+	c.samples = c._samples
+	c.saveHistory = c._saveHistory
+	c.createTemplate = c._createTemplate
+	c.createDefaultFile = c._createDefaultFile
+	c.removeDuplicate = _removeDuplicate
+	c.exists = c._exists
+	c.createEmptyHistory = c._createEmptyHistory
+	c.createDefaultExample = c._createDefaultExample
+	c.finder = configFinder{c: c}
+	c.git = execGit{c: c}
+	c.screen = termboxScreen{}
+	c.ioutilReadFile = ioutil.ReadFile
+	c.ioutilReadDir = ioutil.ReadDir
+	c.includeTemplates = c._includeTemplates
+	c.readTemplateFile = c._readTemplateFile
+	c.envTemplates = c._envTemplates
+	c.configTemplates = c._configTemplates
+	c.historyTemplates = c._historyTemplates
+	c.osGetenv = os.Getenv
+	c.osGetwd = os.Getwd
+	c.sources = c._sources
+	c.runEditor = c._runEditor
+	c.tmpFileName = func(f *os.File) string {
+		return f.Name()
+	}
+	c.lockHistory = c._lockHistory
+	c.rewriteHistory = c._rewriteHistory
+	c.writeFileAtomic = c._writeFileAtomic
+	c.loadConfig = c._loadConfig
+	c.readHistory = c._readHistory
+	c.pruneHistory = c._pruneHistory
+	c.timeNow = time.Now
+	c.execCommandContext = exec.CommandContext
+	c.commandRun = func(cmd *exec.Cmd) error {
+		return cmd.Run()
+	}
+	c.commandOutput = func(cmd *exec.Cmd) ([]byte, error) {
+		return cmd.Output()
+	}
+	c.stdin = os.Stdin
+	c.stdout = os.Stdout
+	c.stderr = os.Stderr
+}
@@ -0,0 +1,420 @@
+package fuzzyfindmessage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recordingRunner struct {
+	ran []*exec.Cmd
+	out []byte
+}
+
+func (r *recordingRunner) Run(cmd *exec.Cmd) error {
+	r.ran = append(r.ran, cmd)
+	return nil
+}
+
+func (r *recordingRunner) Output(cmd *exec.Cmd) ([]byte, error) {
+	r.ran = append(r.ran, cmd)
+	return r.out, nil
+}
+
+// recordingFileSystem is the os package, remembering the files opened.
+type recordingFileSystem struct {
+	opened []string
+}
+
+func (fs *recordingFileSystem) Open(name string) (*os.File, error) {
+	fs.opened = append(fs.opened, name)
+	return os.Open(name)
+}
+
+func (fs *recordingFileSystem) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	fs.opened = append(fs.opened, name)
+	return os.OpenFile(name, flag, perm)
+}
+
+func (fs *recordingFileSystem) Create(name string) (*os.File, error) {
+	fs.opened = append(fs.opened, name)
+	return os.Create(name)
+}
+
+func (fs *recordingFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (fs *recordingFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (fs *recordingFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (fs *recordingFileSystem) TempFile(dir, pattern string) (*os.File, error) {
+	return ioutil.TempFile(dir, pattern)
+}
+
+func (fs *recordingFileSystem) ReadFile(name string) ([]byte, error) {
+	fs.opened = append(fs.opened, name)
+	return ioutil.ReadFile(name)
+}
+
+func (fs *recordingFileSystem) ReadDir(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(name)
+}
+
+// fakeGit is the embedded Git, or a Git returning zero values in a repository
+// rooted at "." with a staged change when it is nil, with the methods given
+// as functions replaced.
+type fakeGit struct {
+	Git
+	commit      func(ctx context.Context, fileName string, edit bool) error
+	headCommit  func(ctx context.Context) (string, error)
+	headMessage func(ctx context.Context) (string, error)
+	config      func(ctx context.Context, key string) ([]string, error)
+	topLevel    func(ctx context.Context) (string, error)
+	stagedDiff  func(ctx context.Context) (string, error)
+	staged      func(ctx context.Context) (bool, error)
+	hooksDir    func(ctx context.Context) (string, error)
+	log         func(ctx context.Context, opts LogOptions) ([]LogEntry, error)
+	version     func(ctx context.Context) (string, error)
+}
+
+func (g *fakeGit) Commit(ctx context.Context, fileName string, edit bool) error {
+	if g.commit != nil {
+		return g.commit(ctx, fileName, edit)
+	}
+	if g.Git != nil {
+		return g.Git.Commit(ctx, fileName, edit)
+	}
+	return nil
+}
+
+func (g *fakeGit) HeadCommit(ctx context.Context) (string, error) {
+	if g.headCommit != nil {
+		return g.headCommit(ctx)
+	}
+	if g.Git != nil {
+		return g.Git.HeadCommit(ctx)
+	}
+	return "", nil
+}
+
+func (g *fakeGit) HeadMessage(ctx context.Context) (string, error) {
+	if g.headMessage != nil {
+		return g.headMessage(ctx)
+	}
+	if g.Git != nil {
+		return g.Git.HeadMessage(ctx)
+	}
+	return "", nil
+}
+
+func (g *fakeGit) Branch(ctx context.Context) (string, error) {
+	if g.Git != nil {
+		return g.Git.Branch(ctx)
+	}
+	return "", nil
+}
+
+func (g *fakeGit) StagedDiff(ctx context.Context) (string, error) {
+	if g.stagedDiff != nil {
+		return g.stagedDiff(ctx)
+	}
+	if g.Git != nil {
+		return g.Git.StagedDiff(ctx)
+	}
+	return "diff --git a/hoge b/hoge\n", nil
+}
+
+func (g *fakeGit) HasStagedChanges(ctx context.Context) (bool, error) {
+	if g.staged != nil {
+		return g.staged(ctx)
+	}
+	if g.Git != nil {
+		return g.Git.HasStagedChanges(ctx)
+	}
+	return true, nil
+}
+
+func (g *fakeGit) Config(ctx context.Context, key string) ([]string, error) {
+	if g.config != nil {
+		return g.config(ctx, key)
+	}
+	if g.Git != nil {
+		return g.Git.Config(ctx, key)
+	}
+	return nil, nil
+}
+
+func (g *fakeGit) TopLevel(ctx context.Context) (string, error) {
+	if g.topLevel != nil {
+		return g.topLevel(ctx)
+	}
+	if g.Git != nil {
+		return g.Git.TopLevel(ctx)
+	}
+	return ".", nil
+}
+
+type mockFinder struct {
+	id    int
+	ids   []int
+	err   error
+	items []string
+}
+
+func (f *mockFinder) Find(ctx context.Context, items []string, preview func(i int) string) (int, error) {
+	f.items = items
+	return f.id, f.err
+}
+
+func (f *mockFinder) FindMulti(ctx context.Context, items []string, preview func(i int) string) ([]int, error) {
+	f.items = items
+	return f.ids, f.err
+}
+
+func TestNew(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader("")
+	c := New(
+		WithTemplateFile("/tmp/fcm/templates"),
+		WithHistoryFile("/tmp/fcm/history"),
+		WithConfigFile("/tmp/fcm/config"),
+		WithClock(func() time.Time {
+			return date(1)
+		}),
+		WithStdio(stdin, &stdout, &stderr),
+	)
+
+	if c.exampleFilePath != "/tmp/fcm/templates" || c.historyFilePath != "/tmp/fcm/history" || c.configFilePath != "/tmp/fcm/config" {
+		t.Errorf("New() paths = %v, %v, %v", c.exampleFilePath, c.historyFilePath, c.configFilePath)
+	}
+	if got := c.timeNow(); !got.Equal(date(1)) {
+		t.Errorf("New() clock = %v, want %v", got, date(1))
+	}
+	if c.stdin != stdin || c.stdout != &stdout || c.stderr != &stderr {
+		t.Errorf("New() did not use the given streams")
+	}
+}
+
+func TestWithRunner(t *testing.T) {
+	var stdout bytes.Buffer
+	r := &recordingRunner{out: []byte("Add hoge\n")}
+	c := New(WithRunner(r), WithStdio(strings.NewReader(""), &stdout, &stdout))
+
+	if err := c.git.Commit(context.Background(), "hoge", true); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	got, err := c.git.HeadMessage(context.Background())
+	if err != nil {
+		t.Fatalf("HeadMessage() error = %v", err)
+	}
+	if got != "Add hoge" {
+		t.Errorf("HeadMessage() got = %v, want Add hoge", got)
+	}
+	if len(r.ran) != 2 {
+		t.Fatalf("runner ran %d commands, want 2", len(r.ran))
+	}
+	if want := []string{"git", "commit", "-F", "hoge", "-e"}; !reflect.DeepEqual(r.ran[0].Args, want) {
+		t.Errorf("runner ran %v, want %v", r.ran[0].Args, want)
+	}
+	if r.ran[0].Stdout != &stdout {
+		t.Errorf("Commit() did not attach the streams of the Client")
+	}
+	if want := []string{"git", "log", "-1", "--pretty=%B"}; !reflect.DeepEqual(r.ran[1].Args, want) {
+		t.Errorf("runner ran %v, want %v", r.ran[1].Args, want)
+	}
+}
+
+func TestWithFileSystem(t *testing.T) {
+	_, dir := newTempClient(t)
+	fs := &recordingFileSystem{}
+	history := filepath.Join(dir, historyFile)
+	c := New(WithFileSystem(fs), WithHistoryFile(history))
+	if err := ioutil.WriteFile(history, []byte(historyFixture), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := c.History()
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("History() got %d entries, want 3", len(entries))
+	}
+	if want := []string{history + lockSuffix, history}; !reflect.DeepEqual(fs.opened, want) {
+		t.Errorf("file system opened %v, want %v", fs.opened, want)
+	}
+}
+
+func TestWithFinder(t *testing.T) {
+	tests := []struct {
+		name    string
+		finder  *mockFinder
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Normal",
+			finder:  &mockFinder{id: 1},
+			want:    "Fix",
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseFinderReturnError",
+			finder:  &mockFinder{err: fmt.Errorf("error")},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := writeTemplateFixture(t)
+			WithFinder(tt.finder)(c)
+			got, err := c.SelectCategory()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectCategory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SelectCategory() got = %v, want %v", got, tt.want)
+			}
+			if want := []string{"Add", "Fix"}; !reflect.DeepEqual(tt.finder.items, want) {
+				t.Errorf("finder got items %v, want %v", tt.finder.items, want)
+			}
+		})
+	}
+}
+
+func TestWithSources(t *testing.T) {
+	c, _ := newTempClient(t)
+	WithSources(SourceFunc(func() ([]Candidate, error) {
+		return []Candidate{{Message: "From tracker", Origin: "tracker"}}, nil
+	}))(c)
+
+	got, err := c._samples(context.Background())
+	if err != nil {
+		t.Fatalf("samples() error = %v", err)
+	}
+	for _, candidate := range got {
+		if candidate.Message == "From tracker" {
+			return
+		}
+	}
+	t.Errorf("samples() did not include the source: %v", got)
+}
+
+func TestWithGit(t *testing.T) {
+	c, _ := newTempClient(t)
+	var committed string
+	WithGit(&fakeGit{
+		commit: func(ctx context.Context, fileName string, edit bool) error {
+			committed = fileName
+			return nil
+		},
+		headCommit: func(ctx context.Context) (string, error) {
+			if len(committed) == 0 {
+				return "", nil
+			}
+			return "a1b2c3", nil
+		},
+		headMessage: func(ctx context.Context) (string, error) {
+			return "Add hoge", nil
+		},
+	})(c)
+	selectMessage(c, "Add hoge")
+
+	if err := c.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if len(committed) == 0 {
+		t.Errorf("Commit() did not commit with the Git")
+	}
+	got, err := c.History()
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Message != "Add hoge" {
+		t.Errorf("History() got = %v, want the message read from the Git", got)
+	}
+}
+
+func TestClient_parallel(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		message := fmt.Sprintf("Add hoge %d", i)
+		t.Run(message, func(t *testing.T) {
+			t.Parallel()
+			c, _ := newTempClient(t)
+			WithClock(func() time.Time {
+				return date(1)
+			})(c)
+			c.git = &fakeGit{headMessage: func(ctx context.Context) (string, error) {
+				return message, nil
+			}}
+
+			if err := c._saveHistory(context.Background()); err != nil {
+				t.Fatalf("saveHistory() error = %v", err)
+			}
+			got, err := c.History()
+			if err != nil {
+				t.Fatalf("History() error = %v", err)
+			}
+			if want := []HistoryEntry{{Time: date(1), Message: message}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("History() got = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func (g *fakeGit) HooksDir(ctx context.Context) (string, error) {
+	if g.hooksDir != nil {
+		return g.hooksDir(ctx)
+	}
+	if g.Git != nil {
+		return g.Git.HooksDir(ctx)
+	}
+	return "", nil
+}
+
+func (g *fakeGit) Log(ctx context.Context, opts LogOptions) ([]LogEntry, error) {
+	if g.log != nil {
+		return g.log(ctx, opts)
+	}
+	if g.Git != nil {
+		return g.Git.Log(ctx, opts)
+	}
+	return nil, nil
+}
+
+func (g *fakeGit) EditorCommand(ctx context.Context) (string, error) {
+	if g.Git != nil {
+		return g.Git.EditorCommand(ctx)
+	}
+	return "vi", nil
+}
+
+func (g *fakeGit) Version(ctx context.Context) (string, error) {
+	if g.version != nil {
+		return g.version(ctx)
+	}
+	if g.Git != nil {
+		return g.Git.Version(ctx)
+	}
+	return "git version 2.30.0", nil
+}
+
+// At returns g itself, which answers for every repository.
+func (g *fakeGit) At(dir string) Git {
+	return g
+}
@@ -0,0 +1,221 @@
+package fuzzyfindmessage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	configFile           = ".fcm_config"
+	defaultSourceTimeout = 5 * time.Second
+)
+
+// Config holds the settings read from ~/.fcm_config and from the .fcm_config
+// of the repository, which fcm init writes and which may only set source
+// (file sources), finder.new_message, editor and gitmoji.
+//
+// The file consists of "key = value" lines. Blank lines and lines starting
+// with "#" are ignored and values may be wrapped in double quotes.
+//
+//	# keep at most 5000 messages, none older than a year
+//	history.max_entries = 5000
+//	history.max_age = 365d
+//	# offer the lines printed by a script, which may take 3 seconds at most
+//	source = "exec: ./scripts/commit-suggestions.sh"
+//	source.timeout = 3s
+//	# choose with fzf instead of the built-in finder
+//	finder = fzf --height 40%
+//	# commit the query as a new message when it matches nothing
+//	finder.new_message = true
+//	# edit the message in the terminal instead of the editor of git
+//	editor = inline
+//	# pick a gitmoji for messages without one, written as a glyph
+//	gitmoji = emoji
+type Config struct {
+	// HistoryMaxEntries is the number of history entries kept. 0 keeps all.
+	HistoryMaxEntries int
+	// HistoryMaxAge drops history entries older than this. 0 keeps all.
+	HistoryMaxAge time.Duration
+	// Sources are additional candidate sources, "exec: <command>" or
+	// "file: <path>". The key may be repeated.
+	Sources []string
+	// SourceTimeout bounds the run time of exec sources.
+	SourceTimeout time.Duration
+	// Finder is "builtin", "prompt" or the command line of an external
+	// finder such as fzf, sk or peco. Empty is builtin.
+	Finder string
+	// NewMessages commits the query typed into the finder as a new message
+	// when it matches no candidate.
+	NewMessages bool
+	// Editor is "git", the editor of git, or "inline", the editor of fcm.
+	// Empty is git.
+	Editor string
+	// Gitmoji is "code" or "emoji" to prefix messages with a gitmoji, as
+	// ":bug:" or as "🐛". Messages without one get one picked by the user.
+	// Empty leaves messages alone.
+	Gitmoji string
+}
+
+// Config returns the settings of the config file, or the defaults when there
+// is none.
+func (c *Client) Config() (*Config, error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+	return c.loadConfig()
+}
+
+func (c *Client) _loadConfig() (*Config, error) {
+	cfg := &Config{SourceTimeout: defaultSourceTimeout}
+	if err := c.readConfigFile(cfg, c.configFilePath, false); err != nil {
+		return nil, err
+	}
+	if path := c.repoFile(configFile); len(path) != 0 {
+		if err := c.readConfigFile(cfg, path, true); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// repoConfigKeys are the settings the .fcm_config of a repository may change.
+// Anything running commands or touching the history of the user is left to
+// ~/.fcm_config, as the repository may come from anyone.
+var repoConfigKeys = map[string]bool{
+	"source":             true,
+	"finder.new_message": true,
+	"editor":             true,
+	"gitmoji":            true,
+}
+
+// readConfigFile sets the settings of the config file at path, if it exists,
+// on cfg. repo tells that it is the .fcm_config of a repository.
+func (c *Client) readConfigFile(cfg *Config, path string, repo bool) error {
+	if !c.exists(path) {
+		return nil
+	}
+
+	file, err := c.osOpen(path)
+	if err != nil {
+		return err
+	}
+	defer c.fileClose(file)
+
+	scanner := c.bufioNewScanner(file)
+	for n := 1; c.scannerScan(scanner); n++ {
+		line := strings.TrimSpace(c.scannerText(scanner))
+		if len(line) == 0 || line[0:1] == "#" {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%s:%d: expected \"key = value\"", path, n)
+		}
+		key := strings.TrimSpace(kv[0])
+		value := strings.Trim(strings.TrimSpace(kv[1]), "\"")
+		if repo {
+			if value, err = c.repoConfigValue(path, key, value); err != nil {
+				return fmt.Errorf("%s:%d: %s", path, n, err)
+			}
+		}
+		if err := cfg.set(key, value); err != nil {
+			return fmt.Errorf("%s:%d: %s", path, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// repoConfigValue checks a setting of the .fcm_config of a repository and
+// makes the paths of file sources relative to it. File sources must stay in
+// the repository.
+func (c *Client) repoConfigValue(path, key, value string) (string, error) {
+	if !repoConfigKeys[key] {
+		return "", fmt.Errorf("%s can only be set in %s", key, c.displayPath(c.configFilePath))
+	}
+	if key != "source" {
+		return value, nil
+	}
+	kv := strings.SplitN(value, ":", 2)
+	if len(kv) != 2 || strings.TrimSpace(kv[0]) != "file" || len(strings.TrimSpace(kv[1])) == 0 {
+		return "", fmt.Errorf("only file sources can be set in a repository: %q", value)
+	}
+	file := c.resolvePath(strings.TrimSpace(kv[1]), filepath.Dir(path))
+	if err := checkInDir(file, filepath.Dir(path)); err != nil {
+		return "", err
+	}
+	return "file: " + file, nil
+}
+
+func (c *Config) set(key, value string) error {
+	switch key {
+	case "history.max_entries":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s must be a non-negative number: %q", key, value)
+		}
+		c.HistoryMaxEntries = n
+	case "history.max_age":
+		d, err := parseAge(value)
+		if err != nil {
+			return fmt.Errorf("%s: %s", key, err)
+		}
+		c.HistoryMaxAge = d
+	case "source":
+		c.Sources = append(c.Sources, value)
+	case "source.timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return fmt.Errorf("%s must be a duration such as 5s: %q", key, value)
+		}
+		c.SourceTimeout = d
+	case "finder":
+		if len(strings.Fields(value)) == 0 {
+			return fmt.Errorf("%s must be builtin, prompt or a command", key)
+		}
+		c.Finder = value
+	case "finder.new_message":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s must be true or false: %q", key, value)
+		}
+		c.NewMessages = b
+	case "editor":
+		if value != "git" && value != "inline" {
+			return fmt.Errorf("%s must be git or inline: %q", key, value)
+		}
+		c.Editor = value
+	case "gitmoji":
+		switch value {
+		case GitmojiCode, GitmojiEmoji:
+			c.Gitmoji = value
+		case "off":
+			c.Gitmoji = ""
+		default:
+			return fmt.Errorf("%s must be code, emoji or off: %q", key, value)
+		}
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	return nil
+}
+
+// parseAge accepts anything time.ParseDuration does plus a "d" suffix for days.
+func parseAge(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil || days < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,289 @@
+package fuzzyfindmessage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test__loadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "Normal",
+			content: `# comment
+history.max_entries = 5000
+
+history.max_age = "365d"
+`,
+			want: &Config{
+				HistoryMaxEntries: 5000,
+				HistoryMaxAge:     365 * 24 * time.Hour,
+				SourceTimeout:     defaultSourceTimeout,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "NormalDuration",
+			content: "history.max_age=720h\n",
+			want: &Config{
+				HistoryMaxAge: 720 * time.Hour,
+				SourceTimeout: defaultSourceTimeout,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "NormalSources",
+			content: "source = \"exec: ./scripts/suggest.sh --all\"\nsource = file: ~/tracker.txt\nsource.timeout = 3s\n",
+			want: &Config{
+				Sources:       []string{"exec: ./scripts/suggest.sh --all", "file: ~/tracker.txt"},
+				SourceTimeout: 3 * time.Second,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "NormalFinder",
+			content: "finder = fzf --height 40%\n",
+			want: &Config{
+				SourceTimeout: defaultSourceTimeout,
+				Finder:        "fzf --height 40%",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "NormalEditor",
+			content: "editor = inline\n",
+			want: &Config{
+				SourceTimeout: defaultSourceTimeout,
+				Editor:        "inline",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseUnknownEditor",
+			content: "editor = vim\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "NormalNewMessage",
+			content: "finder.new_message = true\n",
+			want: &Config{
+				SourceTimeout: defaultSourceTimeout,
+				NewMessages:   true,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseInvalidNewMessage",
+			content: "finder.new_message = sometimes\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "NormalGitmoji",
+			content: "gitmoji = emoji\n",
+			want: &Config{
+				SourceTimeout: defaultSourceTimeout,
+				Gitmoji:       GitmojiEmoji,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "NormalGitmojiOff",
+			content: "gitmoji = code\ngitmoji = off\n",
+			want: &Config{
+				SourceTimeout: defaultSourceTimeout,
+			},
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseInvalidGitmoji",
+			content: "gitmoji = yes\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseEmptyFinder",
+			content: "finder = \"\"\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseInvalidSourceTimeout",
+			content: "source.timeout = 3\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseNotKeyValue",
+			content: "history.max_entries\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseUnknownKey",
+			content: "hoge = fuga\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseNegativeMaxEntries",
+			content: "history.max_entries = -1\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseInvalidMaxAge",
+			content: "history.max_age = hoge\n",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			if err := ioutil.WriteFile(c.configFilePath, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c._loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadConfig() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test__loadConfigNoFile(t *testing.T) {
+	c, _ := newTempClient(t)
+	got, err := c._loadConfig()
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, &Config{SourceTimeout: defaultSourceTimeout}) {
+		t.Errorf("loadConfig() got = %+v, want zero config", got)
+	}
+}
+
+func TestClient_Config(t *testing.T) {
+	c, dir := newTempClient(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, configFile), []byte("editor = inline\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Config()
+	if err != nil {
+		t.Fatalf("Config() error = %v", err)
+	}
+	if want := (&Config{SourceTimeout: defaultSourceTimeout, Editor: "inline"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Config() = %+v, want %+v", got, want)
+	}
+}
+
+func Test__loadConfigRepository(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name:    "Normal",
+			content: "editor = inline\nfinder.new_message = true\nsource = \"file: docs/templates.txt\"\n",
+			want: &Config{
+				HistoryMaxEntries: 100,
+				SourceTimeout:     defaultSourceTimeout,
+				NewMessages:       true,
+				Editor:            "inline",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseExecSource",
+			content: "source = \"exec: ./suggest.sh\"\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseAbsoluteFileSource",
+			content: "source = \"file: /etc/passwd\"\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseFileSourceInHome",
+			content: "source = \"file: ~/.ssh/id_rsa\"\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseFileSourceAboveRepository",
+			content: "source = \"file: ../secret.txt\"\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseFileSourceLinksOutOfRepository",
+			content: "source = \"file: link.txt\"\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseFinderCommand",
+			content: "finder = fzf\n",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseHistorySetting",
+			content: "history.max_entries = 1\n",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newTempClient(t)
+			repo := filepath.Join(dir, "repo")
+			if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(c.configFilePath, []byte("history.max_entries = 100\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(repo, configFile), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(repo, "link.txt")); err != nil {
+				t.Fatal(err)
+			}
+			c.osGetwd = func() (string, error) {
+				return filepath.Join(repo, "sub"), nil
+			}
+
+			got, err := c._loadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want != nil {
+				tt.want.Sources = []string{"file: " + filepath.Join(repo, "docs", "templates.txt")}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadConfig() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
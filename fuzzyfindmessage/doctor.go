@@ -0,0 +1,331 @@
+package fuzzyfindmessage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// CheckStatus is the outcome of a Check.
+type CheckStatus int
+
+const (
+	// CheckOK is a check that passed.
+	CheckOK CheckStatus = iota
+	// CheckWarning is a problem fcm works around, or one that may explain
+	// missing candidates.
+	CheckWarning
+	// CheckError is a problem that keeps fcm from working.
+	CheckError
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "ok"
+	case CheckWarning:
+		return "warning"
+	case CheckError:
+		return "error"
+	}
+	return fmt.Sprintf("CheckStatus(%d)", int(s))
+}
+
+// Check is a finding of Doctor.
+type Check struct {
+	// Name is what was checked, e.g. "history file".
+	Name   string
+	Status CheckStatus
+	// Detail explains the status, e.g. with a path or a count.
+	Detail string
+}
+
+// DoctorOptions are the choices of Doctor.
+type DoctorOptions struct {
+	// RunSources runs the exec sources of the config files to count their
+	// candidates. Without it they are only listed, as they may be slow or
+	// do more than print candidates.
+	RunSources bool
+}
+
+// Doctor calls Client.Doctor with the default Client.
+func Doctor(ctx context.Context, opts DoctorOptions) []Check {
+	return std().Doctor(ctx, opts)
+}
+
+// Doctor checks the files, git and the terminal fcm relies on, e.g. to find
+// out why the list of candidates is empty. Unlike Commit, it does not create
+// the template and history files, nor the lock file of the history.
+func (c *Client) Doctor(ctx context.Context, opts DoctorOptions) []Check {
+	if err := c.resolvePaths(); err != nil {
+		return []Check{{Name: "files", Status: CheckError, Detail: err.Error()}}
+	}
+
+	var checks []Check
+	if len(c.home) == 0 {
+		checks = append(checks, Check{Name: "home", Status: CheckWarning, Detail: "no home directory, the files are in " + filepath.Dir(c.configFilePath)})
+	} else {
+		checks = append(checks, Check{Name: "home", Status: CheckOK, Detail: c.home})
+	}
+	checks = append(checks, c.checkConfig())
+	checks = append(checks, c.checkTemplates()...)
+	checks = append(checks, c.checkHistory()...)
+	checks = append(checks, c.checkCandidates(ctx, opts.RunSources)...)
+	checks = append(checks, c.checkGit(ctx)...)
+	checks = append(checks, c.checkTerminal()...)
+	return checks
+}
+
+func (c *Client) checkConfig() Check {
+	check := Check{Name: "config file", Status: CheckOK, Detail: c.configFilePath}
+	if !c.exists(c.configFilePath) {
+		check.Detail += " does not exist, the defaults apply"
+		return check
+	}
+	if _, err := c.loadConfig(); err != nil {
+		check.Status, check.Detail = CheckError, err.Error()
+	}
+	return check
+}
+
+func (c *Client) checkTemplates() []Check {
+	check := Check{Name: "template file", Status: CheckOK, Detail: c.exampleFilePath}
+	if !c.exists(c.exampleFilePath) {
+		check.Status = CheckWarning
+		check.Detail += " does not exist, fcm commit creates it with the default templates"
+		return append([]Check{check}, c.checkRepoTemplates()...)
+	}
+	return append(c.checkTemplateFile(check), c.checkRepoTemplates()...)
+}
+
+// checkRepoTemplates checks the .fcm of the repository written by fcm init.
+func (c *Client) checkRepoTemplates() []Check {
+	path := c.repoFile(exampleFile)
+	if len(path) == 0 || !c.exists(path) {
+		return nil
+	}
+	return c.checkTemplateFile(Check{Name: "repository templates", Status: CheckOK, Detail: path})
+}
+
+func (c *Client) checkTemplateFile(check Check) []Check {
+	templates, err := c.readTemplateFile(check.Detail, nil)
+	if err != nil {
+		check.Status, check.Detail = CheckError, err.Error()
+		return []Check{check}
+	}
+	if len(templates) == 0 {
+		check.Status = CheckWarning
+		check.Detail += " has no templates, every line is empty or a comment"
+		return []Check{check}
+	}
+	check.Detail += fmt.Sprintf(", %d templates", len(templates))
+
+	checks := []Check{check}
+	if n := len(templates) - len(removeDuplicateCandidates(templates)); n != 0 {
+		checks = append(checks, Check{Name: check.Name, Status: CheckWarning, Detail: fmt.Sprintf("%d templates appear more than once", n)})
+	}
+	return checks
+}
+
+func (c *Client) checkHistory() []Check {
+	check := Check{Name: "history file", Status: CheckOK, Detail: c.historyFilePath}
+	// Tell a missing file apart before opening it.
+	if _, err := c.osStat(c.historyFilePath); os.IsNotExist(err) {
+		check.Detail += " does not exist yet, it is created by the first commit"
+		return []Check{check}
+	}
+	// Keep fcm from replacing the file while it is read.
+	unlock, err := c.lockHistory(false)
+	if err != nil {
+		check.Status, check.Detail = CheckError, err.Error()
+		return []Check{check}
+	}
+	defer unlock()
+
+	file, err := c.osOpen(c.historyFilePath)
+	if err != nil {
+		check.Status, check.Detail = CheckError, err.Error()
+		return []Check{check}
+	}
+	defer c.fileClose(file)
+
+	// Read the lines that parseHistory skips silently.
+	var entries []HistoryEntry
+	malformed, header := 0, false
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		s := scanner.Text()
+		switch {
+		case len(s) == 0:
+		case s[0:1] == "#":
+			if t, _ := parseHistoryHeader(s); t.IsZero() || header {
+				malformed++
+			}
+			header = true
+		default:
+			entries = append(entries, HistoryEntry{Message: s})
+			header = false
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		check.Status, check.Detail = CheckError, fmt.Sprintf("%s: %s", c.historyFilePath, err)
+		return []Check{check}
+	}
+	check.Detail += fmt.Sprintf(", %d entries", len(entries))
+
+	checks := []Check{check}
+	if malformed != 0 {
+		checks = append(checks, Check{Name: "history file", Status: CheckWarning, Detail: fmt.Sprintf("%d comment lines are not the timestamp of a message", malformed)})
+	}
+	seen, duplicates, legacy := map[string]bool{}, 0, 0
+	for _, e := range entries {
+		if seen[e.Message] {
+			duplicates++
+		}
+		seen[e.Message] = true
+		if legacyQuoted(e.Message) {
+			legacy++
+		}
+	}
+	if duplicates != 0 {
+		checks = append(checks, Check{Name: "history file", Status: CheckWarning, Detail: fmt.Sprintf("%d entries repeat an earlier message, fcm history prune drops them", duplicates)})
+	}
+	if legacy != 0 {
+		checks = append(checks, Check{Name: "history file", Status: CheckWarning, Detail: fmt.Sprintf("%d entries are wrapped in quotes by fcm 1.0 and older, fcm doctor --fix removes them", legacy)})
+	}
+	return checks
+}
+
+// checkCandidates counts the candidates of fcm commit without creating the
+// default files as it does, counting the default templates instead. Exec
+// sources are listed rather than run unless runSources is set. A source that
+// fails is reported, as Commit skips it.
+func (c *Client) checkCandidates(ctx context.Context, runSources bool) []Check {
+	check := Check{Name: "candidates", Status: CheckOK}
+	all, err := c.sources(ctx)
+	if err != nil {
+		check.Status, check.Detail = CheckError, err.Error()
+		return []Check{check}
+	}
+
+	var candidates []Candidate
+	var others []Check
+	notRun := 0
+	for _, s := range all {
+		if f, ok := s.(FileSource); ok && f.Path == c.exampleFilePath && !c.exists(f.Path) {
+			for _, m := range defaultExamples {
+				candidates = append(candidates, Candidate{Message: m})
+			}
+			continue
+		}
+		if cmd, ok := s.(CommandSource); ok && !runSources {
+			others = append(others, Check{Name: "exec source", Status: CheckOK, Detail: cmd.commandLine() + ", not run without fcm doctor --run-sources"})
+			notRun++
+			continue
+		}
+		found, err := c.candidates(ctx, s)
+		if err != nil {
+			others = append(others, Check{Name: "source", Status: CheckError, Detail: err.Error()})
+			continue
+		}
+		candidates = append(candidates, found...)
+	}
+
+	n := len(removeDuplicateCandidates(candidates))
+	switch {
+	case n == 0 && notRun != 0:
+		check.Status, check.Detail = CheckWarning, "no messages to choose from but those of the exec sources"
+	case n == 0:
+		check.Status, check.Detail = CheckError, "the list is empty: there are no templates, no history and no other source"
+	case notRun != 0:
+		check.Detail = fmt.Sprintf("%d messages to choose from, and those of the exec sources", n)
+	default:
+		check.Detail = fmt.Sprintf("%d messages to choose from", n)
+	}
+	return append([]Check{check}, others...)
+}
+
+func (c *Client) checkGit(ctx context.Context) []Check {
+	version, err := c.git.Version(ctx)
+	if err != nil {
+		return []Check{{Name: "git", Status: CheckError, Detail: err.Error()}}
+	}
+	checks := []Check{{Name: "git", Status: CheckOK, Detail: version}}
+
+	top, err := c.git.TopLevel(ctx)
+	switch {
+	case err != nil:
+		return append(checks, Check{Name: "repository", Status: CheckError, Detail: err.Error()})
+	case len(top) == 0:
+		return append(checks, Check{Name: "repository", Status: CheckWarning, Detail: "not in a git repository, fcm commit fails here"})
+	}
+	checks = append(checks, Check{Name: "repository", Status: CheckOK, Detail: top})
+
+	for _, name := range []string{PrepareCommitMsgHook, CommitMsgHook} {
+		check := Check{Name: name + " hook", Status: CheckOK}
+		state, path, err := c.HookStatus(ctx, name)
+		switch {
+		case err != nil:
+			check.Status, check.Detail = CheckError, err.Error()
+		case state == HookForeign:
+			check.Detail = path + " was not written by fcm"
+		case state == HookInstalled:
+			check.Detail = "installed in " + path
+		default:
+			check.Detail = "not installed"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkTerminal tells whether the configured finder can run.
+func (c *Client) checkTerminal() []Check {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		// checkConfig has reported it.
+		return nil
+	}
+
+	finder := Check{Name: "finder", Status: CheckOK, Detail: cfg.Finder}
+	switch cfg.Finder {
+	case "", "builtin":
+		finder.Detail = "builtin"
+		if c.osGetenv("TERM") == "dumb" {
+			finder.Detail = "builtin, the prompt is used as TERM is dumb"
+		}
+	case "prompt":
+	default:
+		name := strings.Fields(cfg.Finder)[0]
+		if path, err := exec.LookPath(name); err != nil {
+			finder.Status, finder.Detail = CheckError, fmt.Sprintf("%s is not installed: %s", name, err)
+		} else {
+			finder.Detail = fmt.Sprintf("%s (%s)", cfg.Finder, path)
+		}
+	}
+	checks := []Check{finder}
+
+	term := Check{Name: "terminal", Status: CheckOK, Detail: "TERM=" + c.osGetenv("TERM")}
+	if f, ok := c.stdin.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+			term.Status = CheckWarning
+			term.Detail += ", the standard input is not a terminal"
+		}
+	}
+	if runtime.GOOS != "windows" {
+		// The builtin finder and the inline editor draw on /dev/tty.
+		if tty, err := c.osOpenFile("/dev/tty", os.O_RDWR, 0); err != nil {
+			term.Status = CheckWarning
+			term.Detail += fmt.Sprintf(", /dev/tty cannot be opened (%s), only the prompt finder works", err)
+		} else {
+			c.fileClose(tty)
+		}
+	}
+	return append(checks, term)
+}
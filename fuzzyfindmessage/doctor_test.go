@@ -0,0 +1,167 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestClient_Doctor(t *testing.T) {
+	type want struct {
+		name   string
+		status CheckStatus
+		detail string
+	}
+	tests := []struct {
+		name      string
+		templates string
+		history   string
+		config    string
+		opts      DoctorOptions
+		want      []want
+	}{
+		{
+			name: "NormalWithoutFiles",
+			want: []want{
+				{name: "config file", status: CheckOK, detail: "the defaults apply"},
+				{name: "template file", status: CheckWarning, detail: "does not exist"},
+				{name: "history file", status: CheckOK, detail: "does not exist yet"},
+				{name: "candidates", status: CheckOK, detail: "messages to choose from"},
+				{name: "git", status: CheckOK, detail: "git version 2.30.0"},
+				{name: "repository", status: CheckWarning, detail: "not in a git repository"},
+			},
+		},
+		{
+			name:      "NormalWithFiles",
+			templates: "Add hoge\nFix fuga\n",
+			history:   "# 2020/01/01 12:00:00\nAdd hoge\n",
+			config:    "finder = prompt\n",
+			want: []want{
+				{name: "config file", status: CheckOK, detail: configFile},
+				{name: "template file", status: CheckOK, detail: "2 templates"},
+				{name: "history file", status: CheckOK, detail: "1 entries"},
+				{name: "candidates", status: CheckOK, detail: "2 messages to choose from"},
+				{name: "finder", status: CheckOK, detail: "prompt"},
+			},
+		},
+		{
+			name:      "NormalHistoryProblems",
+			templates: "Add hoge\n",
+			history: "# 2020/01/01 12:00:00\n'Fix fuga\\n'\n" +
+				"# not a timestamp\nAdd hoge\n" +
+				"# 2020/01/03 12:00:00\nAdd hoge\n",
+			want: []want{
+				{name: "history file", status: CheckWarning, detail: "1 comment lines"},
+				{name: "history file", status: CheckWarning, detail: "1 entries repeat"},
+				{name: "history file", status: CheckWarning, detail: "1 entries are wrapped in quotes"},
+			},
+		},
+		{
+			name:      "NormalExecSourceNotRun",
+			templates: "# only a comment\n",
+			config:    "source = \"exec: echo Add hoge\"\n",
+			want: []want{
+				{name: "candidates", status: CheckWarning, detail: "but those of the exec sources"},
+				{name: "exec source", status: CheckOK, detail: "sh -c echo Add hoge, not run"},
+			},
+		},
+		{
+			name:      "NormalExecSourceRun",
+			templates: "# only a comment\n",
+			config:    "source = \"exec: echo Add hoge\"\n",
+			opts:      DoctorOptions{RunSources: true},
+			want: []want{
+				{name: "candidates", status: CheckOK, detail: "1 messages to choose from"},
+			},
+		},
+		{
+			name:      "ErrorBecauseAnExecSourceFails",
+			templates: "Add hoge\n",
+			config:    "source = \"exec: exit 1\"\n",
+			opts:      DoctorOptions{RunSources: true},
+			want: []want{
+				{name: "candidates", status: CheckOK, detail: "1 messages to choose from"},
+				{name: "source", status: CheckError, detail: "exit status 1"},
+			},
+		},
+		{
+			name:      "ErrorBecauseTheListIsEmpty",
+			templates: "# only a comment\n",
+			want: []want{
+				{name: "template file", status: CheckWarning, detail: "has no templates"},
+				{name: "candidates", status: CheckError, detail: "the list is empty"},
+			},
+		},
+		{
+			name:   "ErrorBecauseInvalidConfig",
+			config: "editor = vim\n",
+			want: []want{
+				{name: "config file", status: CheckError, detail: "vim"},
+				{name: "candidates", status: CheckError, detail: "vim"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			for path, content := range map[string]string{
+				c.exampleFilePath: tt.templates,
+				c.historyFilePath: tt.history,
+				c.configFilePath:  tt.config,
+			} {
+				if len(content) == 0 {
+					continue
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.git = &fakeGit{topLevel: func(ctx context.Context) (string, error) {
+				return "", nil
+			}}
+
+			checks := c.Doctor(context.Background(), tt.opts)
+			for _, w := range tt.want {
+				found := false
+				for _, check := range checks {
+					if check.Name == w.name && check.Status == w.status && strings.Contains(check.Detail, w.detail) {
+						found = true
+						break
+					}
+				}
+				if !found {
+					t.Errorf("Doctor() has no %s %s check with %q in %+v", w.status, w.name, w.detail, checks)
+				}
+			}
+			if tt.templates == "" && c.exists(c.exampleFilePath) {
+				t.Errorf("Doctor() created %s", c.exampleFilePath)
+			}
+			if c.exists(c.historyFilePath + lockSuffix) {
+				t.Errorf("Doctor() created %s", c.historyFilePath+lockSuffix)
+			}
+		})
+	}
+}
+
+func TestClient_checkHistory_lock(t *testing.T) {
+	c, _ := newTempClient(t)
+	var locks []bool
+	c.lockHistory = func(exclusive bool) (func() error, error) {
+		locks = append(locks, exclusive)
+		return func() error { return nil }, nil
+	}
+
+	c.checkHistory()
+	if len(locks) != 0 {
+		t.Errorf("checkHistory() took %d locks without a history file", len(locks))
+	}
+
+	if err := ioutil.WriteFile(c.historyFilePath, []byte("# 2020/01/01 12:00:00\nAdd hoge\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c.checkHistory()
+	if len(locks) != 1 || locks[0] {
+		t.Errorf("checkHistory() took the locks %v, want a shared one", locks)
+	}
+}
@@ -0,0 +1,467 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+)
+
+// Editor lets the user change the chosen message before it is committed.
+// The message has a line per line of the commit message. A Client without
+// an Editor has git open its own editor instead.
+type Editor interface {
+	Edit(ctx context.Context, message string) (string, error)
+}
+
+// WithEditor replaces the editor of git with e. The message returned by e
+// is committed as is.
+func WithEditor(e Editor) Option {
+	return func(c *Client) {
+		c.editor = e
+	}
+}
+
+// WithInlineEditor edits the message in the terminal instead of the editor
+// of git: a subject line, then an optional body.
+func WithInlineEditor() Option {
+	return func(c *Client) {
+		c.editor = inlineEditor{c: c}
+	}
+}
+
+// messageEditor returns the Editor of the Client, the one named by the editor
+// key of the config file, or nil for the editor of git.
+func (c *Client) messageEditor() (Editor, error) {
+	if c.editor != nil {
+		return c.editor, nil
+	}
+
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Editor == "inline" {
+		return inlineEditor{c: c}, nil
+	}
+	return nil, nil
+}
+
+// screen is the terminal the builtin finder and the inline editor draw on,
+// termbox unless tests say otherwise.
+type screen interface {
+	Init() error
+	Close()
+	Size() (width, height int)
+	Clear()
+	SetCell(x, y int, ch rune, fg, bg termbox.Attribute)
+	SetCursor(x, y int)
+	Flush() error
+	PollEvent() termbox.Event
+	Interrupt()
+}
+
+type termboxScreen struct{}
+
+func (termboxScreen) Init() error {
+	return termbox.Init()
+}
+
+func (termboxScreen) Close() {
+	termbox.Close()
+}
+
+func (termboxScreen) Size() (width, height int) {
+	return termbox.Size()
+}
+
+func (termboxScreen) Clear() {
+	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+}
+
+func (termboxScreen) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	termbox.SetCell(x, y, ch, fg, bg)
+}
+
+func (termboxScreen) SetCursor(x, y int) {
+	termbox.SetCursor(x, y)
+}
+
+func (termboxScreen) Flush() error {
+	return termbox.Flush()
+}
+
+func (termboxScreen) PollEvent() termbox.Event {
+	return termbox.PollEvent()
+}
+
+func (termboxScreen) Interrupt() {
+	termbox.Interrupt()
+}
+
+// pollEvents sends the events of s until stop is called. PollEvent cannot be
+// canceled, so it runs on its own until stop interrupts it.
+func pollEvents(s screen) (events <-chan termbox.Event, stop func()) {
+	ch := make(chan termbox.Event)
+	quit := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			ev := s.PollEvent()
+			if ev.Type == termbox.EventInterrupt {
+				return
+			}
+			select {
+			case ch <- ev:
+			case <-quit:
+			}
+		}
+	}()
+	return ch, func() {
+		close(quit)
+		s.Interrupt()
+		<-finished
+	}
+}
+
+// inlineEditor edits the message on the screen of the Client. Enter on the
+// subject commits; Tab moves to the body, where Enter starts a new line and
+// Ctrl-D commits. Esc and Ctrl-C abort.
+//
+// Placeholders such as {ticket} are highlighted. The first one is selected
+// when the editor opens and Tab selects the next one; typing replaces the
+// selected placeholder.
+type inlineEditor struct {
+	c *Client
+}
+
+func (e inlineEditor) Edit(ctx context.Context, message string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	s := e.c.screen
+	if err := s.Init(); err != nil {
+		return "", err
+	}
+	defer s.Close()
+	events, stop := pollEvents(s)
+	defer stop()
+
+	b := newMessageBuffer(message)
+	for {
+		if err := b.draw(s); err != nil {
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case ev := <-events:
+			switch ev.Type {
+			case termbox.EventError:
+				return "", ev.Err
+			case termbox.EventKey:
+				switch b.handle(ev) {
+				case editDone:
+					return b.message(), nil
+				case editAborted:
+					return "", ErrAborted
+				}
+			}
+		}
+	}
+}
+
+type editResult int
+
+const (
+	editing editResult = iota
+	editDone
+	editAborted
+)
+
+// messageBuffer is the message being edited. lines[0] is the subject and the
+// rest is the body. selected is the length of the placeholder at the cursor
+// that typing replaces, 0 when none is selected.
+type messageBuffer struct {
+	lines    [][]rune
+	row, col int
+	selected int
+}
+
+func newMessageBuffer(message string) *messageBuffer {
+	lines := strings.Split(strings.TrimRight(message, "\n"), "\n")
+	b := &messageBuffer{lines: [][]rune{[]rune(lines[0])}}
+	body := lines[1:]
+	for len(body) != 0 && strings.TrimSpace(body[0]) == "" {
+		body = body[1:]
+	}
+	for _, line := range body {
+		b.lines = append(b.lines, []rune(line))
+	}
+	if !b.nextPlaceholder(0, 0) {
+		b.col = len(b.lines[0])
+	}
+	return b
+}
+
+// nextPlaceholder selects the first placeholder from col of row onwards.
+func (b *messageBuffer) nextPlaceholder(row, col int) bool {
+	for ; row < len(b.lines); row, col = row+1, 0 {
+		line := string(b.lines[row])
+		offset := len(string(b.lines[row][:col]))
+		loc := placeholderPattern.FindStringIndex(line[offset:])
+		if loc == nil {
+			continue
+		}
+		b.row = row
+		b.col = len([]rune(line[:offset+loc[0]]))
+		b.selected = len([]rune(line[offset+loc[0] : offset+loc[1]]))
+		return true
+	}
+	return false
+}
+
+// message joins the subject and the body, leaving out an empty body.
+func (b *messageBuffer) message() string {
+	subject := strings.TrimSpace(string(b.lines[0]))
+	var body []string
+	for _, line := range b.lines[1:] {
+		body = append(body, strings.TrimRightFunc(string(line), unicode.IsSpace))
+	}
+	text := strings.Trim(strings.Join(body, "\n"), "\n")
+	if len(text) == 0 {
+		return subject
+	}
+	return subject + "\n\n" + text
+}
+
+func (b *messageBuffer) handle(ev termbox.Event) editResult {
+	selected := b.selected
+	b.selected = 0
+	if selected != 0 {
+		switch ev.Key {
+		case termbox.KeyBackspace, termbox.KeyBackspace2, termbox.KeyDelete:
+			b.deleteSelected(selected)
+			return editing
+		case termbox.KeySpace:
+			b.deleteSelected(selected)
+		case termbox.KeyTab, termbox.KeyArrowDown, termbox.KeyArrowUp, termbox.KeyCtrlD, termbox.KeyEnter, termbox.KeyEsc, termbox.KeyCtrlC:
+		default:
+			if ev.Key == 0 && ev.Ch != 0 {
+				b.deleteSelected(selected)
+			}
+		}
+	}
+
+	line := b.lines[b.row]
+	switch ev.Key {
+	case termbox.KeyEsc, termbox.KeyCtrlC:
+		return editAborted
+	case termbox.KeyCtrlD:
+		if len(strings.TrimSpace(string(b.lines[0]))) != 0 {
+			return editDone
+		}
+	case termbox.KeyEnter:
+		if b.row == 0 {
+			if len(strings.TrimSpace(string(line))) != 0 {
+				return editDone
+			}
+			break
+		}
+		rest := append([]rune{}, line[b.col:]...)
+		b.lines[b.row] = line[:b.col]
+		b.lines = append(b.lines[:b.row+1], append([][]rune{rest}, b.lines[b.row+1:]...)...)
+		b.row, b.col = b.row+1, 0
+	case termbox.KeyTab, termbox.KeyArrowDown:
+		if ev.Key == termbox.KeyTab && b.nextPlaceholder(b.row, b.col+selected) {
+			break
+		}
+		if b.row+1 == len(b.lines) {
+			if b.row != 0 {
+				break
+			}
+			b.lines = append(b.lines, []rune{})
+		}
+		b.moveTo(b.row + 1)
+	case termbox.KeyArrowUp:
+		if b.row != 0 {
+			b.moveTo(b.row - 1)
+		}
+	case termbox.KeyArrowLeft, termbox.KeyCtrlB:
+		if b.col != 0 {
+			b.col--
+		}
+	case termbox.KeyArrowRight, termbox.KeyCtrlF:
+		if b.col != len(line) {
+			b.col++
+		}
+	case termbox.KeyHome, termbox.KeyCtrlA:
+		b.col = 0
+	case termbox.KeyEnd, termbox.KeyCtrlE:
+		b.col = len(line)
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		switch {
+		case b.col != 0:
+			b.lines[b.row] = append(line[:b.col-1], line[b.col:]...)
+			b.col--
+		case b.row > 1:
+			// Join the line to the previous line of the body.
+			prev := b.lines[b.row-1]
+			b.lines[b.row-1] = append(prev, line...)
+			b.lines = append(b.lines[:b.row], b.lines[b.row+1:]...)
+			b.row, b.col = b.row-1, len(prev)
+		case b.row == 1 && len(line) == 0 && len(b.lines) == 2:
+			b.lines = b.lines[:1]
+			b.moveTo(0)
+		}
+	case termbox.KeyDelete:
+		switch {
+		case b.col != len(line):
+			b.lines[b.row] = append(line[:b.col], line[b.col+1:]...)
+		case b.row != 0 && b.row+1 != len(b.lines):
+			b.lines[b.row] = append(line, b.lines[b.row+1]...)
+			b.lines = append(b.lines[:b.row+1], b.lines[b.row+2:]...)
+		}
+	case termbox.KeyCtrlU:
+		b.lines[b.row] = line[b.col:]
+		b.col = 0
+	case termbox.KeyCtrlK:
+		b.lines[b.row] = line[:b.col]
+	case termbox.KeyCtrlW:
+		start := b.col
+		for start > 0 && unicode.IsSpace(line[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(line[start-1]) {
+			start--
+		}
+		b.lines[b.row] = append(line[:start], line[b.col:]...)
+		b.col = start
+	case termbox.KeySpace:
+		b.insert(' ')
+	default:
+		if ev.Ch != 0 {
+			b.insert(ev.Ch)
+		}
+	}
+	return editing
+}
+
+func (b *messageBuffer) deleteSelected(n int) {
+	line := b.lines[b.row]
+	b.lines[b.row] = append(line[:b.col], line[b.col+n:]...)
+}
+
+func (b *messageBuffer) insert(r rune) {
+	line := b.lines[b.row]
+	b.lines[b.row] = append(line[:b.col], append([]rune{r}, line[b.col:]...)...)
+	b.col++
+}
+
+// moveTo moves the cursor to row, keeping the column where possible.
+func (b *messageBuffer) moveTo(row int) {
+	b.row = row
+	if b.col > len(b.lines[row]) {
+		b.col = len(b.lines[row])
+	}
+}
+
+const (
+	subjectLabel = "Subject: "
+	bodyLabel    = "Body (Tab from the subject, Ctrl-D to commit):"
+	editorHelp   = "Enter: commit  Tab: next placeholder or body  Esc: abort"
+)
+
+// draw shows the subject on the first line and the body below it. Lines
+// longer than the screen scroll horizontally with the cursor.
+func (b *messageBuffer) draw(s screen) error {
+	width, height := s.Size()
+	s.Clear()
+
+	drawText(s, 0, 0, width, []rune(subjectLabel), -1, termbox.AttrBold)
+	x := runewidth.StringWidth(subjectLabel)
+	cursorX, cursorY := b.drawLine(s, x, 0, width, 0), 0
+
+	if len(b.lines) > 1 || b.row != 0 {
+		drawText(s, 0, 1, width, []rune(bodyLabel), -1, termbox.AttrBold)
+	}
+	// Keep the row of the cursor on the screen above the help line.
+	rows := height - 3
+	first := 1
+	if rows > 0 && b.row >= first+rows {
+		first = b.row - rows + 1
+	}
+	for i := first; i < len(b.lines) && i-first < rows; i++ {
+		if cx := b.drawLine(s, 0, 2+i-first, width, i); i == b.row {
+			cursorX, cursorY = cx, 2+i-first
+		}
+	}
+	if height > 1 {
+		drawText(s, 0, height-1, width, []rune(editorHelp), -1, termbox.ColorDefault)
+	}
+
+	s.SetCursor(cursorX, cursorY)
+	return s.Flush()
+}
+
+// drawLine draws the row-th line with its placeholders highlighted and the
+// selected one reversed.
+func (b *messageBuffer) drawLine(s screen, x, y, width, row int) int {
+	line := b.lines[row]
+	fg := make([]termbox.Attribute, len(line))
+	for i := range fg {
+		fg[i] = termbox.ColorDefault
+	}
+	text := string(line)
+	for _, loc := range placeholderPattern.FindAllStringIndex(text, -1) {
+		for i := len([]rune(text[:loc[0]])); i < len([]rune(text[:loc[1]])); i++ {
+			fg[i] = termbox.ColorCyan | termbox.AttrBold
+		}
+	}
+
+	col := -1
+	if row == b.row {
+		col = b.col
+		for i := b.col; i < b.col+b.selected; i++ {
+			fg[i] |= termbox.AttrReverse
+		}
+	}
+	return drawAttrText(s, x, y, width, line, col, fg)
+}
+
+// drawText draws text from x to the right edge and returns the screen column
+// of the rune at col. When col is not visible the text is scrolled.
+func drawText(s screen, x, y, width int, text []rune, col int, fg termbox.Attribute) int {
+	attrs := make([]termbox.Attribute, len(text))
+	for i := range attrs {
+		attrs[i] = fg
+	}
+	return drawAttrText(s, x, y, width, text, col, attrs)
+}
+
+// drawAttrText is drawText with an attribute for each rune.
+func drawAttrText(s screen, x, y, width int, text []rune, col int, fg []termbox.Attribute) int {
+	offset := 0
+	if col >= 0 {
+		avail := width - x - 1
+		for avail > 0 && runewidth.StringWidth(string(text[offset:col])) > avail {
+			offset++
+		}
+	}
+
+	cursor := x
+	for i := offset; i < len(text) && x < width; i++ {
+		if i == col {
+			cursor = x
+		}
+		s.SetCell(x, y, text[i], fg[i], termbox.ColorDefault)
+		x += runewidth.RuneWidth(text[i])
+	}
+	if col >= len(text) {
+		cursor = x
+	}
+	return cursor
+}
@@ -0,0 +1,360 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	"github.com/nsf/termbox-go"
+)
+
+// fakeScreen replays events and remembers what was drawn last. When set,
+// flushed is told about the drawings it has room for.
+type fakeScreen struct {
+	width, height int
+	events        chan termbox.Event
+	cells         map[[2]int]rune
+	attrs         map[[2]int]termbox.Attribute
+	closed        bool
+	flushed       chan struct{}
+}
+
+func newFakeScreen(events ...termbox.Event) *fakeScreen {
+	s := &fakeScreen{width: 80, height: 10, events: make(chan termbox.Event, len(events)+1)}
+	for _, ev := range events {
+		s.events <- ev
+	}
+	return s
+}
+
+func (s *fakeScreen) Init() error {
+	return nil
+}
+
+func (s *fakeScreen) Close() {
+	s.closed = true
+}
+
+func (s *fakeScreen) Size() (width, height int) {
+	return s.width, s.height
+}
+
+func (s *fakeScreen) Clear() {
+	s.cells = map[[2]int]rune{}
+	s.attrs = map[[2]int]termbox.Attribute{}
+}
+
+func (s *fakeScreen) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	s.cells[[2]int{x, y}] = ch
+	s.attrs[[2]int{x, y}] = fg
+}
+
+func (s *fakeScreen) SetCursor(x, y int) {
+}
+
+func (s *fakeScreen) Flush() error {
+	select {
+	case s.flushed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *fakeScreen) PollEvent() termbox.Event {
+	return <-s.events
+}
+
+func (s *fakeScreen) Interrupt() {
+	s.events <- termbox.Event{Type: termbox.EventInterrupt}
+}
+
+// line returns the text drawn on row y.
+func (s *fakeScreen) line(y int) string {
+	var b strings.Builder
+	for x := 0; x < s.width; x++ {
+		if ch, ok := s.cells[[2]int{x, y}]; ok {
+			b.WriteRune(ch)
+		}
+	}
+	return b.String()
+}
+
+func keys(text string, special ...termbox.Key) []termbox.Event {
+	var events []termbox.Event
+	for _, r := range text {
+		events = append(events, termbox.Event{Type: termbox.EventKey, Ch: r})
+	}
+	for _, k := range special {
+		events = append(events, termbox.Event{Type: termbox.EventKey, Key: k})
+	}
+	return events
+}
+
+func Test_messageBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		events  []termbox.Event
+		want    string
+		result  editResult
+	}{
+		{
+			name:    "Normal",
+			message: "Fix {ticket}: ",
+			events:  keys("PROJ-1", termbox.KeyEnd),
+			want:    "Fix PROJ-1:",
+			result:  editing,
+		},
+		{
+			name:    "NormalNextPlaceholder",
+			message: "feat({scope}): {summary}",
+			events:  append(append(keys("editor", termbox.KeyTab), keys("add a key")...), keys("", termbox.KeyEnter)...),
+			want:    "feat(editor): add a key",
+			result:  editDone,
+		},
+		{
+			name:    "NormalKeepPlaceholder",
+			message: "Fix {ticket}",
+			events:  append(keys("", termbox.KeyArrowLeft), keys("x", termbox.KeyEnter)...),
+			want:    "Fixx {ticket}",
+			result:  editDone,
+		},
+		{
+			name:    "NormalDeletePlaceholder",
+			message: "Fix {ticket} in {file}",
+			events:  keys("", termbox.KeyBackspace2, termbox.KeyEnter),
+			want:    "Fix  in {file}",
+			result:  editDone,
+		},
+		{
+			name:    "NormalPlaceholderInBody",
+			message: "Fix login\n\nRefs {ticket}",
+			events:  keys("PROJ-1", termbox.KeyCtrlD),
+			want:    "Fix login\n\nRefs PROJ-1",
+			result:  editDone,
+		},
+		{
+			name:    "NormalCursorMovement",
+			message: "Fix  login",
+			events: append(append(keys("", termbox.KeyHome, termbox.KeyArrowRight, termbox.KeyArrowRight, termbox.KeyArrowRight, termbox.KeyArrowRight),
+				keys("the")...), keys("", termbox.KeyEnd, termbox.KeyBackspace2, termbox.KeyEnter)...),
+			want:   "Fix the logi",
+			result: editDone,
+		},
+		{
+			name:    "NormalBody",
+			message: "Add hoge",
+			events: append(append(keys("", termbox.KeyTab), keys("first")...),
+				append(keys("", termbox.KeyEnter), keys("second", termbox.KeyCtrlD)...)...),
+			want:   "Add hoge\n\nfirst\nsecond",
+			result: editDone,
+		},
+		{
+			name:    "NormalKeepBody",
+			message: "Add hoge\n\nWith a body\n",
+			events:  keys("!", termbox.KeyEnter),
+			want:    "Add hoge!\n\nWith a body",
+			result:  editDone,
+		},
+		{
+			name:    "NormalEmptyBodyDropped",
+			message: "Add hoge",
+			events:  keys("", termbox.KeyTab, termbox.KeyArrowUp, termbox.KeyEnter),
+			want:    "Add hoge",
+			result:  editDone,
+		},
+		{
+			name:    "NormalJoinBodyLines",
+			message: "Add hoge\n\nfirst\nsecond",
+			events:  keys("", termbox.KeyTab, termbox.KeyArrowDown, termbox.KeyHome, termbox.KeyBackspace2, termbox.KeyCtrlD),
+			want:    "Add hoge\n\nfirstsecond",
+			result:  editDone,
+		},
+		{
+			name:    "NormalKillWord",
+			message: "Add hoge fuga",
+			events:  keys("", termbox.KeyCtrlW, termbox.KeyEnter),
+			want:    "Add hoge",
+			result:  editDone,
+		},
+		{
+			name:    "NormalKillLine",
+			message: "Add hoge",
+			events:  append(keys("", termbox.KeyCtrlU), keys("Fix fuga", termbox.KeyEnter)...),
+			want:    "Fix fuga",
+			result:  editDone,
+		},
+		{
+			name:    "NormalEmptySubjectNotCommitted",
+			message: "Add hoge",
+			events:  keys("", termbox.KeyCtrlU, termbox.KeyEnter, termbox.KeyCtrlD),
+			want:    "",
+			result:  editing,
+		},
+		{
+			name:    "Aborted",
+			message: "Add hoge",
+			events:  keys("", termbox.KeyEsc),
+			want:    "Add hoge",
+			result:  editAborted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newMessageBuffer(tt.message)
+			result := editing
+			for _, ev := range tt.events {
+				if result = b.handle(ev); result != editing {
+					break
+				}
+			}
+			if result != tt.result {
+				t.Errorf("handle() = %v, want %v", result, tt.result)
+			}
+			if got := b.message(); got != tt.want {
+				t.Errorf("message() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_inlineEditorEdit(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []termbox.Event
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "Normal",
+			events:  keys("PROJ-1", termbox.KeyEnter),
+			want:    "Fix PROJ-1",
+			wantErr: nil,
+		},
+		{
+			name:    "ErrorBecauseAborted",
+			events:  keys("", termbox.KeyCtrlC),
+			want:    "",
+			wantErr: fuzzyfinder.ErrAbort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeScreen(tt.events...)
+			c := New(WithInlineEditor())
+			c.screen = s
+			got, err := c.editor.Edit(context.Background(), "Fix ")
+			if err != tt.wantErr {
+				t.Fatalf("Edit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Edit() got = %q, want %q", got, tt.want)
+			}
+			if !s.closed {
+				t.Errorf("Edit() did not close the screen")
+			}
+		})
+	}
+}
+
+func Test_inlineEditorEditCanceled(t *testing.T) {
+	s := newFakeScreen()
+	c := New(WithInlineEditor())
+	c.screen = s
+	ctx, cancel := context.WithCancel(context.Background())
+	go cancel()
+	if _, err := c.editor.Edit(ctx, "Fix "); err != context.Canceled {
+		t.Errorf("Edit() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func Test_messageBufferDraw(t *testing.T) {
+	s := newFakeScreen()
+	b := newMessageBuffer("Add a subject that is longer than the screen, which is only eighty columns wide\n\nWith a body")
+	if err := b.draw(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.line(0); !strings.HasPrefix(got, subjectLabel) || !strings.HasSuffix(got, "columns wide") {
+		t.Errorf("draw() subject = %q, want it scrolled to the cursor", got)
+	}
+	if got, want := s.line(2), "With a body"; got != want {
+		t.Errorf("draw() body = %q, want %q", got, want)
+	}
+	if got := s.line(s.height - 1); got != editorHelp {
+		t.Errorf("draw() help = %q, want %q", got, editorHelp)
+	}
+}
+
+func Test_messageEditor(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []Option
+		config string
+		want   reflect.Type
+	}{
+		{
+			name:   "NormalGit",
+			config: "",
+			want:   nil,
+		},
+		{
+			name:   "NormalInlineConfig",
+			config: "editor = inline\n",
+			want:   reflect.TypeOf(inlineEditor{}),
+		},
+		{
+			name:   "NormalOption",
+			opts:   []Option{WithInlineEditor()},
+			config: "editor = git\n",
+			want:   reflect.TypeOf(inlineEditor{}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			for _, opt := range tt.opts {
+				opt(c)
+			}
+			if err := ioutil.WriteFile(c.configFilePath, []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := c.messageEditor()
+			if err != nil {
+				t.Fatalf("messageEditor() error = %v", err)
+			}
+			if reflect.TypeOf(got) != tt.want {
+				t.Errorf("messageEditor() got = %T, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_messageBufferDrawPlaceholders(t *testing.T) {
+	s := newFakeScreen()
+	b := newMessageBuffer("Fix {ticket} in {file}")
+	if err := b.draw(s); err != nil {
+		t.Fatal(err)
+	}
+
+	x := len(subjectLabel)
+	tests := []struct {
+		name string
+		x    int
+		want termbox.Attribute
+	}{
+		{name: "Text", x: x, want: termbox.ColorDefault},
+		{name: "Selected", x: x + len("Fix "), want: termbox.ColorCyan | termbox.AttrBold | termbox.AttrReverse},
+		{name: "Placeholder", x: x + len("Fix {ticket} in "), want: termbox.ColorCyan | termbox.AttrBold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.attrs[[2]int{tt.x, 0}]; got != tt.want {
+				t.Errorf("draw() attribute at %d = %v, want %v", tt.x, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package fuzzyfindmessage
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+)
+
+var (
+	// ErrAborted is returned when the user leaves a finder or the inline
+	// editor without choosing, or empties the message in the editor of git.
+	// It is the error of go-fuzzyfinder, so code comparing against
+	// fuzzyfinder.ErrAbort keeps working.
+	ErrAborted = fuzzyfinder.ErrAbort
+	// ErrNothingStaged is returned by Commit when nothing is staged, before
+	// the finder opens, or when git refused to commit for that reason.
+	ErrNothingStaged = errors.New("nothing staged to commit; use git add first")
+	// ErrNotARepo is returned by Commit outside of a git repository.
+	ErrNotARepo = errors.New("not a git repository")
+)
+
+// GitError is returned when git exits with a failure.
+type GitError struct {
+	// Args are the arguments git was run with.
+	Args []string
+	// ExitCode is the exit status of git.
+	ExitCode int
+	// Err is the *exec.ExitError.
+	Err error
+}
+
+func (e *GitError) Error() string {
+	return fmt.Sprintf("git %s: exit status %d", strings.Join(e.Args, " "), e.ExitCode)
+}
+
+// Unwrap returns Err.
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+// gitError wraps err in a GitError when git exited with a failure, and
+// returns it unchanged otherwise.
+func gitError(args []string, err error) error {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return err
+	}
+	return &GitError{Args: args, ExitCode: exitErr.ExitCode(), Err: exitErr}
+}
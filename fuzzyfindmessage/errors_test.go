@@ -0,0 +1,56 @@
+package fuzzyfindmessage
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func Test_gitError(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	if _, ok := exitErr.(*exec.ExitError); !ok {
+		t.Skipf("sh did not fail: %v", exitErr)
+	}
+	other := errors.New("hoge")
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{
+			name: "NormalExitError",
+			err:  exitErr,
+			want: &GitError{Args: []string{"commit"}, ExitCode: 3, Err: exitErr},
+		},
+		{
+			name: "NormalOtherError",
+			err:  other,
+			want: other,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gitError([]string{"commit"}, tt.err); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("gitError() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitError(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	err := gitError([]string{"commit", "-F", "msg"}, exitErr)
+
+	if want := "git commit -F msg: exit status 3"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	var gitErr *GitError
+	if !errors.As(err, &gitErr) || gitErr.ExitCode != 3 {
+		t.Errorf("errors.As() = %#v", gitErr)
+	}
+	if !errors.Is(err, exitErr) {
+		t.Errorf("errors.Is() = false, want the *exec.ExitError")
+	}
+}
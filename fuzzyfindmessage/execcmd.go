@@ -1,33 +1,19 @@
 package fuzzyfindmessage
 
 import (
-	"os"
-	"os/exec"
+	"context"
 )
 
-var (
-	execCommand = exec.Command
-	commandRun  = func(c *exec.Cmd) error {
-		return c.Run()
-	}
-	commandOutput = func(c *exec.Cmd) ([]byte, error) {
-		return c.Output()
-	}
-)
-
-func _gitCommit(fileName string) error {
-	c := execCommand("git", "commit", "-F", fileName, "-e")
-	c.Stdin = os.Stdin
-	c.Stdout = os.Stdout
-	c.Stderr = os.Stderr
-	return commandRun(c)
-}
-
-func _lastCommitMessage() (string, error) {
-	c := execCommand("git", "log", "-1", "--pretty='%B'")
-	out, err := commandOutput(c)
+func (c *Client) _runEditor(ctx context.Context, fileName string) error {
+	editor, err := c.git.EditorCommand(ctx)
 	if err != nil {
-		return "", err
+		return err
 	}
-	return string(out), nil
+
+	// Like git, let the shell split the editor so that arguments work.
+	cmd := c.execCommandContext(ctx, "sh", "-c", editor+` "$@"`, editor, fileName)
+	cmd.Stdin = c.stdin
+	cmd.Stdout = c.stdout
+	cmd.Stderr = c.stderr
+	return c.commandRun(cmd)
 }
@@ -1,95 +1,68 @@
 package fuzzyfindmessage
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
+	"reflect"
 	"testing"
 )
 
-func Test__gitCommit(t *testing.T) {
+func Test__runEditor(t *testing.T) {
+	c := New()
 	tests := []struct {
-		name        string
-		execCommand func(name string, arg ...string) *exec.Cmd
-		commandRun  func(c *exec.Cmd) error
-		fileName    string
-		wantErr     bool
+		name          string
+		commandOutput func(cmd *exec.Cmd) ([]byte, error)
+		commandRun    func(cmd *exec.Cmd) error
+		wantArgs      []string
+		wantErr       bool
 	}{
 		{
 			name: "Normal",
-			execCommand: func(name string, arg ...string) *exec.Cmd {
-				return &exec.Cmd{}
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("vim -f\n"), nil
 			},
-			commandRun: func(c *exec.Cmd) error {
+			commandRun: func(cmd *exec.Cmd) error {
 				return nil
 			},
-			fileName: "hoge",
+			wantArgs: []string{"sh", "-c", `vim -f "$@"`, "vim -f", "hoge"},
 			wantErr:  false,
 		},
 		{
-			name: "ErrorBecauseCommandReturnError",
-			execCommand: func(name string, arg ...string) *exec.Cmd {
-				return &exec.Cmd{}
-			},
-			commandRun: func(c *exec.Cmd) error {
-				return fmt.Errorf("error")
-			},
-			fileName: "hoge",
-			wantErr:  true,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			execCommand = tt.execCommand
-			commandRun = tt.commandRun
-			if err := _gitCommit(tt.fileName); (err != nil) != tt.wantErr {
-				t.Errorf("gitCommit() error = %v, wantErr %v", err, tt.wantErr)
-			}
-		})
-	}
-}
-
-func Test__lastCommitMessage(t *testing.T) {
-	tests := []struct {
-		name          string
-		execCommand   func(name string, arg ...string) *exec.Cmd
-		commandOutput func(c *exec.Cmd) ([]byte, error)
-		want          string
-		wantErr       bool
-	}{
-		{
-			name: "Normal",
-			execCommand: func(name string, arg ...string) *exec.Cmd {
-				return &exec.Cmd{}
-			},
-			commandOutput: func(c *exec.Cmd) ([]byte, error) {
-				return []byte("hoge"), nil
+			name: "ErrorBecauseGitVarReturnError",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, fmt.Errorf("error")
 			},
-			want:    "hoge",
-			wantErr: false,
+			commandRun: nil,
+			wantArgs:   []string{"git", "var", "GIT_EDITOR"},
+			wantErr:    true,
 		},
 		{
-			name: "ErrorBecauseCommandReturnError",
-			execCommand: func(name string, arg ...string) *exec.Cmd {
-				return &exec.Cmd{}
+			name: "ErrorBecauseEditorReturnError",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("vim"), nil
 			},
-			commandOutput: func(c *exec.Cmd) ([]byte, error) {
-				return []byte(""), fmt.Errorf("error")
+			commandRun: func(cmd *exec.Cmd) error {
+				return fmt.Errorf("error")
 			},
-			want:    "",
-			wantErr: true,
+			wantArgs: []string{"sh", "-c", `vim "$@"`, "vim", "hoge"},
+			wantErr:  true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			execCommand = tt.execCommand
-			commandOutput = tt.commandOutput
-			got, err := _lastCommitMessage()
-			if (err != nil) != tt.wantErr {
-				t.Errorf("lastCommitMessage() error = %v, wantErr %v", err, tt.wantErr)
-				return
+			var gotArgs []string
+			c.execCommandContext = func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				gotArgs = append([]string{name}, arg...)
+				return &exec.Cmd{}
+			}
+			c.commandOutput = tt.commandOutput
+			c.commandRun = tt.commandRun
+			if err := c._runEditor(context.Background(), "hoge"); (err != nil) != tt.wantErr {
+				t.Errorf("runEditor() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			if got != tt.want {
-				t.Errorf("lastCommitMessage() got = %v, want %v", got, tt.want)
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("runEditor() ran %v, want %v", gotArgs, tt.wantArgs)
 			}
 		})
 	}
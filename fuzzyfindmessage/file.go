@@ -3,17 +3,11 @@
 package fuzzyfindmessage
 
 import (
-	"bufio"
+	"context"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"os"
-	"os/user"
+	"path/filepath"
 	"sort"
-	"strings"
-	"time"
-
-	"github.com/ktr0731/go-fuzzyfinder"
 )
 
 const (
@@ -21,218 +15,285 @@ const (
 	historyFile = ".fcm_history"
 )
 
-var (
-	osOpen               func(name string) (*os.File, error)
-	osOpenFile           func(name string, flag int, perm os.FileMode) (*os.File, error)
-	osCreate             func(name string) (*os.File, error)
-	osStat               func(name string) (os.FileInfo, error)
-	osRemove             func(name string) error
-	bufioNewScanner      func(r io.Reader) *bufio.Scanner
-	fmtFprintf           func(w io.Writer, format string, a ...interface{}) (n int, err error)
-	fmtFprintln          func(w io.Writer, a ...interface{}) (n int, err error)
-	ioutilTempFile       func(dir, pattern string) (f *os.File, err error)
-	fileWrite            func(file *os.File, b []byte) (n int, err error)
-	fileClose            func(file *os.File) error
-	scannerScan          func(scanner *bufio.Scanner) bool
-	scannerText          func(scanner *bufio.Scanner) string
-	newCurrentUser       func() *user.User
-	home                 string
-	userCurrent          func() (*user.User, error)
-	exampleFilePath      string
-	historyFilePath      string
-	samples              func() ([]string, error)
-	saveHistory          func() (err error)
-	createTemplate       func(message string) (f *os.File, err error)
-	createDefaultFile    func(filePath string) error
-	removeDuplicate      func(slice []string) []string
-	exists               func(filename string) bool
-	createEmptyHistory   func() (err error)
-	createDefaultExample func() (err error)
-	lastCommitMessage    func() (string, error)
-	gitCommit            func(fileName string) error
-	fuzzyfinderFind      func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error)
-	tmpFileName          func(f *os.File) string
-)
+// Commit calls Client.Commit with the default Client.
+func Commit() error {
+	return std().Commit()
+}
 
-func init() {
-	osOpen = os.Open
-	osOpenFile = os.OpenFile
-	osCreate = os.Create
-	osStat = os.Stat
-	osRemove = os.Remove
-	bufioNewScanner = bufio.NewScanner
-	fmtFprintf = fmt.Fprintf
-	fmtFprintln = fmt.Fprintln
-	ioutilTempFile = ioutil.TempFile
-	fileWrite = func(file *os.File, b []byte) (n int, err error) {
-		return file.Write(b)
-	}
-	fileClose = func(file *os.File) error {
-		return file.Close()
-	}
-	scannerScan = func(scanner *bufio.Scanner) bool {
-		return scanner.Scan()
-	}
-	scannerText = func(scanner *bufio.Scanner) string {
-		return scanner.Text()
-	}
-	userCurrent = user.Current
-	newCurrentUser = _newCurrentUser
-	home = newCurrentUser().HomeDir
-	exampleFilePath = home + "/" + exampleFile
-	historyFilePath = home + "/" + historyFile
-	samples = _samples
-	saveHistory = _saveHistory
-	createTemplate = _createTemplate
-	createDefaultFile = _createDefaultFile
-	removeDuplicate = _removeDuplicate
-	exists = _exists
-	createEmptyHistory = _createEmptyHistory
-	createDefaultExample = _createDefaultExample
-	lastCommitMessage = _lastCommitMessage
-	gitCommit = _gitCommit
-	fuzzyfinderFind = fuzzyfinder.Find
-	tmpFileName = func(f *os.File) string {
-		return f.Name()
-	}
+// CommitContext calls Client.CommitContext with the default Client.
+func CommitContext(ctx context.Context) error {
+	return std().CommitContext(ctx)
 }
 
 // Commit wraps Git Commit.
 // You can perform a fuzzy search from a message template and commit the result.
-func Commit() (err error) {
-	samples, err := samples()
+// With a finder that is a Selector, ctrl-e instead of Enter edits the chosen
+// message in the inline editor and commits it without the editor of git, and
+// ctrl-t promotes the chosen history entry into the template file.
+// With WithNewMessages, a query matching no candidate is committed instead.
+func (c *Client) Commit() error {
+	return c.CommitContext(context.Background())
+}
+
+// CommitContext is Commit with a context. Canceling ctx stops loading the
+// candidates and kills git and the commands of exec sources. A canceled
+// commit returns ctx.Err(), one the user left returns ErrAborted.
+// ErrNotARepo, ErrNothingStaged and *GitError tell the failures of git apart.
+func (c *Client) CommitContext(ctx context.Context) (err error) {
+	if err := c.resolvePaths(); err != nil {
+		return err
+	}
+	if top, err := c.git.TopLevel(ctx); err != nil {
+		return err
+	} else if len(top) == 0 {
+		return ErrNotARepo
+	}
+	// Tell it before the user chooses a message rather than after.
+	if staged, err := c.git.HasStagedChanges(ctx); err != nil {
+		return err
+	} else if !staged {
+		return ErrNothingStaged
+	}
+	message, key, err := c.chooseMessage(ctx, []string{editKey, promoteKey})
 	if err != nil {
 		return err
 	}
 
-	id, err := fuzzyfinderFind(
-		samples,
-		func(i int) string {
-			return samples[i]
-		},
-		fuzzyfinder.WithPreviewWindow(func(i, w, h int) string {
-			if i == -1 {
-				return ""
-			}
-			return fmt.Sprintln(samples[i])
-		}))
+	editor, err := c.messageEditor()
 	if err != nil {
 		return err
 	}
+	if key == editKey {
+		editor = inlineEditor{c: c}
+	}
+	if editor != nil {
+		// Candidates keep their newlines escaped, as in the history, and so
+		// does createTemplate expect them, but the editor shows the lines.
+		edited, err := editor.Edit(ctx, unescapeMessage(message))
+		if err != nil {
+			return err
+		}
+		message = escapeMessage(edited)
+	}
 
-	f, err := createTemplate(samples[id])
+	f, err := c.createTemplate(message)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		if err == nil {
-			err = osRemove(tmpFileName(f))
+			err = c.osRemove(c.tmpFileName(f))
 		}
-		osRemove(tmpFileName(f))
+		c.osRemove(c.tmpFileName(f))
 	}()
 
-	if err := gitCommit(tmpFileName(f)); err != nil {
+	before, err := c.git.HeadCommit(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.git.Commit(ctx, c.tmpFileName(f), editor == nil); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// git commit fails the same way whatever the reason, so tell the
+		// common one apart.
+		if staged, serr := c.git.HasStagedChanges(ctx); serr == nil && !staged {
+			return ErrNothingStaged
+		}
 		return err
 	}
 
-	if err := saveHistory(); err != nil {
+	// git can exit successfully without creating a commit (e.g. a hook that
+	// swallows it), and HEAD would then still hold the previous message.
+	after, err := c.git.HeadCommit(ctx)
+	if err != nil {
 		return err
 	}
+	if after == "" || after == before || c.noHistory {
+		return nil
+	}
+
+	if err := c.saveHistory(ctx); err != nil {
+		return err
+	}
+
+	// The commit is made and recorded, so a history that cannot be pruned
+	// only waits for the next one.
+	if _, err := c.pruneHistory(false); err != nil {
+		c.fmtFprintf(c.stderr, "Could not prune the history: %s\n", err)
+	}
 
 	return nil
 }
 
-func _createTemplate(message string) (f *os.File, err error) {
-	f, err = ioutilTempFile("", "template")
+// chooseMessage lets the user choose a candidate, or type a new message when
+// new messages are enabled, and returns it with the key that chose it. With
+// the gitmoji setting, the user picks a gitmoji for it next. promoteKey among
+// keys promotes the chosen history entry and lets the user choose again.
+func (c *Client) chooseMessage(ctx context.Context, keys []string) (message, key string, err error) {
+	for {
+		candidates, err := c.samples(ctx)
+		if err != nil {
+			return "", "", err
+		}
+
+		items := make([]string, len(candidates))
+		for i := range candidates {
+			items[i] = candidates[i].Message
+		}
+		allowQuery, err := c.allowNewMessages()
+		if err != nil {
+			return "", "", err
+		}
+		selection, err := selectItem(ctx, c.finder, items, func(i int) string {
+			if i < 0 {
+				return ""
+			}
+			return candidates[i].preview()
+		}, SelectOptions{Keys: keys, AllowQuery: allowQuery})
+		if err != nil {
+			return "", "", err
+		}
+
+		if selection.Key == promoteKey {
+			if err := c.promoteCandidate(candidates, selection.Index); err != nil {
+				return "", "", err
+			}
+			continue
+		}
+
+		message = selection.Query
+		if selection.Index >= 0 {
+			message = candidates[selection.Index].Message
+		}
+		if message, err = c.addGitmoji(ctx, message); err != nil {
+			return "", "", err
+		}
+		return message, selection.Key, nil
+	}
+}
+
+// promoteCandidate promotes the i-th candidate, which must come from the
+// history, into the template file under a category the user picks. Leaving
+// the category finder promotes nothing.
+func (c *Client) promoteCandidate(candidates []Candidate, i int) error {
+	if i < 0 || !c.fromHistory(candidates[i]) {
+		c.fmtFprintf(c.stderr, "Only entries of the history can be promoted.\n")
+		return nil
+	}
+	category, err := c.SelectCategory()
+	if err == ErrAborted {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = c.PromoteMessage(candidates[i].Message, category, false)
+	return err
+}
+
+func (c *Client) _createTemplate(message string) (f *os.File, err error) {
+	f, err = c.ioutilTempFile("", "template")
 	if err != nil {
 		return nil, err
 	}
 	defer func() {
 		if err == nil {
-			err = fileClose(f)
+			err = c.fileClose(f)
 		}
-		fileClose(f)
+		c.fileClose(f)
 	}()
 
-	message = strings.ReplaceAll(message, "\\n", "\n")
+	message = unescapeMessage(message)
 
-	if _, err := fileWrite(f, []byte(message)); err != nil {
+	if _, err := c.fileWrite(f, []byte(message)); err != nil {
 		return nil, err
 	}
 
 	return f, nil
 }
 
-func _samples() ([]string, error) {
-	if err := createDefaultFile(exampleFilePath); err != nil {
+// Samples returns the candidates offered by Commit, sorted and without
+// duplicates. A source that fails is skipped with a warning on stderr.
+func (c *Client) Samples(ctx context.Context) ([]Candidate, error) {
+	if err := c.resolvePaths(); err != nil {
 		return nil, err
 	}
+	return c.samples(ctx)
+}
 
-	if err := createDefaultFile(historyFilePath); err != nil {
+func (c *Client) _samples(ctx context.Context) ([]Candidate, error) {
+	if err := c.createDefaultFile(c.exampleFilePath); err != nil {
 		return nil, err
 	}
 
-	var samples []string
-	samplesFile, err := osOpen(exampleFilePath)
-	if err != nil {
+	if err := c.createDefaultFile(c.historyFilePath); err != nil {
 		return nil, err
 	}
-	defer fileClose(samplesFile)
-	historyFile, err := osOpen(historyFilePath)
+
+	all, err := c.sources(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err == nil {
-			err = fileClose(historyFile)
-			return
-		}
-		fileClose(historyFile)
-	}()
 
-	scanner := bufioNewScanner(io.MultiReader(samplesFile, historyFile))
-	for scannerScan(scanner) {
-		s := scannerText(scanner)
-		if len(s) == 0 {
-			continue
+	// A broken or slow source must not keep the user from committing, so
+	// it is skipped with a warning unless ctx itself is done.
+	var candidates []Candidate
+	for _, s := range all {
+		found, err := c.candidates(ctx, s)
+		if err := ctx.Err(); err != nil {
+			return nil, err
 		}
-
-		if s[0:1] == "#" {
+		if err != nil {
+			c.fmtFprintf(c.stderr, "Skipped a source: %s\n", err)
 			continue
 		}
-		samples = append(samples, s)
+		candidates = append(candidates, found...)
 	}
 
-	samples = removeDuplicate(samples)
+	candidates = removeDuplicateCandidates(candidates)
 
-	sort.Slice(samples, func(i, j int) bool {
-		return samples[i] > samples[j]
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].Message > candidates[j].Message
 	})
 
-	return samples, nil
+	return candidates, nil
 }
 
-func _saveHistory() (err error) {
-	history, err := lastCommitMessage()
+func (c *Client) _saveHistory(ctx context.Context) (err error) {
+	history, err := c.git.HeadMessage(ctx)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := c.lockHistory(true)
 	if err != nil {
 		return err
 	}
+	defer func() {
+		if err == nil {
+			err = unlock()
+			return
+		}
+		unlock()
+	}()
 
-	file, err := osOpenFile(historyFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	file, err := c.osOpenFile(c.historyFilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		if err == nil {
-			err = fileClose(file)
+			err = c.fileClose(file)
 			return
 		}
-		fileClose(file)
+		c.fileClose(file)
 	}()
 
-	history = strings.Replace(history, "\n", "\\n", -1)
+	history = escapeMessage(history)
 
-	if _, err := fmtFprintf(file, "# %s\n%s\n", time.Now().Format("2006/01/02 15:04:05"), history); err != nil {
+	if _, err := c.fmtFprintf(file, "# %s\n%s\n", c.timeNow().Format(HistoryTimeFormat), history); err != nil {
 		return err
 	}
 
@@ -251,36 +312,36 @@ func _removeDuplicate(slice []string) []string {
 	return results
 }
 
-func _createDefaultFile(filePath string) error {
-	if exists(filePath) {
+func (c *Client) _createDefaultFile(filePath string) error {
+	if c.exists(filePath) {
 		return nil
 	}
 
 	switch filePath {
-	case historyFilePath:
-		return createEmptyHistory()
-	case exampleFilePath:
-		return createDefaultExample()
+	case c.historyFilePath:
+		return c.createEmptyHistory()
+	case c.exampleFilePath:
+		return c.createDefaultExample()
 	}
 
 	return nil
 }
 
-func _createDefaultExample() (err error) {
-	file, err := osCreate(exampleFilePath)
+func (c *Client) _createDefaultExample() (err error) {
+	file, err := c.osCreate(c.exampleFilePath)
 	if err != nil {
 		return err
 	}
 	defer func() {
 		if err == nil {
-			err = fileClose(file)
+			err = c.fileClose(file)
 			return
 		}
-		fileClose(file)
+		c.fileClose(file)
 	}()
 
 	for _, s := range defaultExamples {
-		if _, err := fmtFprintln(file, s); err != nil {
+		if _, err := c.fmtFprintln(file, s); err != nil {
 			return err
 		}
 	}
@@ -288,27 +349,136 @@ func _createDefaultExample() (err error) {
 	return nil
 }
 
-func _createEmptyHistory() (err error) {
-	file, err := osCreate(historyFilePath)
+func (c *Client) _createEmptyHistory() (err error) {
+	file, err := c.osCreate(c.historyFilePath)
 	if err != nil {
 		return err
 	}
 	defer func() {
-		err = fileClose(file)
+		err = c.fileClose(file)
 	}()
 
 	return nil
 }
 
-func _exists(filename string) bool {
-	_, err := osStat(filename)
+func (c *Client) _exists(filename string) bool {
+	_, err := c.osStat(filename)
 	return err == nil
 }
 
-func _newCurrentUser() *user.User {
-	usr, err := userCurrent()
+// _homeDir returns the home directory of the current user, or $HOME when the
+// user database has no entry for it, as in many containers.
+func (c *Client) _homeDir() (string, error) {
+	usr, err := c.userCurrent()
+	if err == nil && len(usr.HomeDir) != 0 {
+		return usr.HomeDir, nil
+	}
+	if home := c.osGetenv("HOME"); len(home) != 0 {
+		return home, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("user %s has no home directory", usr.Username)
+	}
+	return "", fmt.Errorf("%s and $HOME is not set", err)
+}
+
+// Paths are the files of a Client.
+type Paths struct {
+	// Template is the template file, ~/.fcm by default.
+	Template string
+	// History is the history file, ~/.fcm_history by default.
+	History string
+	// Config is the config file, ~/.fcm_config by default.
+	Config string
+}
+
+// Paths returns the files the Client reads and writes. They need not exist.
+func (c *Client) Paths() (Paths, error) {
+	if err := c.resolvePaths(); err != nil {
+		return Paths{}, err
+	}
+	return Paths{Template: c.exampleFilePath, History: c.historyFilePath, Config: c.configFilePath}, nil
+}
+
+// repoFile returns the path of the file name in the top level directory of
+// the repository of the working directory, where fcm init writes the files
+// shared by a team. It is empty outside of repositories and when the file is
+// the one of the user, as in a home directory kept in git.
+func (c *Client) repoFile(name string) string {
+	dir := c.repoTopLevel()
+	if len(dir) == 0 {
+		return ""
+	}
+
+	path := filepath.Join(dir, name)
+	for _, own := range []string{c.exampleFilePath, c.historyFilePath, c.configFilePath} {
+		if path == own {
+			return ""
+		}
+	}
+	return path
+}
+
+// repoTopLevel returns the top level directory of the repository of the
+// working directory, the closest one holding .git, or "" outside of
+// repositories.
+func (c *Client) repoTopLevel() string {
+	dir, err := c.osGetwd()
 	if err != nil {
-		panic("error")
+		return ""
 	}
-	return usr
+	for {
+		if c.exists(filepath.Join(dir, ".git")) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// resolvePaths fills in the files not given by options, once. They are kept
+// in the home directory, or in the fcm directory of the user configuration
+// directory when there is no home.
+func (c *Client) resolvePaths() error {
+	c.resolveOnce.Do(func() {
+		if len(c.home) == 0 {
+			if home, err := c.homeDir(); err == nil {
+				c.home = home
+			} else {
+				c.resolveErr = err
+			}
+		}
+		if len(c.exampleFilePath) != 0 && len(c.historyFilePath) != 0 && len(c.configFilePath) != 0 {
+			c.resolveErr = nil
+			return
+		}
+
+		dir := c.home
+		if len(dir) == 0 {
+			config, err := c.osUserConfigDir()
+			if err != nil {
+				c.resolveErr = fmt.Errorf("cannot find a directory for %s, %s and %s: %s; %s", exampleFile, historyFile, configFile, c.resolveErr, err)
+				return
+			}
+			dir = filepath.Join(config, "fcm")
+			if err := c.osMkdirAll(dir, 0700); err != nil {
+				c.resolveErr = fmt.Errorf("cannot find a directory for %s, %s and %s: %s", exampleFile, historyFile, configFile, err)
+				return
+			}
+			c.resolveErr = nil
+		}
+		if len(c.exampleFilePath) == 0 {
+			c.exampleFilePath = filepath.Join(dir, exampleFile)
+		}
+		if len(c.historyFilePath) == 0 {
+			c.historyFilePath = filepath.Join(dir, historyFile)
+		}
+		if len(c.configFilePath) == 0 {
+			c.configFilePath = filepath.Join(dir, configFile)
+		}
+	})
+	return c.resolveErr
 }
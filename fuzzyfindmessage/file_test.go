@@ -1,27 +1,32 @@
 package fuzzyfindmessage
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"fmt"
-	"github.com/ktr0731/go-fuzzyfinder"
+	"github.com/nsf/termbox-go"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/user"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
 func Test_samples(t *testing.T) {
+	c := New()
 	count := 0
 	tests := []struct {
 		name              string
 		createDefaultFile func(filePath string) error
-		osOpen            func(name string) (*os.File, error)
-		bufioNewScanner   func(r io.Reader) *bufio.Scanner
-		scannerScan       func(scanner *bufio.Scanner) bool
-		scannerText       func(scanner *bufio.Scanner) string
-		removeDuplicate   func(slice []string) []string
-		want              []string
+		readTemplateFile  func(path string, chain []string) ([]Candidate, error)
+		envTemplates      func() ([]Candidate, error)
+		configTemplates   func(ctx context.Context) ([]Candidate, error)
+		historyTemplates  func() ([]Candidate, error)
+		want              []Candidate
+		wantStderr        string
 		wantErr           bool
 	}{
 		{
@@ -29,166 +34,181 @@ func Test_samples(t *testing.T) {
 			createDefaultFile: func(filePath string) error {
 				return nil
 			},
-			osOpen: func(name string) (*os.File, error) {
-				return nil, nil
+			readTemplateFile: func(path string, chain []string) ([]Candidate, error) {
+				return []Candidate{{Message: "fuga", Origin: "file"}, {Message: "hoge", Origin: "file"}}, nil
 			},
-			bufioNewScanner: func(r io.Reader) *bufio.Scanner {
-				return &bufio.Scanner{}
+			envTemplates: func() ([]Candidate, error) {
+				return []Candidate{{Message: "piyo", Origin: "env"}}, nil
 			},
-			scannerScan: func(scanner *bufio.Scanner) bool {
-				count++
-				return count <= 2
+			configTemplates: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "foo", Origin: "config"}}, nil
 			},
-			scannerText: func(scanner *bufio.Scanner) string {
-				return "hoge"
+			historyTemplates: func() ([]Candidate, error) {
+				return []Candidate{{Message: "hoge", Origin: "history"}}, nil
 			},
-			removeDuplicate: func(slice []string) []string {
-				return []string{"fuga", "hoge"}
+			want: []Candidate{
+				{Message: "piyo", Origin: "env"},
+				{Message: "hoge", Origin: "file"},
+				{Message: "fuga", Origin: "file"},
+				{Message: "foo", Origin: "config"},
 			},
-			want:    []string{"hoge", "fuga"},
 			wantErr: false,
 		},
 		{
-			name: "NormalNoneRecordInFile",
+			name: "NormalNoneRecord",
 			createDefaultFile: func(filePath string) error {
 				return nil
 			},
-			osOpen: func(name string) (*os.File, error) {
+			readTemplateFile: func(path string, chain []string) ([]Candidate, error) {
 				return nil, nil
 			},
-			bufioNewScanner: func(r io.Reader) *bufio.Scanner {
-				return &bufio.Scanner{}
+			envTemplates: func() ([]Candidate, error) {
+				return nil, nil
 			},
-			scannerScan: func(scanner *bufio.Scanner) bool {
-				return false
+			configTemplates: func(ctx context.Context) ([]Candidate, error) {
+				return nil, nil
 			},
-			scannerText: nil,
-			removeDuplicate: func(slice []string) []string {
-				return nil
+			historyTemplates: func() ([]Candidate, error) {
+				return nil, nil
 			},
-			want:    nil,
+			want:    []Candidate{},
 			wantErr: false,
 		},
 		{
-			name: "NormalBlankLine",
+			name: "ErrorBecauseCreateExamplesDefaultFileError",
 			createDefaultFile: func(filePath string) error {
-				return nil
-			},
-			osOpen: func(name string) (*os.File, error) {
-				return nil, nil
-			},
-			bufioNewScanner: func(r io.Reader) *bufio.Scanner {
-				return &bufio.Scanner{}
+				return fmt.Errorf("error")
 			},
-			scannerScan: func(scanner *bufio.Scanner) bool {
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "ErrorBecauseCreateHistoryDefaultFileError",
+			createDefaultFile: func(filePath string) error {
 				count++
-				return count <= 1
-
-			},
-			scannerText: func(scanner *bufio.Scanner) string {
-				return ""
-			},
-			removeDuplicate: func(slice []string) []string {
-				return nil
+				if count <= 1 {
+					return nil
+				}
+				return fmt.Errorf("error")
 			},
 			want:    nil,
-			wantErr: false,
+			wantErr: true,
 		},
 		{
-			name: "NormalBlankLine",
+			name: "NormalSkipBecauseReadTemplateFileReturnError",
 			createDefaultFile: func(filePath string) error {
 				return nil
 			},
-			osOpen: func(name string) (*os.File, error) {
-				return nil, nil
+			readTemplateFile: func(path string, chain []string) ([]Candidate, error) {
+				return nil, fmt.Errorf("error")
 			},
-			bufioNewScanner: func(r io.Reader) *bufio.Scanner {
-				return &bufio.Scanner{}
+			envTemplates: func() ([]Candidate, error) {
+				return []Candidate{{Message: "piyo", Origin: "env"}}, nil
 			},
-			scannerScan: func(scanner *bufio.Scanner) bool {
-				count++
-				return count <= 1
+			configTemplates: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "foo", Origin: "config"}}, nil
 			},
-			scannerText: func(scanner *bufio.Scanner) string {
-				return "# hoge"
+			historyTemplates: func() ([]Candidate, error) {
+				return []Candidate{{Message: "fuga", Origin: "history"}}, nil
 			},
-			removeDuplicate: func(slice []string) []string {
-				return nil
+			want: []Candidate{
+				{Message: "piyo", Origin: "env"},
+				{Message: "fuga", Origin: "history"},
+				{Message: "foo", Origin: "config"},
 			},
-			want:    nil,
-			wantErr: false,
+			wantStderr: "Skipped a source: error\n",
+			wantErr:    false,
 		},
 		{
-			name: "ErrorBecauseCreateExamplesDefaultFileError",
+			name: "NormalSkipBecauseEnvTemplatesReturnError",
 			createDefaultFile: func(filePath string) error {
-				return fmt.Errorf("error")
+				return nil
 			},
-			osOpen: nil,
-			bufioNewScanner: func(r io.Reader) *bufio.Scanner {
-				return &bufio.Scanner{}
+			readTemplateFile: func(path string, chain []string) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge", Origin: "file"}}, nil
 			},
-			want:    nil,
-			wantErr: true,
-		},
-		{
-			name: "ErrorBecauseCreateHistoryDefaultFileError",
-			createDefaultFile: func(filePath string) error {
-				count++
-				if count <= 1 {
-					return nil
-				}
-				return fmt.Errorf("error")
+			envTemplates: func() ([]Candidate, error) {
+				return nil, fmt.Errorf("error")
 			},
-			osOpen: nil,
-			bufioNewScanner: func(r io.Reader) *bufio.Scanner {
-				return &bufio.Scanner{}
+			configTemplates: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "foo", Origin: "config"}}, nil
 			},
-			want:    nil,
-			wantErr: true,
+			historyTemplates: func() ([]Candidate, error) {
+				return []Candidate{{Message: "fuga", Origin: "history"}}, nil
+			},
+			want: []Candidate{
+				{Message: "hoge", Origin: "file"},
+				{Message: "fuga", Origin: "history"},
+				{Message: "foo", Origin: "config"},
+			},
+			wantStderr: "Skipped a source: error\n",
+			wantErr:    false,
 		},
 		{
-			name: "ErrorBecauseNotOpenExamplesFile",
+			name: "NormalSkipBecauseConfigTemplatesReturnError",
 			createDefaultFile: func(filePath string) error {
 				return nil
 			},
-			osOpen: func(name string) (*os.File, error) {
+			readTemplateFile: func(path string, chain []string) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge", Origin: "file"}}, nil
+			},
+			envTemplates: func() ([]Candidate, error) {
+				return []Candidate{{Message: "piyo", Origin: "env"}}, nil
+			},
+			configTemplates: func(ctx context.Context) ([]Candidate, error) {
 				return nil, fmt.Errorf("error")
 			},
-			bufioNewScanner: func(r io.Reader) *bufio.Scanner {
-				return &bufio.Scanner{}
+			historyTemplates: func() ([]Candidate, error) {
+				return []Candidate{{Message: "fuga", Origin: "history"}}, nil
 			},
-			want:    nil,
-			wantErr: true,
+			want: []Candidate{
+				{Message: "piyo", Origin: "env"},
+				{Message: "hoge", Origin: "file"},
+				{Message: "fuga", Origin: "history"},
+			},
+			wantStderr: "Skipped a source: error\n",
+			wantErr:    false,
 		},
 		{
-			name: "ErrorBecauseNotOpenHistoryFile",
+			name: "NormalSkipBecauseHistoryTemplatesReturnError",
 			createDefaultFile: func(filePath string) error {
 				return nil
 			},
-			osOpen: func(name string) (*os.File, error) {
-				count++
-				if count <= 1 {
-					return nil, nil
-				}
+			readTemplateFile: func(path string, chain []string) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge", Origin: "file"}}, nil
+			},
+			envTemplates: func() ([]Candidate, error) {
+				return []Candidate{{Message: "piyo", Origin: "env"}}, nil
+			},
+			configTemplates: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "foo", Origin: "config"}}, nil
+			},
+			historyTemplates: func() ([]Candidate, error) {
 				return nil, fmt.Errorf("error")
 			},
-			bufioNewScanner: func(r io.Reader) *bufio.Scanner {
-				return &bufio.Scanner{}
+			want: []Candidate{
+				{Message: "piyo", Origin: "env"},
+				{Message: "hoge", Origin: "file"},
+				{Message: "foo", Origin: "config"},
 			},
-			want:    nil,
-			wantErr: true,
+			wantStderr: "Skipped a source: error\n",
+			wantErr:    false,
 		},
 	}
+	c.loadConfig = func() (*Config, error) {
+		return &Config{}, nil
+	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			count = 0
-			createDefaultFile = tt.createDefaultFile
-			osOpen = tt.osOpen
-			bufioNewScanner = tt.bufioNewScanner
-			scannerScan = tt.scannerScan
-			scannerText = tt.scannerText
-			removeDuplicate = tt.removeDuplicate
-			got, err := _samples()
+			c.createDefaultFile = tt.createDefaultFile
+			c.readTemplateFile = tt.readTemplateFile
+			c.envTemplates = tt.envTemplates
+			c.configTemplates = tt.configTemplates
+			c.historyTemplates = tt.historyTemplates
+			stderr := &bytes.Buffer{}
+			c.stderr = stderr
+			got, err := c._samples(context.Background())
 			if (err != nil) != tt.wantErr {
 				t.Errorf("samples() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -196,14 +216,19 @@ func Test_samples(t *testing.T) {
 			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("samples() got = %v, want %v", got, tt.want)
 			}
+			if stderr.String() != tt.wantStderr {
+				t.Errorf("samples() stderr = %q, want %q", stderr.String(), tt.wantStderr)
+			}
 		})
 	}
 }
 
 func Test__saveHistory(t *testing.T) {
+	c := New()
 	tests := []struct {
 		name              string
-		lastCommitMessage func() (string, error)
+		lastCommitMessage func(ctx context.Context) (string, error)
+		lockHistory       func(exclusive bool) (func() error, error)
 		osOpenFile        func(name string, flag int, perm os.FileMode) (*os.File, error)
 		fileClose         func(file *os.File) error
 		fmtFprintf        func(w io.Writer, format string, a ...interface{}) (n int, err error)
@@ -211,9 +236,12 @@ func Test__saveHistory(t *testing.T) {
 	}{
 		{
 			name: "Normal",
-			lastCommitMessage: func() (string, error) {
+			lastCommitMessage: func(ctx context.Context) (string, error) {
 				return "", nil
 			},
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return nil }, nil
+			},
 			osOpenFile: func(name string, flag int, perm os.FileMode) (*os.File, error) {
 				return nil, nil
 			},
@@ -227,9 +255,12 @@ func Test__saveHistory(t *testing.T) {
 		},
 		{
 			name: "ErrorLastCommitMessageReturnError",
-			lastCommitMessage: func() (string, error) {
+			lastCommitMessage: func(ctx context.Context) (string, error) {
 				return "", fmt.Errorf("error")
 			},
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return nil }, nil
+			},
 			osOpenFile: func(name string, flag int, perm os.FileMode) (*os.File, error) {
 				return nil, fmt.Errorf("error")
 			},
@@ -243,9 +274,12 @@ func Test__saveHistory(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseNotOpenFile",
-			lastCommitMessage: func() (string, error) {
+			lastCommitMessage: func(ctx context.Context) (string, error) {
 				return "", nil
 			},
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return nil }, nil
+			},
 			osOpenFile: func(name string, flag int, perm os.FileMode) (*os.File, error) {
 				return nil, fmt.Errorf("error")
 			},
@@ -259,9 +293,12 @@ func Test__saveHistory(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseFprintReturnError",
-			lastCommitMessage: func() (string, error) {
+			lastCommitMessage: func(ctx context.Context) (string, error) {
 				return "", nil
 			},
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return nil }, nil
+			},
 			osOpenFile: func(name string, flag int, perm os.FileMode) (*os.File, error) {
 				return nil, nil
 			},
@@ -275,9 +312,12 @@ func Test__saveHistory(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseFileCloseReturnError",
-			lastCommitMessage: func() (string, error) {
+			lastCommitMessage: func(ctx context.Context) (string, error) {
 				return "", nil
 			},
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return nil }, nil
+			},
 			osOpenFile: func(name string, flag int, perm os.FileMode) (*os.File, error) {
 				return nil, nil
 			},
@@ -291,9 +331,12 @@ func Test__saveHistory(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseFileCloseReturnErrorAndFileCloseReturnError",
-			lastCommitMessage: func() (string, error) {
+			lastCommitMessage: func(ctx context.Context) (string, error) {
 				return "", nil
 			},
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return nil }, nil
+			},
 			osOpenFile: func(name string, flag int, perm os.FileMode) (*os.File, error) {
 				return nil, nil
 			},
@@ -305,63 +348,218 @@ func Test__saveHistory(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "ErrorBecauseLockHistoryReturnError",
+			lastCommitMessage: func(ctx context.Context) (string, error) {
+				return "", nil
+			},
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return nil, fmt.Errorf("error")
+			},
+			osOpenFile: nil,
+			fileClose: func(file *os.File) error {
+				return nil
+			},
+			fmtFprintf: nil,
+			wantErr:    true,
+		},
+		{
+			name: "ErrorBecauseUnlockReturnError",
+			lastCommitMessage: func(ctx context.Context) (string, error) {
+				return "", nil
+			},
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return fmt.Errorf("error") }, nil
+			},
+			osOpenFile: func(name string, flag int, perm os.FileMode) (*os.File, error) {
+				return nil, nil
+			},
+			fileClose: func(file *os.File) error {
+				return nil
+			},
+			fmtFprintf: func(w io.Writer, format string, a ...interface{}) (n int, err error) {
+				return n, nil
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			lastCommitMessage = tt.lastCommitMessage
-			osOpenFile = tt.osOpenFile
-			fileClose = tt.fileClose
-			fmtFprintf = tt.fmtFprintf
-			if err := _saveHistory(); (err != nil) != tt.wantErr {
+			c.git = &fakeGit{headMessage: tt.lastCommitMessage}
+			c.lockHistory = tt.lockHistory
+			c.osOpenFile = tt.osOpenFile
+			c.fileClose = tt.fileClose
+			c.fmtFprintf = tt.fmtFprintf
+			if err := c._saveHistory(context.Background()); (err != nil) != tt.wantErr {
 				t.Errorf("_saveHistory() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
 
-func Test__newCurrentUser(t *testing.T) {
+func Test__homeDir(t *testing.T) {
+	c := New()
 	tests := []struct {
 		name        string
 		userCurrent func() (*user.User, error)
-		want        *user.User
+		home        string
+		want        string
 		wantErr     bool
 	}{
 		{
 			name: "Normal",
 			userCurrent: func() (*user.User, error) {
-				return nil, nil
+				return &user.User{HomeDir: "/home/hoge"}, nil
 			},
-			want:    nil,
+			home:    "/home/fuga",
+			want:    "/home/hoge",
 			wantErr: false,
 		},
 		{
-			name: "PanicBecauseReturnUserCurrentReturnError",
+			name: "NormalBecauseUserCurrentReturnErrorAndHomeIsSet",
 			userCurrent: func() (*user.User, error) {
 				return nil, fmt.Errorf("error")
 			},
-			want:    nil,
+			home:    "/home/fuga",
+			want:    "/home/fuga",
+			wantErr: false,
+		},
+		{
+			name: "NormalBecauseUserHasNoHomeAndHomeIsSet",
+			userCurrent: func() (*user.User, error) {
+				return &user.User{Username: "hoge"}, nil
+			},
+			home:    "/home/fuga",
+			want:    "/home/fuga",
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseUserCurrentReturnErrorAndHomeIsNotSet",
+			userCurrent: func() (*user.User, error) {
+				return nil, fmt.Errorf("error")
+			},
+			home:    "",
+			want:    "",
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			userCurrent = tt.userCurrent
-			if tt.wantErr {
-				defer func() {
-					err := recover()
-					if err == nil {
-						t.Errorf("newCurrentUser() not return panic")
-					}
-				}()
-			}
-			if got := _newCurrentUser(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("newCurrentUser() = %v, want %v", got, tt.want)
+			c.userCurrent = tt.userCurrent
+			c.osGetenv = func(key string) string {
+				return tt.home
+			}
+			got, err := c._homeDir()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("homeDir() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("homeDir() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func Test_resolvePaths(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		homeDir       func() (string, error)
+		userConfigDir func() (string, error)
+		want          string
+		wantErr       bool
+	}{
+		{
+			name: "Normal",
+			homeDir: func() (string, error) {
+				return "/home/hoge", nil
+			},
+			userConfigDir: func() (string, error) {
+				return "/config", nil
+			},
+			want:    filepath.Join("/home/hoge", historyFile),
+			wantErr: false,
+		},
+		{
+			name: "NormalBecauseHomeIsNotFoundAndConfigDirIs",
+			homeDir: func() (string, error) {
+				return "", fmt.Errorf("error")
+			},
+			userConfigDir: func() (string, error) {
+				return "/config", nil
+			},
+			want:    filepath.Join("/config", "fcm", historyFile),
+			wantErr: false,
+		},
+		{
+			name: "NormalBecauseEveryFileIsGiven",
+			opts: []Option{WithTemplateFile("/tmp/fcm"), WithHistoryFile("/tmp/fcm_history"), WithConfigFile("/tmp/fcm_config")},
+			homeDir: func() (string, error) {
+				return "", fmt.Errorf("error")
+			},
+			userConfigDir: func() (string, error) {
+				return "", fmt.Errorf("error")
+			},
+			want:    "/tmp/fcm_history",
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseNeitherHomeNorConfigDirIsFound",
+			homeDir: func() (string, error) {
+				return "", fmt.Errorf("error")
+			},
+			userConfigDir: func() (string, error) {
+				return "", fmt.Errorf("error")
+			},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.opts...)
+			c.homeDir = tt.homeDir
+			c.osUserConfigDir = tt.userConfigDir
+			c.osMkdirAll = func(path string, perm os.FileMode) error {
+				return nil
+			}
+			err := c.resolvePaths()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolvePaths() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if c.historyFilePath != tt.want {
+				t.Errorf("resolvePaths() history = %v, want %v", c.historyFilePath, tt.want)
+			}
+			if err := c.resolvePaths(); (err != nil) != tt.wantErr {
+				t.Errorf("resolvePaths() second call error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCommit_noHome(t *testing.T) {
+	c := New()
+	c.homeDir = func() (string, error) {
+		return "", fmt.Errorf("user: Current requires cgo or $USER set in environment and $HOME is not set")
+	}
+	c.osUserConfigDir = func() (string, error) {
+		return "", fmt.Errorf("neither $XDG_CONFIG_HOME nor $HOME are defined")
+	}
+	c.git = &fakeGit{commit: func(ctx context.Context, fileName string, edit bool) error {
+		t.Fatal("Commit() ran without the fcm files")
+		return nil
+	}}
+
+	err := c.Commit()
+	if err == nil || !strings.Contains(err.Error(), historyFile) {
+		t.Errorf("Commit() error = %v, want an error naming %s", err, historyFile)
+	}
+}
+
 func Test__exists(t *testing.T) {
+	c := New()
 	tests := []struct {
 		name     string
 		filename string
@@ -387,8 +585,8 @@ func Test__exists(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			osStat = tt.osStat
-			if got := _exists(tt.filename); got != tt.want {
+			c.osStat = tt.osStat
+			if got := c._exists(tt.filename); got != tt.want {
 				t.Errorf("exists() = %v, want %v", got, tt.want)
 			}
 		})
@@ -396,6 +594,7 @@ func Test__exists(t *testing.T) {
 }
 
 func Test__createEmptyHistory(t *testing.T) {
+	c := New()
 	tests := []struct {
 		name      string
 		osCreate  func(name string) (*os.File, error)
@@ -435,9 +634,9 @@ func Test__createEmptyHistory(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			osCreate = tt.osCreate
-			fileClose = tt.fileClose
-			if err := _createEmptyHistory(); (err != nil) != tt.wantErr {
+			c.osCreate = tt.osCreate
+			c.fileClose = tt.fileClose
+			if err := c._createEmptyHistory(); (err != nil) != tt.wantErr {
 				t.Errorf("createEmptyHistory() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -445,6 +644,7 @@ func Test__createEmptyHistory(t *testing.T) {
 }
 
 func Test__createDefaultExample(t *testing.T) {
+	c := New()
 	tests := []struct {
 		name        string
 		osCreate    func(name string) (*os.File, error)
@@ -520,10 +720,10 @@ func Test__createDefaultExample(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			osCreate = tt.osCreate
-			fileClose = tt.fileClose
-			fmtFprintln = tt.fmtFprintln
-			if err := _createDefaultExample(); (err != nil) != tt.wantErr {
+			c.osCreate = tt.osCreate
+			c.fileClose = tt.fileClose
+			c.fmtFprintln = tt.fmtFprintln
+			if err := c._createDefaultExample(); (err != nil) != tt.wantErr {
 				t.Errorf("createDefaultExample() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -531,6 +731,7 @@ func Test__createDefaultExample(t *testing.T) {
 }
 
 func Test__createTemplate(t *testing.T) {
+	c := New()
 	tests := []struct {
 		name           string
 		message        string
@@ -623,10 +824,10 @@ func Test__createTemplate(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ioutilTempFile = tt.ioutilTempFile
-			fileWrite = tt.fileWrite
-			fileClose = tt.fileClose
-			got, err := _createTemplate(tt.message)
+			c.ioutilTempFile = tt.ioutilTempFile
+			c.fileWrite = tt.fileWrite
+			c.fileClose = tt.fileClose
+			got, err := c._createTemplate(tt.message)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("createTemplate() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -639,6 +840,7 @@ func Test__createTemplate(t *testing.T) {
 }
 
 func Test__createDefaultFile(t *testing.T) {
+	c := New()
 	historyFilePathMock := "hoge/.fcm_history"
 	exampleFilePathMock := "hoge/.fcm"
 	tests := []struct {
@@ -704,12 +906,12 @@ func Test__createDefaultFile(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			exists = tt.exists
-			createEmptyHistory = tt.createEmptyHistory
-			createDefaultExample = tt.createDefaultExample
-			exampleFilePath = exampleFilePathMock
-			historyFilePath = historyFilePathMock
-			if err := _createDefaultFile(tt.filePath); (err != nil) != tt.wantErr {
+			c.exists = tt.exists
+			c.createEmptyHistory = tt.createEmptyHistory
+			c.createDefaultExample = tt.createDefaultExample
+			c.exampleFilePath = exampleFilePathMock
+			c.historyFilePath = historyFilePathMock
+			if err := c._createDefaultFile(tt.filePath); (err != nil) != tt.wantErr {
 				t.Errorf("_createDefaultFile() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -738,32 +940,38 @@ func Test__removeDuplicate(t *testing.T) {
 }
 
 func TestCommit(t *testing.T) {
+	c := New()
+	count := 0
+	movingHead := func(ctx context.Context) (string, error) {
+		count++
+		return fmt.Sprint(count), nil
+	}
 	tests := []struct {
-		name            string
-		samples         func() ([]string, error)
-		fuzzyfinderFind func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error)
-		createTemplate  func(message string) (f *os.File, err error)
-		gitCommit       func(fileName string) error
-		saveHistory     func() (err error)
-		tmpFileName     func(f *os.File) string
-		osRemove        func(name string) error
-		wantErr         bool
+		name           string
+		samples        func(ctx context.Context) ([]Candidate, error)
+		finder         Finder
+		createTemplate func(message string) (f *os.File, err error)
+		gitCommit      func(ctx context.Context, fileName string, edit bool) error
+		headCommit     func(ctx context.Context) (string, error)
+		saveHistory    func(ctx context.Context) (err error)
+		tmpFileName    func(f *os.File) string
+		osRemove       func(name string) error
+		wantErr        bool
 	}{
 		{
 			name: "Normal",
-			samples: func() ([]string, error) {
-				return []string{"hoge"}, nil
-			},
-			fuzzyfinderFind: func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error) {
-				return 0, nil
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
 			},
+			finder: &mockFinder{},
 			createTemplate: func(message string) (f *os.File, err error) {
 				return nil, nil
 			},
-			gitCommit: func(fileName string) error {
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
 				return nil
 			},
-			saveHistory: func() (err error) {
+			headCommit: movingHead,
+			saveHistory: func(ctx context.Context) (err error) {
 				return nil
 			},
 			tmpFileName: func(f *os.File) string {
@@ -776,19 +984,18 @@ func TestCommit(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseSamplesReturnError",
-			samples: func() ([]string, error) {
+			samples: func(ctx context.Context) ([]Candidate, error) {
 				return nil, fmt.Errorf("error")
 			},
-			fuzzyfinderFind: func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error) {
-				return 0, nil
-			},
+			finder: &mockFinder{},
 			createTemplate: func(message string) (f *os.File, err error) {
 				return nil, nil
 			},
-			gitCommit: func(fileName string) error {
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
 				return nil
 			},
-			saveHistory: func() (err error) {
+			headCommit: movingHead,
+			saveHistory: func(ctx context.Context) (err error) {
 				return nil
 			},
 			tmpFileName: func(f *os.File) string {
@@ -800,20 +1007,19 @@ func TestCommit(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "ErrorBecauseFuzzyFinderFindReturnError",
-			samples: func() ([]string, error) {
-				return []string{"hoge"}, nil
-			},
-			fuzzyfinderFind: func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error) {
-				return 0, fmt.Errorf("error")
+			name: "ErrorBecauseFinderReturnError",
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
 			},
+			finder: &mockFinder{err: fmt.Errorf("error")},
 			createTemplate: func(message string) (f *os.File, err error) {
 				return nil, nil
 			},
-			gitCommit: func(fileName string) error {
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
 				return nil
 			},
-			saveHistory: func() (err error) {
+			headCommit: movingHead,
+			saveHistory: func(ctx context.Context) (err error) {
 				return nil
 			},
 			tmpFileName: func(f *os.File) string {
@@ -826,19 +1032,18 @@ func TestCommit(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseCreateTemplateReturnError",
-			samples: func() ([]string, error) {
-				return []string{"hoge"}, nil
-			},
-			fuzzyfinderFind: func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error) {
-				return 0, nil
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
 			},
+			finder: &mockFinder{},
 			createTemplate: func(message string) (f *os.File, err error) {
 				return nil, fmt.Errorf("error")
 			},
-			gitCommit: func(fileName string) error {
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
 				return nil
 			},
-			saveHistory: func() (err error) {
+			headCommit: movingHead,
+			saveHistory: func(ctx context.Context) (err error) {
 				return nil
 			},
 			tmpFileName: func(f *os.File) string {
@@ -851,19 +1056,18 @@ func TestCommit(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseGitCommitReturnError",
-			samples: func() ([]string, error) {
-				return []string{"hoge"}, nil
-			},
-			fuzzyfinderFind: func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error) {
-				return 0, nil
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
 			},
+			finder: &mockFinder{},
 			createTemplate: func(message string) (f *os.File, err error) {
 				return nil, nil
 			},
-			gitCommit: func(fileName string) error {
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
 				return fmt.Errorf("error")
 			},
-			saveHistory: func() (err error) {
+			headCommit: movingHead,
+			saveHistory: func(ctx context.Context) (err error) {
 				return nil
 			},
 			tmpFileName: func(f *os.File) string {
@@ -876,19 +1080,18 @@ func TestCommit(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseSaveHistoryReturnError",
-			samples: func() ([]string, error) {
-				return []string{"hoge"}, nil
-			},
-			fuzzyfinderFind: func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error) {
-				return 0, nil
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
 			},
+			finder: &mockFinder{},
 			createTemplate: func(message string) (f *os.File, err error) {
 				return nil, nil
 			},
-			gitCommit: func(fileName string) error {
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
 				return nil
 			},
-			saveHistory: func() (err error) {
+			headCommit: movingHead,
+			saveHistory: func(ctx context.Context) (err error) {
 				return fmt.Errorf("error")
 			},
 			tmpFileName: func(f *os.File) string {
@@ -901,19 +1104,18 @@ func TestCommit(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseOsRemoveReturnError",
-			samples: func() ([]string, error) {
-				return []string{"hoge"}, nil
-			},
-			fuzzyfinderFind: func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error) {
-				return 0, nil
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
 			},
+			finder: &mockFinder{},
 			createTemplate: func(message string) (f *os.File, err error) {
 				return nil, nil
 			},
-			gitCommit: func(fileName string) error {
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
 				return nil
 			},
-			saveHistory: func() (err error) {
+			headCommit: movingHead,
+			saveHistory: func(ctx context.Context) (err error) {
 				return nil
 			},
 			tmpFileName: func(f *os.File) string {
@@ -926,19 +1128,18 @@ func TestCommit(t *testing.T) {
 		},
 		{
 			name: "ErrorBecauseOsRemoveReturnErrorAndSomeError",
-			samples: func() ([]string, error) {
-				return []string{"hoge"}, nil
-			},
-			fuzzyfinderFind: func(slice interface{}, itemFunc func(i int) string, opts ...fuzzyfinder.Option) (int, error) {
-				return 0, nil
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
 			},
+			finder: &mockFinder{},
 			createTemplate: func(message string) (f *os.File, err error) {
 				return nil, nil
 			},
-			gitCommit: func(fileName string) error {
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
 				return fmt.Errorf("error")
 			},
-			saveHistory: func() (err error) {
+			headCommit: movingHead,
+			saveHistory: func(ctx context.Context) (err error) {
 				return nil
 			},
 			tmpFileName: func(f *os.File) string {
@@ -949,19 +1150,501 @@ func TestCommit(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "NormalHeadNotMoved",
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
+			},
+			finder: &mockFinder{},
+			createTemplate: func(message string) (f *os.File, err error) {
+				return nil, nil
+			},
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
+				return nil
+			},
+			headCommit: func(ctx context.Context) (string, error) {
+				return "hoge", nil
+			},
+			saveHistory: func(ctx context.Context) (err error) {
+				return fmt.Errorf("must not be called")
+			},
+			tmpFileName: func(f *os.File) string {
+				return "hoge"
+			},
+			osRemove: func(name string) error {
+				return nil
+			},
+			wantErr: false,
+		},
+		{
+			name: "NormalUnbornBranchNotCommitted",
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
+			},
+			finder: &mockFinder{},
+			createTemplate: func(message string) (f *os.File, err error) {
+				return nil, nil
+			},
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
+				return nil
+			},
+			headCommit: func(ctx context.Context) (string, error) {
+				return "", nil
+			},
+			saveHistory: func(ctx context.Context) (err error) {
+				return fmt.Errorf("must not be called")
+			},
+			tmpFileName: func(f *os.File) string {
+				return "hoge"
+			},
+			osRemove: func(name string) error {
+				return nil
+			},
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseHeadCommitReturnError",
+			samples: func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "hoge"}}, nil
+			},
+			finder: &mockFinder{},
+			createTemplate: func(message string) (f *os.File, err error) {
+				return nil, nil
+			},
+			gitCommit: func(ctx context.Context, fileName string, edit bool) error {
+				return nil
+			},
+			headCommit: func(ctx context.Context) (string, error) {
+				return "", fmt.Errorf("error")
+			},
+			saveHistory: func(ctx context.Context) (err error) {
+				return nil
+			},
+			tmpFileName: func(f *os.File) string {
+				return "hoge"
+			},
+			osRemove: func(name string) error {
+				return nil
+			},
+			wantErr: true,
+		},
+	}
+	c.pruneHistory = func(dryRun bool) ([]HistoryEntry, error) {
+		return nil, nil
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			samples = tt.samples
-			fuzzyfinderFind = tt.fuzzyfinderFind
-			createTemplate = tt.createTemplate
-			gitCommit = tt.gitCommit
-			saveHistory = tt.saveHistory
-			tmpFileName = tt.tmpFileName
-			osRemove = tt.osRemove
-			if err := Commit(); (err != nil) != tt.wantErr {
+			count = 0
+			c.samples = tt.samples
+			c.finder = tt.finder
+			c.createTemplate = tt.createTemplate
+			c.git = &fakeGit{commit: tt.gitCommit, headCommit: tt.headCommit}
+			c.saveHistory = tt.saveHistory
+			c.tmpFileName = tt.tmpFileName
+			c.osRemove = tt.osRemove
+			if err := c.Commit(); (err != nil) != tt.wantErr {
 				t.Errorf("Commit() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+type editorFunc func(ctx context.Context, message string) (string, error)
+
+func (f editorFunc) Edit(ctx context.Context, message string) (string, error) {
+	return f(ctx, message)
+}
+
+func TestCommit_editor(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		editor   editorFunc
+		wantFile string
+		wantErr  bool
+	}{
+		{
+			name:    "Normal",
+			message: "Fix",
+			editor: func(ctx context.Context, message string) (string, error) {
+				return message + " PROJ-1", nil
+			},
+			wantFile: "Fix PROJ-1",
+			wantErr:  false,
+		},
+		{
+			// The editor gets the lines of the message, not the escaped
+			// newlines of the history.
+			name:    "NormalMultiLine",
+			message: "Fix hoge\\n\\nbecause of fuga",
+			editor: func(ctx context.Context, message string) (string, error) {
+				if message != "Fix hoge\n\nbecause of fuga" {
+					return "", fmt.Errorf("Edit() got %q", message)
+				}
+				return message + "\n\nSee PROJ-1\n", nil
+			},
+			wantFile: "Fix hoge\n\nbecause of fuga\n\nSee PROJ-1",
+			wantErr:  false,
+		},
+		{
+			name:    "ErrorBecauseEditorReturnError",
+			message: "Fix",
+			editor: func(ctx context.Context, message string) (string, error) {
+				return "", ErrAborted
+			},
+			wantFile: "",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			selectMessage(c, tt.message)
+			WithEditor(tt.editor)(c)
+			var gotFile string
+			c.git = &fakeGit{commit: func(ctx context.Context, fileName string, edit bool) error {
+				if edit {
+					t.Errorf("Commit() opened the editor of git after the inline editor")
+				}
+				b, err := ioutil.ReadFile(fileName)
+				gotFile = string(b)
+				return err
+			}}
+			if err := c.Commit(); (err != nil) != tt.wantErr {
+				t.Fatalf("Commit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if gotFile != tt.wantFile {
+				t.Errorf("Commit() committed %q, want %q", gotFile, tt.wantFile)
+			}
+		})
+	}
+}
+
+// mockSelector chooses the item at index with key, or query when the index
+// is -1.
+type mockSelector struct {
+	mockFinder
+	key   string
+	query string
+	opts  SelectOptions
+}
+
+func (f *mockSelector) Select(ctx context.Context, items []string, preview func(i int) string, opts SelectOptions) (Selection, error) {
+	f.items = items
+	f.opts = opts
+	return Selection{Index: f.id, Key: f.key, Query: f.query}, f.err
+}
+
+// scriptedSelector returns its selections one after the other.
+type scriptedSelector struct {
+	mockFinder
+	selections []Selection
+}
+
+func (f *scriptedSelector) Select(ctx context.Context, items []string, preview func(i int) string, opts SelectOptions) (Selection, error) {
+	f.items = items
+	s := f.selections[0]
+	f.selections = f.selections[1:]
+	return s, nil
+}
+
+func TestClient_chooseMessage_promoteKey(t *testing.T) {
+	c, _ := newTempClient(t)
+	if err := ioutil.WriteFile(c.exampleFilePath, []byte("# Fix\nFix {ticket}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(c.historyFilePath, []byte("# 2020/01/01 00:00:00\nFix PROJ-1 in main.go\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	c.stderr = &stderr
+	// The template comes first, then the history entry. Promoting the
+	// template does nothing; the category finder picks "Fix".
+	f := &scriptedSelector{selections: []Selection{
+		{Index: 0, Key: promoteKey},
+		{Index: 1, Key: promoteKey},
+		{Index: 1},
+	}}
+	c.finder = f
+
+	message, key, err := c.chooseMessage(context.Background(), []string{editKey, promoteKey})
+	if err != nil {
+		t.Fatalf("chooseMessage() error = %v", err)
+	}
+	if message != "Fix PROJ-1 in main.go" || key != "" {
+		t.Errorf("chooseMessage() = %q, %q", message, key)
+	}
+	if got, want := readFile(t, c.exampleFilePath), "# Fix\nFix {ticket}\nFix PROJ-1 in main.go\n"; got != want {
+		t.Errorf("chooseMessage() templates = %q, want %q", got, want)
+	}
+	if !strings.Contains(stderr.String(), "Only entries of the history") {
+		t.Errorf("chooseMessage() printed %q", stderr.String())
+	}
+	if len(f.selections) != 0 {
+		t.Errorf("chooseMessage() left %d selections", len(f.selections))
+	}
+}
+
+func TestCommit_editKey(t *testing.T) {
+	c, _ := newTempClient(t)
+	c.samples = func(ctx context.Context) ([]Candidate, error) {
+		return []Candidate{{Message: "Add hoge"}, {Message: "Fix {ticket}: login"}}, nil
+	}
+	WithFinder(&mockSelector{mockFinder: mockFinder{id: 1}, key: editKey})(c)
+	c.screen = newFakeScreen(keys("PROJ-1", termbox.KeyEnter)...)
+	var gotFile string
+	c.git = &fakeGit{commit: func(ctx context.Context, fileName string, edit bool) error {
+		if edit {
+			t.Errorf("Commit() opened the editor of git after the inline editor")
+		}
+		b, err := ioutil.ReadFile(fileName)
+		gotFile = string(b)
+		return err
+	}}
+
+	if err := c.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if want := "Fix PROJ-1: login"; gotFile != want {
+		t.Errorf("Commit() committed %q, want %q", gotFile, want)
+	}
+}
+
+func TestCommit_editKeyMultiLine(t *testing.T) {
+	c, _ := newTempClient(t)
+	c.samples = func(ctx context.Context) ([]Candidate, error) {
+		return []Candidate{{Message: "Fix {ticket}: login\\n\\nThe session expired"}}, nil
+	}
+	WithFinder(&mockSelector{key: editKey})(c)
+	// The body is a line of its own in the inline editor.
+	events := append(keys("PROJ-1", termbox.KeyArrowDown, termbox.KeyEnd), keys(".", termbox.KeyCtrlD)...)
+	c.screen = newFakeScreen(events...)
+	var gotFile string
+	c.git = &fakeGit{commit: func(ctx context.Context, fileName string, edit bool) error {
+		b, err := ioutil.ReadFile(fileName)
+		gotFile = string(b)
+		return err
+	}}
+
+	if err := c.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if want := "Fix PROJ-1: login\n\nThe session expired."; gotFile != want {
+		t.Errorf("Commit() committed %q, want %q", gotFile, want)
+	}
+}
+
+func TestCommit_newMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		config    string
+		wantQuery bool
+	}{
+		{
+			name:      "NormalOption",
+			opts:      []Option{WithNewMessages()},
+			wantQuery: true,
+		},
+		{
+			name:      "NormalConfig",
+			config:    "finder.new_message = true\n",
+			wantQuery: true,
+		},
+		{
+			name:      "NormalDisabled",
+			wantQuery: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newTempClient(t)
+			if err := ioutil.WriteFile(filepath.Join(dir, configFile), []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for _, opt := range tt.opts {
+				opt(c)
+			}
+			c.samples = func(ctx context.Context) ([]Candidate, error) {
+				return []Candidate{{Message: "Add hoge"}}, nil
+			}
+			f := &mockSelector{mockFinder: mockFinder{id: -1}, query: "Add piyo"}
+			WithFinder(f)(c)
+			var gotFile string
+			heads := []string{"before", "after"}
+			c.git = &fakeGit{
+				commit: func(ctx context.Context, fileName string, edit bool) error {
+					b, err := ioutil.ReadFile(fileName)
+					gotFile = string(b)
+					return err
+				},
+				headCommit: func(ctx context.Context) (string, error) {
+					head := heads[0]
+					heads = heads[1:]
+					return head, nil
+				},
+				headMessage: func(ctx context.Context) (string, error) {
+					return gotFile, nil
+				},
+			}
+
+			if !tt.wantQuery {
+				f.id = 0
+			}
+			if err := c.Commit(); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+			if f.opts.AllowQuery != tt.wantQuery {
+				t.Errorf("Commit() AllowQuery = %v, want %v", f.opts.AllowQuery, tt.wantQuery)
+			}
+			if !tt.wantQuery {
+				return
+			}
+			if gotFile != "Add piyo" {
+				t.Errorf("Commit() committed %q, want %q", gotFile, "Add piyo")
+			}
+			history, err := ioutil.ReadFile(c.historyFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(history), "\nAdd piyo\n") {
+				t.Errorf("history = %q, want Add piyo", history)
+			}
+		})
+	}
+}
+
+func TestCommit_errors(t *testing.T) {
+	gitErr := &GitError{Args: []string{"commit"}, ExitCode: 128}
+	tests := []struct {
+		name      string
+		topLevel  string
+		commitErr error
+		staged    bool
+		want      error
+	}{
+		{
+			name:     "ErrorBecauseNotARepo",
+			topLevel: "",
+			want:     ErrNotARepo,
+		},
+		{
+			name:      "ErrorBecauseNothingStaged",
+			topLevel:  ".",
+			commitErr: nil,
+			staged:    false,
+			want:      ErrNothingStaged,
+		},
+		{
+			name:      "ErrorBecauseGitFailed",
+			topLevel:  ".",
+			commitErr: gitErr,
+			staged:    true,
+			want:      gitErr,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			selectMessage(c, "Add hoge")
+			c.git = &fakeGit{
+				commit: func(ctx context.Context, fileName string, edit bool) error {
+					return tt.commitErr
+				},
+				topLevel: func(ctx context.Context) (string, error) {
+					return tt.topLevel, nil
+				},
+				staged: func(ctx context.Context) (bool, error) {
+					return tt.staged, nil
+				},
+			}
+			if err := c.Commit(); err != tt.want {
+				t.Errorf("Commit() error = %v, want %v", err, tt.want)
+			}
+			if tt.want == ErrNothingStaged && c.finder.(*mockFinder).items != nil {
+				t.Errorf("Commit() opened the finder with nothing staged")
+			}
+		})
+	}
+}
+
+func TestCommit_withoutHistory(t *testing.T) {
+	c, _ := newTempClient(t)
+	WithoutHistory()(c)
+	selectMessage(c, "Add hoge")
+	heads := []string{"before", "after"}
+	c.git = &fakeGit{
+		headCommit: func(ctx context.Context) (string, error) {
+			head := heads[0]
+			heads = heads[1:]
+			return head, nil
+		},
+		headMessage: func(ctx context.Context) (string, error) {
+			return "Add hoge", nil
+		},
+	}
+
+	if err := c.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if b, err := ioutil.ReadFile(c.historyFilePath); len(b) != 0 || (err != nil && !os.IsNotExist(err)) {
+		t.Errorf("history = %q, %v, want none", b, err)
+	}
+}
+
+func TestCommit_pruneHistoryFails(t *testing.T) {
+	c, _ := newTempClient(t)
+	var stderr bytes.Buffer
+	c.stderr = &stderr
+	selectMessage(c, "Add hoge")
+	heads := []string{"before", "after"}
+	c.git = &fakeGit{
+		headCommit: func(ctx context.Context) (string, error) {
+			head := heads[0]
+			heads = heads[1:]
+			return head, nil
+		},
+		headMessage: func(ctx context.Context) (string, error) {
+			return "Add hoge", nil
+		},
+	}
+	c.pruneHistory = func(dryRun bool) ([]HistoryEntry, error) {
+		return nil, fmt.Errorf("disk full")
+	}
+
+	if err := c.Commit(); err != nil {
+		t.Fatalf("Commit() error = %v, want the commit to succeed", err)
+	}
+	if got, want := stderr.String(), "Could not prune the history: disk full\n"; got != want {
+		t.Errorf("stderr = %q, want %q", got, want)
+	}
+	if b, err := ioutil.ReadFile(c.historyFilePath); !strings.Contains(string(b), "Add hoge") {
+		t.Errorf("history = %q, %v, want the message recorded", b, err)
+	}
+}
+
+func TestClient_Paths(t *testing.T) {
+	c, dir := newTempClient(t)
+	want := Paths{
+		Template: filepath.Join(dir, exampleFile),
+		History:  filepath.Join(dir, historyFile),
+		Config:   filepath.Join(dir, configFile),
+	}
+	if got, err := c.Paths(); err != nil || got != want {
+		t.Errorf("Paths() = %v, %v, want %v", got, err, want)
+	}
+}
+
+func TestCommitContext(t *testing.T) {
+	c, _ := newTempClient(t)
+	c.git = &fakeGit{commit: func(ctx context.Context, fileName string, edit bool) error {
+		t.Errorf("Commit() was called with a canceled context")
+		return nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.CommitContext(ctx); err != context.Canceled {
+		t.Errorf("CommitContext() error = %v, want %v", err, context.Canceled)
+	}
+}
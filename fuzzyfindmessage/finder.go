@@ -0,0 +1,439 @@
+package fuzzyfindmessage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Finder lets the user choose among items, the candidate list of Commit or
+// the history and category lists. preview returns the text shown next to the
+// i-th item; it is nil when there is nothing to preview. Canceling ctx should
+// close the finder and return ctx.Err().
+type Finder interface {
+	Find(ctx context.Context, items []string, preview func(i int) string) (int, error)
+	FindMulti(ctx context.Context, items []string, preview func(i int) string) ([]int, error)
+}
+
+// Selection is the item chosen with a Selector.
+type Selection struct {
+	// Index is the chosen item, or -1 when the Query matched none.
+	Index int
+	// Key is the key that chose the item, one of SelectOptions.Keys in fzf
+	// notation such as "ctrl-e", or "" for Enter.
+	Key string
+	// Query is the text typed into the finder, when it can tell.
+	Query string
+}
+
+// SelectOptions is what a Selector is asked for.
+type SelectOptions struct {
+	// Keys are the keys, besides Enter, that choose an item.
+	Keys []string
+	// AllowQuery lets the user choose a query that matches no item. The
+	// Selection has Index -1 then.
+	AllowQuery bool
+}
+
+// Selector is a Finder that also tells which key chose the item and what was
+// typed. Commit offers ctrl-e with a Selector, which opens the chosen message
+// in the inline editor, and ctrl-t, which promotes the chosen history entry
+// into the template file. It commits a query matching nothing as a new
+// message when new messages are enabled.
+type Selector interface {
+	Finder
+	Select(ctx context.Context, items []string, preview func(i int) string, opts SelectOptions) (Selection, error)
+}
+
+// editKey chooses a candidate of Commit and opens it in the inline editor.
+const editKey = "ctrl-e"
+
+// promoteKey promotes the highlighted history entry of Commit into the
+// template file and opens the finder again.
+const promoteKey = "ctrl-t"
+
+// selectItem is f.Select, or f.Find when f cannot tell the keys apart.
+func selectItem(ctx context.Context, f Finder, items []string, preview func(i int) string, opts SelectOptions) (Selection, error) {
+	if s, ok := f.(Selector); ok {
+		return s.Select(ctx, items, preview, opts)
+	}
+	id, err := f.Find(ctx, items, preview)
+	return Selection{Index: id}, err
+}
+
+// WithCommandFinder replaces the builtin finder with an external finder
+// such as fzf, sk or peco. The items are written to its standard input, one
+// per line, and the lines it prints are the selection. fzf and sk are given
+// --multi where several items can be chosen. The preview is not shown.
+func WithCommandFinder(name string, args ...string) Option {
+	return func(c *Client) {
+		c.finder = commandFinder{c: c, name: name, args: args}
+	}
+}
+
+// WithPromptFinder replaces the builtin finder with a numbered list read
+// from the standard input of the Client, for terminals that cannot run a
+// full screen UI.
+func WithPromptFinder() Option {
+	return func(c *Client) {
+		c.finder = promptFinder{c: c}
+	}
+}
+
+// WithNewMessages commits the query typed into the finder as a new message
+// when it matches no candidate. Only a Selector, such as the builtin finder,
+// fzf, sk or the prompt, can report the query.
+func WithNewMessages() Option {
+	return func(c *Client) {
+		c.newMessages = true
+	}
+}
+
+// allowNewMessages tells whether WithNewMessages or the finder.new_message
+// key of the config file is set.
+func (c *Client) allowNewMessages() (bool, error) {
+	if c.newMessages {
+		return true, nil
+	}
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return false, err
+	}
+	return cfg.NewMessages, nil
+}
+
+// configFinder is the default Finder. It picks the finder named by the
+// finder key of the config file, or the prompt on a dumb terminal.
+type configFinder struct {
+	c *Client
+}
+
+func (f configFinder) Find(ctx context.Context, items []string, preview func(i int) string) (int, error) {
+	finder, err := f.c.configuredFinder()
+	if err != nil {
+		return 0, err
+	}
+	return finder.Find(ctx, items, preview)
+}
+
+func (f configFinder) FindMulti(ctx context.Context, items []string, preview func(i int) string) ([]int, error) {
+	finder, err := f.c.configuredFinder()
+	if err != nil {
+		return nil, err
+	}
+	return finder.FindMulti(ctx, items, preview)
+}
+
+func (f configFinder) Select(ctx context.Context, items []string, preview func(i int) string, opts SelectOptions) (Selection, error) {
+	finder, err := f.c.configuredFinder()
+	if err != nil {
+		return Selection{}, err
+	}
+	return selectItem(ctx, finder, items, preview, opts)
+}
+
+func (c *Client) configuredFinder() (Finder, error) {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Finder {
+	case "", "builtin":
+		if c.osGetenv("TERM") == "dumb" {
+			return promptFinder{c: c}, nil
+		}
+		return fuzzyFinder{c: c}, nil
+	case "prompt":
+		return promptFinder{c: c}, nil
+	}
+	fields := strings.Fields(cfg.Finder)
+	return commandFinder{c: c, name: fields[0], args: fields[1:]}, nil
+}
+
+// commandFinder is the Finder running an external process. Only fzf and sk
+// tell the keys apart and print the query.
+type commandFinder struct {
+	c    *Client
+	name string
+	args []string
+}
+
+func (f commandFinder) Find(ctx context.Context, items []string, preview func(i int) string) (int, error) {
+	out, err := f.run(ctx, items, f.args, false)
+	if err != nil {
+		return 0, err
+	}
+	ids, err := f.indexes(items, out)
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (f commandFinder) FindMulti(ctx context.Context, items []string, preview func(i int) string) ([]int, error) {
+	args := f.args
+	if f.fzfLike() {
+		args = append([]string{"--multi"}, args...)
+	}
+	out, err := f.run(ctx, items, args, false)
+	if err != nil {
+		return nil, err
+	}
+	return f.indexes(items, out)
+}
+
+func (f commandFinder) Select(ctx context.Context, items []string, preview func(i int) string, opts SelectOptions) (Selection, error) {
+	if !f.fzfLike() {
+		id, err := f.Find(ctx, items, preview)
+		return Selection{Index: id}, err
+	}
+
+	args := f.args
+	if len(opts.Keys) != 0 {
+		args = append([]string{"--expect=" + strings.Join(opts.Keys, ",")}, args...)
+	}
+	if opts.AllowQuery {
+		args = append([]string{"--print-query"}, args...)
+	}
+	out, err := f.run(ctx, items, args, opts.AllowQuery)
+	if err != nil {
+		return Selection{}, err
+	}
+
+	var selection Selection
+	if opts.AllowQuery {
+		selection.Query, out = out[0], out[1:]
+	}
+	if len(opts.Keys) != 0 && len(out) != 0 {
+		selection.Key, out = out[0], out[1:]
+	}
+	ids, err := f.indexes(items, out)
+	if err == ErrAborted && opts.AllowQuery && len(strings.TrimSpace(selection.Query)) != 0 {
+		selection.Index = -1
+		return selection, nil
+	}
+	if err != nil {
+		return Selection{}, err
+	}
+	selection.Index = ids[0]
+	return selection, nil
+}
+
+func (f commandFinder) fzfLike() bool {
+	switch strings.TrimSuffix(filepath.Base(f.name), ".exe") {
+	case "fzf", "sk":
+		return true
+	}
+	return false
+}
+
+// run returns the lines printed by the finder. A finder exiting with status
+// 1 or 130, as fzf, sk and peco do when nothing is chosen, is an abort, except
+// that status 1 (no match) returns the printed query when printQuery is set.
+func (f commandFinder) run(ctx context.Context, items []string, args []string, printQuery bool) ([]string, error) {
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = strings.Replace(item, "\n", " ", -1)
+	}
+
+	cmd := f.c.execCommandContext(ctx, f.name, args...)
+	cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	cmd.Stderr = f.c.stderr
+	out, err := f.c.commandOutput(cmd)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s", f.name, err)
+		}
+		switch code := exitErr.ExitCode(); {
+		case code == 1 && printQuery:
+		case code == 1 || code == 130:
+			return nil, ErrAborted
+		default:
+			return nil, fmt.Errorf("%s: %s", f.name, err)
+		}
+	}
+
+	printed := strings.Split(strings.Replace(string(out), "\r\n", "\n", -1), "\n")
+	if len(printed) != 0 && printed[len(printed)-1] == "" {
+		printed = printed[:len(printed)-1]
+	}
+	return printed, nil
+}
+
+// indexes returns the items printed by the finder.
+func (f commandFinder) indexes(items []string, printed []string) ([]int, error) {
+	index := map[string]int{}
+	for i := len(items) - 1; i >= 0; i-- {
+		index[strings.Replace(items[i], "\n", " ", -1)] = i
+	}
+
+	var ids []int
+	for _, line := range printed {
+		if len(line) == 0 {
+			continue
+		}
+		i, ok := index[line]
+		if !ok {
+			return nil, fmt.Errorf("%s: selected %q, which is not a candidate", f.name, line)
+		}
+		ids = append(ids, i)
+	}
+	if len(ids) == 0 {
+		return nil, ErrAborted
+	}
+	return ids, nil
+}
+
+// promptFinder is the Finder for dumb terminals: it prints the items with
+// numbers and reads the chosen numbers. Any other input narrows the list to
+// the items containing it; an empty line or EOF aborts.
+type promptFinder struct {
+	c *Client
+}
+
+func (f promptFinder) Find(ctx context.Context, items []string, preview func(i int) string) (int, error) {
+	ids, _, err := f.prompt(ctx, items, promptOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (f promptFinder) FindMulti(ctx context.Context, items []string, preview func(i int) string) ([]int, error) {
+	ids, _, err := f.prompt(ctx, items, promptOptions{multi: true})
+	return ids, err
+}
+
+// Select understands editKey and promoteKey, typed as an "e" or a "p" after
+// the number. With AllowQuery, text that matches nothing is chosen when it is
+// typed twice.
+func (f promptFinder) Select(ctx context.Context, items []string, preview func(i int) string, opts SelectOptions) (Selection, error) {
+	p := promptOptions{allowQuery: opts.AllowQuery}
+	for _, key := range opts.Keys {
+		p.edit = p.edit || key == editKey
+		p.promote = p.promote || key == promoteKey
+	}
+	ids, key, err := f.prompt(ctx, items, p)
+	if err != nil {
+		return Selection{}, err
+	}
+	if ids == nil {
+		return Selection{Index: -1, Query: key}, nil
+	}
+	return Selection{Index: ids[0], Key: key}, nil
+}
+
+type promptOptions struct {
+	multi, edit, promote, allowQuery bool
+}
+
+// prompt returns the chosen items and the key. When p.allowQuery is set and
+// a query is chosen, the items are nil and the query takes the place of the
+// key.
+func (f promptFinder) prompt(ctx context.Context, items []string, p promptOptions) ([]int, string, error) {
+	multi, edit := p.multi, p.edit
+	var unmatched string
+	shown := make([]int, len(items))
+	for i := range items {
+		shown[i] = i
+	}
+
+	reader := bufio.NewReader(f.c.stdin)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, "", err
+		}
+		for n, i := range shown {
+			f.c.fmtFprintf(f.c.stdout, "%3d) %s\n", n+1, strings.Replace(items[i], "\n", " ", -1))
+		}
+		switch {
+		case multi:
+			f.c.fmtFprintf(f.c.stdout, "Numbers separated by spaces, or text to narrow the list: ")
+		case edit || p.promote:
+			var suffixes []string
+			if edit {
+				suffixes = append(suffixes, "e to edit it first")
+			}
+			if p.promote {
+				suffixes = append(suffixes, "p to promote it to a template")
+			}
+			f.c.fmtFprintf(f.c.stdout, "Number (followed by %s), or text to narrow the list: ", strings.Join(suffixes, " or "))
+		default:
+			f.c.fmtFprintf(f.c.stdout, "Number, or text to narrow the list: ")
+		}
+
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			if err != nil && err != io.EOF {
+				return nil, "", err
+			}
+			return nil, "", ErrAborted
+		}
+
+		if edit && strings.HasSuffix(line, "e") {
+			if ids, ok := parseChoice(strings.TrimSuffix(line, "e"), shown, false); ok {
+				return ids, editKey, nil
+			}
+		}
+		if p.promote && strings.HasSuffix(line, "p") {
+			if ids, ok := parseChoice(strings.TrimSuffix(line, "p"), shown, false); ok {
+				return ids, promoteKey, nil
+			}
+		}
+		if ids, ok := parseChoice(line, shown, multi); ok {
+			return ids, "", nil
+		}
+
+		var narrowed []int
+		for _, i := range shown {
+			if strings.Contains(strings.ToLower(items[i]), strings.ToLower(line)) {
+				narrowed = append(narrowed, i)
+			}
+		}
+		switch {
+		case len(narrowed) != 0:
+			shown = narrowed
+			unmatched = ""
+		case p.allowQuery && line == unmatched:
+			return nil, line, nil
+		case p.allowQuery:
+			f.c.fmtFprintf(f.c.stdout, "No candidate contains %q. Type it again to use it as a new message.\n", line)
+			unmatched = line
+		default:
+			f.c.fmtFprintf(f.c.stdout, "No candidate contains %q.\n", line)
+		}
+		if err == io.EOF {
+			return nil, "", ErrAborted
+		}
+	}
+}
+
+// parseChoice returns the items numbered by line, which counts from 1 in the
+// list shown.
+func parseChoice(line string, shown []int, multi bool) ([]int, bool) {
+	fields := strings.Fields(strings.Replace(line, ",", " ", -1))
+	if !multi && len(fields) != 1 {
+		return nil, false
+	}
+
+	ids := make([]int, 0, len(fields))
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 1 || n > len(shown) {
+			return nil, false
+		}
+		ids = append(ids, shown[n-1])
+	}
+	return ids, true
+}
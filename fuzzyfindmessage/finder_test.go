@@ -0,0 +1,423 @@
+package fuzzyfindmessage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+)
+
+func Test_configuredFinder(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		term   string
+		want   Finder
+	}{
+		{
+			name:   "NormalBuiltin",
+			config: "",
+			term:   "xterm-256color",
+			want:   fuzzyFinder{},
+		},
+		{
+			name:   "NormalDumbTerminal",
+			config: "",
+			term:   "dumb",
+			want:   promptFinder{},
+		},
+		{
+			name:   "NormalPrompt",
+			config: "finder = prompt\n",
+			term:   "xterm-256color",
+			want:   promptFinder{},
+		},
+		{
+			name:   "NormalCommand",
+			config: "finder = fzf --height 40%\n",
+			term:   "dumb",
+			want:   commandFinder{name: "fzf", args: []string{"--height", "40%"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			if err := ioutil.WriteFile(c.configFilePath, []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			c.osGetenv = func(key string) string {
+				if key == "TERM" {
+					return tt.term
+				}
+				return ""
+			}
+			got, err := c.configuredFinder()
+			if err != nil {
+				t.Fatalf("configuredFinder() error = %v", err)
+			}
+			switch f := got.(type) {
+			case fuzzyFinder:
+				f.c = nil
+				got = f
+			case promptFinder:
+				f.c = nil
+				got = f
+			case commandFinder:
+				f.c = nil
+				got = f
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("configuredFinder() got = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_commandFinderFind(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		want    int
+		wantErr error
+	}{
+		{
+			name:    "Normal",
+			script:  "sed -n 2p",
+			want:    1,
+			wantErr: nil,
+		},
+		{
+			name:    "NormalMultiLineItem",
+			script:  "grep 'Fix hoge body'",
+			want:    2,
+			wantErr: nil,
+		},
+		{
+			name:    "ErrorBecauseAborted",
+			script:  "cat >/dev/null; exit 130",
+			want:    0,
+			wantErr: fuzzyfinder.ErrAbort,
+		},
+		{
+			name:    "ErrorBecauseNothingSelected",
+			script:  "cat >/dev/null",
+			want:    0,
+			wantErr: fuzzyfinder.ErrAbort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			f := commandFinder{c: c, name: "sh", args: []string{"-c", tt.script}}
+			got, err := f.Find(context.Background(), []string{"Add hoge", "Fix fuga", "Fix hoge\nFix hoge body"}, nil)
+			if err != tt.wantErr {
+				t.Fatalf("Find() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Find() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_commandFinderFindMulti(t *testing.T) {
+	c, _ := newTempClient(t)
+	r := &recordingRunner{out: []byte("Add hoge\nFix fuga\n")}
+	WithRunner(r)(c)
+	WithCommandFinder("fzf", "--height", "40%")(c)
+
+	got, err := c.finder.FindMulti(context.Background(), []string{"Add hoge", "Fix fuga", "Fix hoge"}, nil)
+	if err != nil {
+		t.Fatalf("FindMulti() error = %v", err)
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindMulti() got = %v, want %v", got, want)
+	}
+	if want := []string{"fzf", "--multi", "--height", "40%"}; !reflect.DeepEqual(r.ran[0].Args, want) {
+		t.Errorf("FindMulti() ran %v, want %v", r.ran[0].Args, want)
+	}
+}
+
+func Test_promptFinder(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		multi   bool
+		want    []int
+		wantErr error
+	}{
+		{
+			name:    "Normal",
+			input:   "2\n",
+			multi:   false,
+			want:    []int{1},
+			wantErr: nil,
+		},
+		{
+			name:    "NormalNarrowed",
+			input:   "fix\n2\n",
+			multi:   false,
+			want:    []int{2},
+			wantErr: nil,
+		},
+		{
+			name:    "NormalMulti",
+			input:   "1, 3\n",
+			multi:   true,
+			want:    []int{0, 2},
+			wantErr: nil,
+		},
+		{
+			name:    "NormalNoNewline",
+			input:   "3",
+			multi:   false,
+			want:    []int{2},
+			wantErr: nil,
+		},
+		{
+			name:    "ErrorBecauseEmptyLine",
+			input:   "\n",
+			multi:   false,
+			want:    nil,
+			wantErr: fuzzyfinder.ErrAbort,
+		},
+		{
+			name:    "ErrorBecauseEOF",
+			input:   "hoge\n",
+			multi:   false,
+			want:    nil,
+			wantErr: fuzzyfinder.ErrAbort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c := New(WithStdio(strings.NewReader(tt.input), &stdout, &stdout), WithPromptFinder())
+			items := []string{"Add hoge", "Fix fuga", "Fix hoge"}
+
+			var got []int
+			var err error
+			if tt.multi {
+				got, err = c.finder.FindMulti(context.Background(), items, nil)
+			} else {
+				var id int
+				id, err = c.finder.Find(context.Background(), items, nil)
+				got = []int{id}
+			}
+			if err != tt.wantErr {
+				t.Fatalf("prompt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prompt() got = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(stdout.String(), "  1) Add hoge") {
+				t.Errorf("prompt() printed %q", stdout.String())
+			}
+		})
+	}
+}
+
+func Test_commandFinderSelect(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		out      string
+		want     Selection
+		wantArgs []string
+	}{
+		{
+			name:     "NormalKey",
+			command:  "fzf",
+			out:      "ctrl-e\nFix fuga\n",
+			want:     Selection{Index: 1, Key: "ctrl-e"},
+			wantArgs: []string{"fzf", "--expect=ctrl-e,ctrl-t", "--reverse"},
+		},
+		{
+			name:     "NormalEnter",
+			command:  "sk",
+			out:      "\nFix fuga\n",
+			want:     Selection{Index: 1, Key: ""},
+			wantArgs: []string{"sk", "--expect=ctrl-e,ctrl-t", "--reverse"},
+		},
+		{
+			name:     "NormalNoKeys",
+			command:  "peco",
+			out:      "Fix fuga\n",
+			want:     Selection{Index: 1, Key: ""},
+			wantArgs: []string{"peco", "--reverse"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			r := &recordingRunner{out: []byte(tt.out)}
+			WithRunner(r)(c)
+			WithCommandFinder(tt.command, "--reverse")(c)
+
+			got, err := selectItem(context.Background(), c.finder, []string{"Add hoge", "Fix fuga"}, nil, SelectOptions{Keys: []string{editKey, promoteKey}})
+			if err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Select() got = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(r.ran[0].Args, tt.wantArgs) {
+				t.Errorf("Select() ran %v, want %v", r.ran[0].Args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func Test_commandFinderSelectQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		script     string
+		allowQuery bool
+		want       Selection
+		wantErr    error
+	}{
+		{
+			name:       "NormalNoMatch",
+			script:     "printf 'Add piyo\\n\\n'; exit 1",
+			allowQuery: true,
+			want:       Selection{Index: -1, Query: "Add piyo"},
+			wantErr:    nil,
+		},
+		{
+			name:       "NormalMatch",
+			script:     "printf 'fuga\\nctrl-e\\nFix fuga\\n'",
+			allowQuery: true,
+			want:       Selection{Index: 1, Key: "ctrl-e", Query: "fuga"},
+			wantErr:    nil,
+		},
+		{
+			name:       "ErrorBecauseEmptyQuery",
+			script:     "printf '\\n\\n'; exit 1",
+			allowQuery: true,
+			want:       Selection{},
+			wantErr:    fuzzyfinder.ErrAbort,
+		},
+		{
+			name:       "ErrorBecauseQueryNotAllowed",
+			script:     "exit 1",
+			allowQuery: false,
+			want:       Selection{},
+			wantErr:    fuzzyfinder.ErrAbort,
+		},
+		{
+			name:       "ErrorBecauseAborted",
+			script:     "printf 'Add piyo\\n\\n'; exit 130",
+			allowQuery: true,
+			want:       Selection{},
+			wantErr:    fuzzyfinder.ErrAbort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newTempClient(t)
+			// Select only asks fzf and sk for the query.
+			fzf := filepath.Join(dir, "fzf")
+			script := "#!/bin/sh\ncat >/dev/null\n" + tt.script + "\n"
+			if err := ioutil.WriteFile(fzf, []byte(script), 0755); err != nil {
+				t.Fatal(err)
+			}
+			f := commandFinder{c: c, name: fzf}
+			got, err := f.Select(context.Background(), []string{"Add hoge", "Fix fuga"}, nil, SelectOptions{Keys: []string{editKey}, AllowQuery: tt.allowQuery})
+			if err != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Select() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_promptFinderSelect(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Selection
+	}{
+		{
+			name:  "NormalEnter",
+			input: "2\n",
+			want:  Selection{Index: 1, Key: ""},
+		},
+		{
+			name:  "NormalEdit",
+			input: "2e\n",
+			want:  Selection{Index: 1, Key: editKey},
+		},
+		{
+			name:  "NormalPromote",
+			input: "1p\n",
+			want:  Selection{Index: 0, Key: promoteKey},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c := New(WithStdio(strings.NewReader(tt.input), &stdout, &stdout), WithPromptFinder())
+			got, err := selectItem(context.Background(), c.finder, []string{"Add hoge", "Fix fuga"}, nil, SelectOptions{Keys: []string{editKey, promoteKey}})
+			if err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Select() got = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(stdout.String(), "followed by e to edit it first or p to promote") {
+				t.Errorf("Select() printed %q", stdout.String())
+			}
+		})
+	}
+}
+
+func Test_promptFinderSelectQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Selection
+		wantErr error
+	}{
+		{
+			name:    "NormalTypedTwice",
+			input:   "Add piyo\nAdd piyo\n",
+			want:    Selection{Index: -1, Query: "Add piyo"},
+			wantErr: nil,
+		},
+		{
+			name:    "NormalNarrowedInBetween",
+			input:   "Add piyo\nfuga\nAdd piyo\n1\n",
+			want:    Selection{Index: 1},
+			wantErr: nil,
+		},
+		{
+			name:    "ErrorBecauseTypedOnce",
+			input:   "Add piyo\n\n",
+			want:    Selection{},
+			wantErr: fuzzyfinder.ErrAbort,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			c := New(WithStdio(strings.NewReader(tt.input), &stdout, &stdout), WithPromptFinder())
+			got, err := selectItem(context.Background(), c.finder, []string{"Add hoge", "Fix fuga"}, nil, SelectOptions{AllowQuery: true})
+			if err != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Select() got = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(stdout.String(), "Type it again") {
+				t.Errorf("Select() printed %q", stdout.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,372 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/ktr0731/go-fuzzyfinder/matching"
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+)
+
+// fuzzyFinder is the builtin Finder. It looks and matches like go-fuzzyfinder
+// but draws on the screen of the Client, so that canceling ctx closes it:
+// go-fuzzyfinder itself cannot be interrupted.
+type fuzzyFinder struct {
+	c *Client
+}
+
+func (f fuzzyFinder) Find(ctx context.Context, items []string, preview func(i int) string) (int, error) {
+	st := newFinderState(items, false)
+	if err := f.run(ctx, st, preview); err != nil {
+		return 0, err
+	}
+	return st.chosen()[0], nil
+}
+
+func (f fuzzyFinder) FindMulti(ctx context.Context, items []string, preview func(i int) string) ([]int, error) {
+	st := newFinderState(items, true)
+	if err := f.run(ctx, st, preview); err != nil {
+		return nil, err
+	}
+	return st.chosen(), nil
+}
+
+// Select binds opts.Keys from ctrl-a to ctrl-z, in place of their editing
+// functions such as ctrl-e moving to the end of the query. With
+// opts.AllowQuery, Enter chooses a query that matches nothing.
+func (f fuzzyFinder) Select(ctx context.Context, items []string, preview func(i int) string, opts SelectOptions) (Selection, error) {
+	st := newFinderState(items, false)
+	st.allowQuery = opts.AllowQuery
+	for _, name := range opts.Keys {
+		key, ok := termboxKey(name)
+		if !ok {
+			return Selection{}, fmt.Errorf("the builtin finder cannot bind %s", name)
+		}
+		st.keys[key] = name
+	}
+	if err := f.run(ctx, st, preview); err != nil {
+		return Selection{}, err
+	}
+	if len(st.matched) == 0 {
+		return Selection{Index: -1, Query: string(st.query)}, nil
+	}
+	return Selection{Index: st.chosen()[0], Key: st.key, Query: string(st.query)}, nil
+}
+
+// termboxKey returns the termbox key of name in fzf notation, from ctrl-a to
+// ctrl-z.
+func termboxKey(name string) (termbox.Key, bool) {
+	if len(name) != len("ctrl-a") || !strings.HasPrefix(name, "ctrl-") || name[5] < 'a' || name[5] > 'z' {
+		return 0, false
+	}
+	return termbox.KeyCtrlA + termbox.Key(name[5]-'a'), true
+}
+
+// run lets the user choose with st until an item is chosen.
+func (f fuzzyFinder) run(ctx context.Context, st *finderState, preview func(i int) string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s := f.c.screen
+	if err := s.Init(); err != nil {
+		return err
+	}
+	defer s.Close()
+	events, stop := pollEvents(s)
+	defer stop()
+
+	for {
+		if err := st.draw(s, preview); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-events:
+			switch ev.Type {
+			case termbox.EventError:
+				return ev.Err
+			case termbox.EventKey:
+				switch st.handle(ev) {
+				case findDone:
+					return nil
+				case findAborted:
+					return ErrAborted
+				}
+			}
+		}
+	}
+}
+
+type findResult int
+
+const (
+	finding findResult = iota
+	findDone
+	findAborted
+)
+
+// finderState is the query typed into the builtin finder and the items it
+// matches, best first. cursor is the index in matched of the highlighted
+// item and top the first one shown; x is the position in the query. In
+// multi mode, selected maps the items chosen with Tab to the order they
+// were chosen in, counted by order. keys are the keys that choose an item
+// besides Enter, and key the one that did. allowQuery lets Enter choose a
+// query that matches nothing.
+type finderState struct {
+	items      []string
+	multi      bool
+	keys       map[termbox.Key]string
+	key        string
+	allowQuery bool
+	query      []rune
+	x          int
+	matched    []matching.Matched
+	cursor     int
+	top        int
+	selected   map[int]int
+	order      int
+}
+
+func newFinderState(items []string, multi bool) *finderState {
+	st := &finderState{items: items, multi: multi, keys: map[termbox.Key]string{}, selected: map[int]int{}}
+	st.filter()
+	return st
+}
+
+// filter matches the items against the query. All of them match an empty
+// query, in their order.
+func (st *finderState) filter() {
+	st.cursor, st.top = 0, 0
+	if len(st.query) != 0 {
+		st.matched = matching.FindAll(string(st.query), st.items)
+		return
+	}
+	st.matched = make([]matching.Matched, len(st.items))
+	for i := range st.items {
+		st.matched[i] = matching.Matched{Idx: i, Pos: [2]int{-1, -1}}
+	}
+}
+
+// chosen returns the items chosen with Tab in the order they were chosen,
+// or else the highlighted one.
+func (st *finderState) chosen() []int {
+	if len(st.selected) == 0 {
+		return []int{st.matched[st.cursor].Idx}
+	}
+	ids := make([]int, 0, len(st.selected))
+	for i := range st.selected {
+		ids = append(ids, i)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return st.selected[ids[i]] < st.selected[ids[j]]
+	})
+	return ids
+}
+
+func (st *finderState) handle(ev termbox.Event) findResult {
+	if name, ok := st.keys[ev.Key]; ok && ev.Ch == 0 {
+		// A bound key does nothing without a match rather than fall back
+		// to its editing function, e.g. ctrl-e moving to the end.
+		if len(st.matched) == 0 {
+			return finding
+		}
+		st.key = name
+		return findDone
+	}
+	switch ev.Key {
+	case termbox.KeyEsc, termbox.KeyCtrlC, termbox.KeyCtrlD:
+		return findAborted
+	case termbox.KeyEnter:
+		// In multi mode, the items chosen with Tab are kept while the
+		// query matches nothing.
+		if len(st.matched) != 0 || len(st.selected) != 0 || st.allowQuery && len(strings.TrimSpace(string(st.query))) != 0 {
+			return findDone
+		}
+		return findAborted
+	case termbox.KeyArrowUp, termbox.KeyCtrlK, termbox.KeyCtrlP:
+		if st.cursor+1 < len(st.matched) {
+			st.cursor++
+		}
+	case termbox.KeyArrowDown, termbox.KeyCtrlJ, termbox.KeyCtrlN:
+		if st.cursor > 0 {
+			st.cursor--
+		}
+	case termbox.KeyTab:
+		if !st.multi || len(st.matched) == 0 {
+			break
+		}
+		i := st.matched[st.cursor].Idx
+		if _, ok := st.selected[i]; ok {
+			delete(st.selected, i)
+		} else {
+			st.selected[i] = st.order
+			st.order++
+		}
+		if st.cursor > 0 {
+			st.cursor--
+		}
+	case termbox.KeyArrowLeft, termbox.KeyCtrlB:
+		if st.x != 0 {
+			st.x--
+		}
+	case termbox.KeyArrowRight, termbox.KeyCtrlF:
+		if st.x != len(st.query) {
+			st.x++
+		}
+	case termbox.KeyHome, termbox.KeyCtrlA:
+		st.x = 0
+	case termbox.KeyEnd, termbox.KeyCtrlE:
+		st.x = len(st.query)
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if st.x != 0 {
+			st.query = append(st.query[:st.x-1], st.query[st.x:]...)
+			st.x--
+			st.filter()
+		}
+	case termbox.KeyDelete:
+		if st.x != len(st.query) {
+			st.query = append(st.query[:st.x], st.query[st.x+1:]...)
+			st.filter()
+		}
+	case termbox.KeyCtrlU:
+		st.query = st.query[st.x:]
+		st.x = 0
+		st.filter()
+	case termbox.KeyCtrlW:
+		start := st.x
+		for start > 0 && unicode.IsSpace(st.query[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(st.query[start-1]) {
+			start--
+		}
+		st.query = append(st.query[:start], st.query[st.x:]...)
+		st.x = start
+		st.filter()
+	case termbox.KeySpace:
+		st.insert(' ')
+	default:
+		if ev.Ch != 0 {
+			st.insert(ev.Ch)
+		}
+	}
+	return finding
+}
+
+func (st *finderState) insert(r rune) {
+	st.query = append(st.query[:st.x], append([]rune{r}, st.query[st.x:]...)...)
+	st.x++
+	st.filter()
+}
+
+const finderPrompt = "> "
+
+// draw shows the query on the last line, the number of matches above it and
+// the matches above them, best first from the bottom, as go-fuzzyfinder
+// does. The preview of the highlighted item takes the right half.
+func (st *finderState) draw(s screen, preview func(i int) string) error {
+	width, height := s.Size()
+	s.Clear()
+
+	listWidth := width
+	if preview != nil {
+		listWidth = width/2 - 1
+	}
+	drawText(s, 0, height-1, listWidth, []rune(finderPrompt), -1, termbox.ColorBlue)
+	x := runewidth.StringWidth(finderPrompt)
+	cursorX := drawText(s, x, height-1, listWidth, st.query, st.x, termbox.ColorDefault|termbox.AttrBold)
+	count := fmt.Sprintf("%d/%d", len(st.matched), len(st.items))
+	drawText(s, 2, height-2, listWidth, []rune(count), -1, termbox.ColorYellow)
+
+	// Keep the highlighted item on the screen above the count.
+	rows := height - 2
+	if st.cursor < st.top {
+		st.top = st.cursor
+	}
+	if rows > 0 && st.cursor >= st.top+rows {
+		st.top = st.cursor - rows + 1
+	}
+	for i := st.top; i < len(st.matched) && i-st.top < rows; i++ {
+		st.drawItem(s, height-3-(i-st.top), listWidth, i)
+	}
+
+	if preview != nil {
+		i := -1
+		if len(st.matched) != 0 {
+			i = st.matched[st.cursor].Idx
+		}
+		drawPreview(s, width/2, width, height, preview(i))
+	}
+
+	s.SetCursor(cursorX, height-1)
+	return s.Flush()
+}
+
+// drawItem draws the i-th match on row y with the runes matching the query
+// highlighted, the way go-fuzzyfinder does.
+func (st *finderState) drawItem(s screen, y, width, i int) {
+	m := st.matched[i]
+	bg := termbox.ColorDefault
+	if i == st.cursor {
+		s.SetCell(0, y, '>', termbox.ColorRed, termbox.ColorBlack)
+		s.SetCell(1, y, ' ', termbox.ColorRed, termbox.ColorBlack)
+		bg = termbox.ColorBlack
+	}
+	if _, ok := st.selected[m.Idx]; ok {
+		s.SetCell(1, y, '>', termbox.ColorRed, termbox.ColorBlack)
+	}
+
+	item := []rune(strings.Replace(st.items[m.Idx], "\n", " ", -1))
+	q := 0
+	x := 2
+	for j, r := range item {
+		fg := termbox.ColorDefault
+		if q < len(st.query) && m.Pos[0] <= j && j <= m.Pos[1] && unicode.ToLower(st.query[q]) == unicode.ToLower(r) {
+			fg |= termbox.ColorGreen
+			q++
+		}
+		if i == st.cursor {
+			fg |= termbox.AttrBold | termbox.ColorYellow
+		}
+		w := runewidth.RuneWidth(r)
+		if x+w+2 > width {
+			s.SetCell(x, y, '.', fg, bg)
+			s.SetCell(x+1, y, '.', fg, bg)
+			return
+		}
+		s.SetCell(x, y, r, fg, bg)
+		x += w
+	}
+}
+
+// drawPreview draws text in a box from column left to right.
+func drawPreview(s screen, left, right, height int, text string) {
+	if right-left < 4 || height < 2 {
+		return
+	}
+	border := termbox.ColorBlack
+	for x := left + 1; x < right-1; x++ {
+		s.SetCell(x, 0, '─', border, termbox.ColorDefault)
+		s.SetCell(x, height-1, '─', border, termbox.ColorDefault)
+	}
+	for y := 1; y < height-1; y++ {
+		s.SetCell(left, y, '│', border, termbox.ColorDefault)
+		s.SetCell(right-1, y, '│', border, termbox.ColorDefault)
+	}
+	s.SetCell(left, 0, '┌', border, termbox.ColorDefault)
+	s.SetCell(right-1, 0, '┐', border, termbox.ColorDefault)
+	s.SetCell(left, height-1, '└', border, termbox.ColorDefault)
+	s.SetCell(right-1, height-1, '┘', border, termbox.ColorDefault)
+
+	for y, line := range strings.Split(text, "\n") {
+		if y+1 >= height-1 {
+			break
+		}
+		drawText(s, left+2, y+1, right-2, []rune(line), -1, termbox.ColorDefault)
+	}
+}
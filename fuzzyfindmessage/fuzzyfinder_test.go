@@ -0,0 +1,243 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nsf/termbox-go"
+)
+
+func Test_fuzzyFinderFind(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []termbox.Event
+		want    int
+		wantErr error
+	}{
+		{
+			name:    "Normal",
+			events:  keys("fuga", termbox.KeyEnter),
+			want:    1,
+			wantErr: nil,
+		},
+		{
+			name:    "NormalFirstItem",
+			events:  keys("", termbox.KeyEnter),
+			want:    0,
+			wantErr: nil,
+		},
+		{
+			name:    "NormalMoveUp",
+			events:  keys("", termbox.KeyArrowUp, termbox.KeyEnter),
+			want:    1,
+			wantErr: nil,
+		},
+		{
+			name:    "NormalEditQuery",
+			events:  keys("fx", termbox.KeyBackspace2, termbox.KeyCtrlA, termbox.KeyCtrlE, termbox.KeyEnter),
+			want:    1,
+			wantErr: nil,
+		},
+		{
+			name:    "ErrorBecauseAborted",
+			events:  keys("", termbox.KeyEsc),
+			want:    0,
+			wantErr: ErrAborted,
+		},
+		{
+			name:    "ErrorBecauseNothingMatches",
+			events:  keys("piyo", termbox.KeyEnter),
+			want:    0,
+			wantErr: ErrAborted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFakeScreen(tt.events...)
+			c := New()
+			c.screen = s
+			got, err := (fuzzyFinder{c: c}).Find(context.Background(), []string{"hoge", "fuga"}, nil)
+			if err != tt.wantErr {
+				t.Fatalf("Find() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Find() got = %v, want %v", got, tt.want)
+			}
+			if !s.closed {
+				t.Errorf("Find() did not close the screen")
+			}
+		})
+	}
+}
+
+func Test_fuzzyFinderFindMulti(t *testing.T) {
+	s := newFakeScreen(keys("", termbox.KeyArrowUp, termbox.KeyTab, termbox.KeyTab, termbox.KeyEnter)...)
+	c := New()
+	c.screen = s
+	got, err := (fuzzyFinder{c: c}).FindMulti(context.Background(), []string{"hoge", "fuga", "piyo"}, nil)
+	if err != nil {
+		t.Fatalf("FindMulti() error = %v", err)
+	}
+	if want := []int{1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindMulti() got = %v, want %v", got, want)
+	}
+}
+
+func Test_fuzzyFinderFindMultiNothingMatches(t *testing.T) {
+	s := newFakeScreen(append(keys("", termbox.KeyTab), keys("zzz", termbox.KeyEnter)...)...)
+	c := New()
+	c.screen = s
+	got, err := (fuzzyFinder{c: c}).FindMulti(context.Background(), []string{"hoge", "fuga", "piyo"}, nil)
+	if err != nil {
+		t.Fatalf("FindMulti() error = %v", err)
+	}
+	if want := []int{0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindMulti() got = %v, want %v", got, want)
+	}
+}
+
+func Test_fuzzyFinderSelect(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		allowQuery bool
+		events     []termbox.Event
+		want       Selection
+		wantErr    bool
+	}{
+		{
+			name:       "Normal",
+			keys:       []string{editKey},
+			allowQuery: true,
+			events:     keys("fuga", termbox.KeyEnter),
+			want:       Selection{Index: 1, Query: "fuga"},
+			wantErr:    false,
+		},
+		{
+			name:       "NormalKey",
+			keys:       []string{editKey},
+			allowQuery: false,
+			events:     keys("fuga", termbox.KeyCtrlE),
+			want:       Selection{Index: 1, Key: editKey, Query: "fuga"},
+			wantErr:    false,
+		},
+		{
+			name:       "NormalUnboundKeyEdits",
+			keys:       nil,
+			allowQuery: false,
+			events:     keys("fuga", termbox.KeyCtrlE, termbox.KeyEnter),
+			want:       Selection{Index: 1, Query: "fuga"},
+			wantErr:    false,
+		},
+		{
+			name:       "NormalBoundKeyWithoutMatchDoesNotEdit",
+			keys:       []string{editKey},
+			allowQuery: true,
+			events:     append(keys("Add piyo", termbox.KeyCtrlA, termbox.KeyCtrlE), keys("x", termbox.KeyEnter)...),
+			want:       Selection{Index: -1, Query: "xAdd piyo"},
+			wantErr:    false,
+		},
+		{
+			name:       "NormalQuery",
+			keys:       []string{editKey},
+			allowQuery: true,
+			events:     keys("Add piyo", termbox.KeyEnter),
+			want:       Selection{Index: -1, Query: "Add piyo"},
+			wantErr:    false,
+		},
+		{
+			name:       "ErrorBecauseQueryIsNotAllowed",
+			keys:       []string{editKey},
+			allowQuery: false,
+			events:     keys("Add piyo", termbox.KeyEnter),
+			want:       Selection{},
+			wantErr:    true,
+		},
+		{
+			name:       "ErrorBecauseQueryIsBlank",
+			keys:       []string{editKey},
+			allowQuery: true,
+			events:     keys("  ", termbox.KeyEnter),
+			want:       Selection{},
+			wantErr:    true,
+		},
+		{
+			name:       "ErrorBecauseKeyCannotBeBound",
+			keys:       []string{"alt-e"},
+			allowQuery: false,
+			events:     nil,
+			want:       Selection{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New()
+			c.screen = newFakeScreen(tt.events...)
+			opts := SelectOptions{Keys: tt.keys, AllowQuery: tt.allowQuery}
+			got, err := (fuzzyFinder{c: c}).Select(context.Background(), []string{"hoge", "fuga"}, nil, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Select() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Select() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fuzzyFinderFindCanceled(t *testing.T) {
+	c := New()
+	c.screen = newFakeScreen()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := (fuzzyFinder{c: c}).Find(ctx, []string{"hoge"}, nil); err != context.Canceled {
+		t.Errorf("Find() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func Test_fuzzyFinderFindCanceledWhileOpen(t *testing.T) {
+	s := newFakeScreen()
+	s.flushed = make(chan struct{}, 1)
+	c := New()
+	c.screen = s
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-s.flushed
+		cancel()
+	}()
+	if _, err := (fuzzyFinder{c: c}).Find(ctx, []string{"hoge"}, nil); err != context.Canceled {
+		t.Errorf("Find() error = %v, want %v", err, context.Canceled)
+	}
+	if !s.closed {
+		t.Errorf("Find() did not close the screen")
+	}
+}
+
+func Test_finderStateDraw(t *testing.T) {
+	s := newFakeScreen()
+	st := newFinderState([]string{"Add hoge", "Fix fuga"}, false)
+	for _, ev := range keys("fix") {
+		st.handle(ev)
+	}
+	if err := st.draw(s, func(i int) string {
+		return "preview of " + st.items[i]
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.line(s.height - 1); !strings.HasPrefix(got, finderPrompt+"fix") {
+		t.Errorf("draw() prompt = %q", got)
+	}
+	if got := s.line(s.height - 2); !strings.HasPrefix(got, "1/2") {
+		t.Errorf("draw() count = %q, want 1/2", got)
+	}
+	if got := s.line(s.height - 3); !strings.HasPrefix(got, "> Fix fuga") {
+		t.Errorf("draw() item = %q", got)
+	}
+	if got := s.line(1); !strings.Contains(got, "preview of Fix fuga") {
+		t.Errorf("draw() preview = %q", got)
+	}
+}
@@ -0,0 +1,301 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Git is the repository of the working directory, which fcm reads from and
+// commits to. The git binary failing is reported as a *GitError.
+type Git interface {
+	// Commit commits with the message in fileName, opening it in the editor
+	// of git first when edit is true. A message emptied in the editor is
+	// ErrAborted.
+	Commit(ctx context.Context, fileName string, edit bool) error
+	// HeadCommit returns the hash of HEAD, or "" on an unborn branch.
+	HeadCommit(ctx context.Context) (string, error)
+	// HeadMessage returns the message of the HEAD commit.
+	HeadMessage(ctx context.Context) (string, error)
+	// Branch returns the name of the current branch, or "" when HEAD is
+	// detached.
+	Branch(ctx context.Context) (string, error)
+	// StagedDiff returns the changes to be committed as a unified diff.
+	StagedDiff(ctx context.Context) (string, error)
+	// HasStagedChanges tells whether there is something to commit without
+	// computing the diff: the index differs from HEAD, or a merge is in
+	// progress, which git commits even when its tree is the one of HEAD.
+	HasStagedChanges(ctx context.Context) (bool, error)
+	// Config returns every value of a git config key, or nil when it is not
+	// set.
+	Config(ctx context.Context, key string) ([]string, error)
+	// TopLevel returns the root of the working tree, or "" outside of a
+	// repository.
+	TopLevel(ctx context.Context) (string, error)
+	// HooksDir returns the absolute path of the hooks directory, which
+	// core.hooksPath may move, or ErrNotARepo outside of a repository.
+	HooksDir(ctx context.Context) (string, error)
+	// Log returns the commits reachable from HEAD that opts selects, oldest
+	// first. Merge commits are left out.
+	Log(ctx context.Context, opts LogOptions) ([]LogEntry, error)
+	// EditorCommand returns the editor of git as a command line for the
+	// shell, as git var GIT_EDITOR prints it.
+	EditorCommand(ctx context.Context) (string, error)
+	// Version returns the version of the git binary, which commits even when
+	// the repository is read otherwise.
+	Version(ctx context.Context) (string, error)
+	// At returns the Git of the repository in dir instead of the working
+	// directory.
+	At(dir string) Git
+}
+
+// LogOptions selects the commits returned by Git.Log.
+type LogOptions struct {
+	// Author is a pattern as understood by `git log --author`.
+	Author string
+	// Since is a date as understood by `git log --since`, e.g. "6.months".
+	Since string
+}
+
+// LogEntry is a commit returned by Git.Log.
+type LogEntry struct {
+	Time    time.Time
+	Subject string
+	Message string
+}
+
+// WithGit replaces git from $PATH.
+func WithGit(g Git) Option {
+	return func(c *Client) {
+		c.git = g
+	}
+}
+
+// execGit is the Git running the git binary with the Runner of the Client,
+// in dir unless it is empty.
+type execGit struct {
+	c   *Client
+	dir string
+}
+
+// command returns the git command running args in the repository of g.
+func (g execGit) command(ctx context.Context, args ...string) *exec.Cmd {
+	if len(g.dir) != 0 {
+		args = append([]string{"-C", g.dir}, args...)
+	}
+	return g.c.execCommandContext(ctx, "git", args...)
+}
+
+func (g execGit) At(dir string) Git {
+	return execGit{c: g.c, dir: dir}
+}
+
+func (g execGit) Commit(ctx context.Context, fileName string, edit bool) error {
+	args := []string{"commit", "-F", fileName}
+	if edit {
+		args = append(args, "-e")
+	}
+	cmd := g.command(ctx, args...)
+	cmd.Stdin = g.c.stdin
+	cmd.Stdout = g.c.stdout
+	cmd.Stderr = g.c.stderr
+	if err := g.c.commandRun(cmd); err != nil {
+		if _, ok := err.(*exec.ExitError); ok && edit && g.messageEmptied(ctx) {
+			return ErrAborted
+		}
+		return gitError(args, err)
+	}
+	return nil
+}
+
+// messageEmptied tells whether the message left in the editor has nothing
+// but comments, which makes git abort the commit. git says so only in the
+// language of the user, so read the message itself.
+func (g execGit) messageEmptied(ctx context.Context) bool {
+	out, err := g.c.commandOutput(g.command(ctx, "rev-parse", "--git-path", "COMMIT_EDITMSG"))
+	if err != nil {
+		return false
+	}
+	path := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(path) && len(g.dir) != 0 {
+		path = filepath.Join(g.dir, path)
+	}
+	b, err := g.c.ioutilReadFile(path)
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) != 0 && line[0:1] != "#" {
+			return false
+		}
+	}
+	return true
+}
+
+func (g execGit) HeadMessage(ctx context.Context) (string, error) {
+	args := []string{"log", "-1", "--pretty=%B"}
+	out, err := g.c.commandOutput(g.command(ctx, args...))
+	if err != nil {
+		return "", gitError(args, err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func (g execGit) HeadCommit(ctx context.Context) (string, error) {
+	cmd := g.command(ctx, "rev-parse", "--verify", "-q", "HEAD")
+	out, err := g.c.commandOutput(cmd)
+	if err != nil {
+		// HEAD does not resolve yet on an unborn branch.
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g execGit) Branch(ctx context.Context) (string, error) {
+	cmd := g.command(ctx, "symbolic-ref", "--short", "-q", "HEAD")
+	out, err := g.c.commandOutput(cmd)
+	if err != nil {
+		// git symbolic-ref exits with 1 when HEAD is detached.
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g execGit) StagedDiff(ctx context.Context) (string, error) {
+	args := []string{"diff", "--cached", "--no-color"}
+	out, err := g.c.commandOutput(g.command(ctx, args...))
+	if err != nil {
+		return "", gitError(args, err)
+	}
+	return string(out), nil
+}
+
+func (g execGit) HasStagedChanges(ctx context.Context) (bool, error) {
+	args := []string{"diff", "--cached", "--quiet"}
+	err := g.c.commandRun(g.command(ctx, args...))
+	if err == nil {
+		return g.merging(ctx)
+	}
+	// git diff --quiet exits with 1 when there are changes.
+	if e, ok := err.(*exec.ExitError); ok && e.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, gitError(args, err)
+}
+
+// merging tells whether MERGE_HEAD exists, that is a merge is in progress.
+func (g execGit) merging(ctx context.Context) (bool, error) {
+	err := g.c.commandRun(g.command(ctx, "rev-parse", "-q", "--verify", "MERGE_HEAD"))
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (g execGit) Config(ctx context.Context, key string) ([]string, error) {
+	cmd := g.command(ctx, "config", "--get-all", key)
+	out, err := g.c.commandOutput(cmd)
+	if err != nil {
+		// git config exits with 1 when the key is not set.
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(out), "\n"), "\n"), nil
+}
+
+func (g execGit) TopLevel(ctx context.Context) (string, error) {
+	cmd := g.command(ctx, "rev-parse", "--show-toplevel")
+	out, err := g.c.commandOutput(cmd)
+	if err != nil {
+		// Outside of a repository commands run in the current directory.
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g execGit) HooksDir(ctx context.Context) (string, error) {
+	args := []string{"rev-parse", "--git-path", "hooks"}
+	out, err := g.c.commandOutput(g.command(ctx, args...))
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return "", ErrNotARepo
+		}
+		return "", err
+	}
+	// The path is relative to the working directory unless it is absolute.
+	return filepath.Abs(strings.TrimSpace(string(out)))
+}
+
+// Separators produced by the %x00 and %x1e placeholders of the log format.
+const (
+	logFieldSeparator  = "\x00"
+	logRecordSeparator = "\x1e"
+)
+
+func (g execGit) Log(ctx context.Context, opts LogOptions) ([]LogEntry, error) {
+	args := []string{"log", "--no-merges", "--reverse", "--format=%at%x00%s%x00%B%x1e"}
+	if len(opts.Author) != 0 {
+		args = append(args, "--author="+opts.Author)
+	}
+	if len(opts.Since) != 0 {
+		args = append(args, "--since="+opts.Since)
+	}
+	out, err := g.c.commandOutput(g.command(ctx, args...))
+	if err != nil {
+		return nil, gitError(args, err)
+	}
+
+	var entries []LogEntry
+	for _, record := range strings.Split(string(out), logRecordSeparator) {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), logFieldSeparator, 3)
+		if len(fields) != 3 {
+			continue
+		}
+		sec, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, LogEntry{
+			Time:    time.Unix(sec, 0),
+			Subject: fields[1],
+			Message: strings.TrimRight(fields[2], "\n"),
+		})
+	}
+	return entries, nil
+}
+
+func (g execGit) EditorCommand(ctx context.Context) (string, error) {
+	args := []string{"var", "GIT_EDITOR"}
+	out, err := g.c.commandOutput(g.command(ctx, args...))
+	if err != nil {
+		return "", gitError(args, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (g execGit) Version(ctx context.Context) (string, error) {
+	args := []string{"--version"}
+	out, err := g.c.commandOutput(g.command(ctx, args...))
+	if err != nil {
+		return "", gitError(args, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
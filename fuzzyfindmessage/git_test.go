@@ -0,0 +1,533 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_execGitCommit(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name               string
+		execCommandContext func(ctx context.Context, name string, arg ...string) *exec.Cmd
+		commandRun         func(cmd *exec.Cmd) error
+		fileName           string
+		wantErr            bool
+	}{
+		{
+			name: "Normal",
+			execCommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			},
+			commandRun: func(cmd *exec.Cmd) error {
+				return nil
+			},
+			fileName: "hoge",
+			wantErr:  false,
+		},
+		{
+			name: "ErrorBecauseCommandReturnError",
+			execCommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			},
+			commandRun: func(cmd *exec.Cmd) error {
+				return fmt.Errorf("error")
+			},
+			fileName: "hoge",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.execCommandContext = tt.execCommandContext
+			c.commandRun = tt.commandRun
+			if err := (execGit{c: c}).Commit(context.Background(), tt.fileName, true); (err != nil) != tt.wantErr {
+				t.Errorf("Commit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_execGitHeadMessage(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name               string
+		execCommandContext func(ctx context.Context, name string, arg ...string) *exec.Cmd
+		commandOutput      func(cmd *exec.Cmd) ([]byte, error)
+		want               string
+		wantErr            bool
+	}{
+		{
+			name: "Normal",
+			execCommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			},
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("hoge"), nil
+			},
+			want:    "hoge",
+			wantErr: false,
+		},
+		{
+			// git log prints the message as is, with a blank line after it.
+			name: "NormalMultiLine",
+			execCommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			},
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("Add hoge\n\nWith a body\n\n"), nil
+			},
+			want:    "Add hoge\n\nWith a body",
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseCommandReturnError",
+			execCommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			},
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte(""), fmt.Errorf("error")
+			},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.execCommandContext = tt.execCommandContext
+			c.commandOutput = tt.commandOutput
+			got, err := (execGit{c: c}).HeadMessage(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HeadMessage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("HeadMessage() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_execGitHeadCommit(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name               string
+		execCommandContext func(ctx context.Context, name string, arg ...string) *exec.Cmd
+		commandOutput      func(cmd *exec.Cmd) ([]byte, error)
+		want               string
+		wantErr            bool
+	}{
+		{
+			name: "Normal",
+			execCommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			},
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("hoge\n"), nil
+			},
+			want:    "hoge",
+			wantErr: false,
+		},
+		{
+			name: "NormalUnbornBranch",
+			execCommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			},
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, &exec.ExitError{}
+			},
+			want:    "",
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseCommandReturnError",
+			execCommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			},
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, fmt.Errorf("error")
+			},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.execCommandContext = tt.execCommandContext
+			c.commandOutput = tt.commandOutput
+			got, err := (execGit{c: c}).HeadCommit(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HeadCommit() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("HeadCommit() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_execGitTopLevel(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name          string
+		commandOutput func(cmd *exec.Cmd) ([]byte, error)
+		want          string
+		wantErr       bool
+	}{
+		{
+			name: "Normal",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("/repo\n"), nil
+			},
+			want:    "/repo",
+			wantErr: false,
+		},
+		{
+			name: "NormalNotARepository",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, &exec.ExitError{}
+			},
+			want:    "",
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseCommandReturnError",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, fmt.Errorf("error")
+			},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.execCommandContext = func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			}
+			c.commandOutput = tt.commandOutput
+			got, err := (execGit{c: c}).TopLevel(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TopLevel() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("TopLevel() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_execGitBranch(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name          string
+		commandOutput func(cmd *exec.Cmd) ([]byte, error)
+		want          string
+		wantErr       bool
+	}{
+		{
+			name: "Normal",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("main\n"), nil
+			},
+			want:    "main",
+			wantErr: false,
+		},
+		{
+			name: "NormalDetached",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, &exec.ExitError{}
+			},
+			want:    "",
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseCommandReturnError",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, fmt.Errorf("error")
+			},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotArgs []string
+			c.execCommandContext = func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				gotArgs = append([]string{name}, arg...)
+				return &exec.Cmd{}
+			}
+			c.commandOutput = tt.commandOutput
+			got, err := (execGit{c: c}).Branch(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Branch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Branch() got = %v, want %v", got, tt.want)
+			}
+			if want := []string{"git", "symbolic-ref", "--short", "-q", "HEAD"}; !reflect.DeepEqual(gotArgs, want) {
+				t.Errorf("Branch() ran %v, want %v", gotArgs, want)
+			}
+		})
+	}
+}
+
+func Test_execGitConfig(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name          string
+		commandOutput func(cmd *exec.Cmd) ([]byte, error)
+		want          []string
+		wantErr       bool
+	}{
+		{
+			name: "Normal",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("Fix {ticket}: \nAdd {file}\n"), nil
+			},
+			want:    []string{"Fix {ticket}: ", "Add {file}"},
+			wantErr: false,
+		},
+		{
+			name: "NormalNotSet",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, &exec.ExitError{}
+			},
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseCommandReturnError",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, fmt.Errorf("error")
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.execCommandContext = func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			}
+			c.commandOutput = tt.commandOutput
+			got, err := (execGit{c: c}).Config(context.Background(), "fcm.template")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Config() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Config() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_execGit_repository runs the read operations of both Git
+// implementations on a real repository and expects the same answers.
+func Test_execGit_repository(t *testing.T) {
+	r := newTestRepo(t)
+	r.stage("hoge.txt", "hoge\n")
+	r.git("commit", "-q", "-m", "Add hoge", "-m", "With a body")
+	r.stage("hoge.txt", "hoge\nfuga\n")
+	r.git("config", "--add", "fcm.template", "Fix {ticket}: ")
+	r.git("config", "core.hooksPath", "githooks")
+	hooks, err := filepath.EvalSymlinks(r.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hooks = filepath.Join(hooks, "githooks")
+
+	for name, g := range map[string]Git{"exec": execGit{c: r.c}, "go-git": r.goGit()} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if got, err := g.HeadMessage(ctx); err != nil || got != "Add hoge\n\nWith a body" {
+				t.Errorf("HeadMessage() = %q, %v", got, err)
+			}
+			if got, err := g.HeadCommit(ctx); err != nil || got != r.git("rev-parse", "HEAD") {
+				t.Errorf("HeadCommit() = %q, %v", got, err)
+			}
+			if got, err := g.Branch(ctx); err != nil || got != r.git("symbolic-ref", "--short", "HEAD") {
+				t.Errorf("Branch() = %q, %v", got, err)
+			}
+			// The global git config of the user may add more values before it.
+			if got, err := g.Config(ctx, "fcm.template"); err != nil || len(got) == 0 || got[len(got)-1] != "Fix {ticket}: " {
+				t.Errorf("Config() = %q, %v", got, err)
+			}
+			got, err := g.StagedDiff(ctx)
+			if err != nil || !strings.Contains(got, "+++ b/hoge.txt") || !strings.Contains(got, "\n+fuga\n") {
+				t.Errorf("StagedDiff() = %q, %v", got, err)
+			}
+			if got, err := g.HasStagedChanges(ctx); err != nil || !got {
+				t.Errorf("HasStagedChanges() = %v, %v, want true", got, err)
+			}
+			if got, err := g.HooksDir(ctx); err != nil || got != hooks {
+				t.Errorf("HooksDir() = %q, %v, want %q", got, err, hooks)
+			}
+			log, err := g.At(r.dir).Log(ctx, LogOptions{})
+			if err != nil || len(log) != 1 || log[0].Subject != "Add hoge" || log[0].Message != "Add hoge\n\nWith a body" {
+				t.Errorf("Log() = %+v, %v", log, err)
+			}
+			if got, err := g.EditorCommand(ctx); err != nil || got != "true" {
+				t.Errorf("EditorCommand() = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+// Test_execGit_hasStagedChanges expects both Git implementations to see a
+// merge in progress as something to commit, even when its tree is the one
+// of HEAD.
+func Test_execGit_hasStagedChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *testRepo)
+		want  bool
+	}{
+		{
+			name:  "NormalNothingStaged",
+			setup: func(r *testRepo) {},
+			want:  false,
+		},
+		{
+			name: "NormalMergeWithTheTreeOfHead",
+			setup: func(r *testRepo) {
+				r.git("checkout", "-q", "-b", "topic")
+				r.stage("fuga.txt", "fuga\n")
+				r.git("commit", "-q", "-m", "Add fuga")
+				r.git("checkout", "-q", "-")
+				r.git("merge", "-q", "-s", "ours", "--no-commit", "topic")
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			r.stage("hoge.txt", "hoge\n")
+			r.git("commit", "-q", "-m", "Add hoge")
+			tt.setup(r)
+
+			for name, g := range map[string]Git{"exec": execGit{c: r.c}, "go-git": r.goGit()} {
+				if got, err := g.HasStagedChanges(context.Background()); err != nil || got != tt.want {
+					t.Errorf("%s: HasStagedChanges() = %v, %v, want %v", name, got, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_execGitCommitArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		edit bool
+		want []string
+	}{
+		{
+			name: "NormalEdit",
+			edit: true,
+			want: []string{"git", "commit", "-F", "hoge", "-e"},
+		},
+		{
+			name: "NormalNoEdit",
+			edit: false,
+			want: []string{"git", "commit", "-F", "hoge"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recordingRunner{}
+			c := New(WithRunner(r))
+			if err := (execGit{c: c}).Commit(context.Background(), "hoge", tt.edit); err != nil {
+				t.Fatalf("Commit() error = %v", err)
+			}
+			if !reflect.DeepEqual(r.ran[0].Args, tt.want) {
+				t.Errorf("Commit() ran %v, want %v", r.ran[0].Args, tt.want)
+			}
+		})
+	}
+}
+
+func Test_execGitLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     LogOptions
+		out      string
+		wantArgs []string
+		want     []LogEntry
+	}{
+		{
+			name:     "Normal",
+			opts:     LogOptions{},
+			out:      "1600000000\x00Add hoge\x00Add hoge\n\nWith a body\n\x1e\n1600000060\x00Fix fuga\x00Fix fuga\n\x1e\n",
+			wantArgs: []string{"git", "log", "--no-merges", "--reverse", "--format=%at%x00%s%x00%B%x1e"},
+			want: []LogEntry{
+				{Time: time.Unix(1600000000, 0), Subject: "Add hoge", Message: "Add hoge\n\nWith a body"},
+				{Time: time.Unix(1600000060, 0), Subject: "Fix fuga", Message: "Fix fuga"},
+			},
+		},
+		{
+			name:     "NormalAuthorAndSince",
+			opts:     LogOptions{Author: "fcm@example.com", Since: "6.months"},
+			out:      "",
+			wantArgs: []string{"git", "log", "--no-merges", "--reverse", "--format=%at%x00%s%x00%B%x1e", "--author=fcm@example.com", "--since=6.months"},
+			want:     nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recordingRunner{out: []byte(tt.out)}
+			c := New(WithRunner(r))
+			got, err := (execGit{c: c}).Log(context.Background(), tt.opts)
+			if err != nil {
+				t.Fatalf("Log() error = %v", err)
+			}
+			if !reflect.DeepEqual(r.ran[0].Args, tt.wantArgs) {
+				t.Errorf("Log() ran %v, want %v", r.ran[0].Args, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Log() got = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_execGitEditorCommand(t *testing.T) {
+	r := &recordingRunner{out: []byte("vim -f\n")}
+	c := New(WithRunner(r))
+	got, err := (execGit{c: c}).EditorCommand(context.Background())
+	if err != nil || got != "vim -f" {
+		t.Errorf("EditorCommand() = %q, %v, want \"vim -f\"", got, err)
+	}
+	if want := []string{"git", "var", "GIT_EDITOR"}; !reflect.DeepEqual(r.ran[0].Args, want) {
+		t.Errorf("EditorCommand() ran %v, want %v", r.ran[0].Args, want)
+	}
+}
+
+func Test_execGitVersion(t *testing.T) {
+	r := &recordingRunner{out: []byte("git version 2.30.0\n")}
+	c := New(WithRunner(r))
+	got, err := (execGit{c: c}).Version(context.Background())
+	if err != nil || got != "git version 2.30.0" {
+		t.Errorf("Version() = %q, %v, want \"git version 2.30.0\"", got, err)
+	}
+}
+
+func Test_execGitAt(t *testing.T) {
+	r := &recordingRunner{out: []byte("hoge\n")}
+	c := New(WithRunner(r))
+	if _, err := (execGit{c: c}).At("fuga").HeadMessage(context.Background()); err != nil {
+		t.Fatalf("HeadMessage() error = %v", err)
+	}
+	if want := []string{"git", "-C", "fuga", "log", "-1", "--pretty=%B"}; !reflect.DeepEqual(r.ran[0].Args, want) {
+		t.Errorf("HeadMessage() ran %v, want %v", r.ran[0].Args, want)
+	}
+}
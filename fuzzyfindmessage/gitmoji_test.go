@@ -0,0 +1,142 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestGitmojis(t *testing.T) {
+	seen := map[string]bool{}
+	for _, g := range Gitmojis() {
+		if !strings.HasPrefix(g.Code, ":") || !strings.HasSuffix(g.Code, ":") || len(g.Emoji) == 0 || len(g.Description) == 0 {
+			t.Errorf("invalid gitmoji %+v", g)
+		}
+		if seen[g.Code] || seen[g.Emoji] {
+			t.Errorf("gitmoji %+v appears twice", g)
+		}
+		seen[g.Code], seen[g.Emoji] = true, true
+	}
+}
+
+func Test_leadingGitmoji(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		wantCode string
+		wantRest string
+		wantOK   bool
+	}{
+		{
+			name:     "NormalCode",
+			message:  ":bug: Fix hoge",
+			wantCode: ":bug:",
+			wantRest: "Fix hoge",
+			wantOK:   true,
+		},
+		{
+			name:     "NormalEmoji",
+			message:  "⚡️ Speed up fuga",
+			wantCode: ":zap:",
+			wantRest: "Speed up fuga",
+			wantOK:   true,
+		},
+		{
+			name:     "NormalEmojiWithoutVariationSelector",
+			message:  "⚡ Speed up fuga",
+			wantCode: ":zap:",
+			wantRest: "Speed up fuga",
+			wantOK:   true,
+		},
+		{
+			name:     "NormalNone",
+			message:  "Fix hoge :bug:",
+			wantCode: "",
+			wantRest: "Fix hoge :bug:",
+			wantOK:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, rest, ok := leadingGitmoji(tt.message)
+			if g.Code != tt.wantCode || rest != tt.wantRest || ok != tt.wantOK {
+				t.Errorf("leadingGitmoji() = %q, %q, %v, want %q, %q, %v", g.Code, rest, ok, tt.wantCode, tt.wantRest, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestClient_addGitmoji(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		message string
+		finder  *mockFinder
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "NormalOff",
+			config:  "",
+			message: "Fix hoge",
+			finder:  &mockFinder{},
+			want:    "Fix hoge",
+			wantErr: false,
+		},
+		{
+			name:    "NormalPickCode",
+			config:  "gitmoji = code\n",
+			message: "Fix hoge",
+			finder:  &mockFinder{id: 3},
+			want:    ":bug: Fix hoge",
+			wantErr: false,
+		},
+		{
+			name:    "NormalPickEmoji",
+			config:  "gitmoji = emoji\n",
+			message: "Fix hoge",
+			finder:  &mockFinder{id: 3},
+			want:    "🐛 Fix hoge",
+			wantErr: false,
+		},
+		{
+			name:    "NormalKeepAndConvert",
+			config:  "gitmoji = emoji\n",
+			message: ":sparkles: Add fuga",
+			finder:  nil,
+			want:    "✨ Add fuga",
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseAborted",
+			config:  "gitmoji = code\n",
+			message: "Fix hoge",
+			finder:  &mockFinder{err: ErrAborted},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			if err := ioutil.WriteFile(c.configFilePath, []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if tt.finder != nil {
+				c.finder = tt.finder
+			}
+
+			got, err := c.addGitmoji(context.Background(), tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("addGitmoji() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("addGitmoji() = %q, want %q", got, tt.want)
+			}
+			if tt.finder != nil && tt.finder.items != nil && !strings.Contains(tt.finder.items[3], "Fix a bug") {
+				t.Errorf("addGitmoji() offered %q, want the descriptions", tt.finder.items[3])
+			}
+		})
+	}
+}
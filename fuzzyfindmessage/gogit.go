@@ -0,0 +1,430 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// WithGoGit reads the repository of the working directory in process with
+// go-git, so that fcm works without the git binary. Committing, git log, the
+// editor of git and its version still run git, as go-git runs neither hooks
+// nor the editor and does not understand the dates of --since. includeIf and
+// the other include directives of git config are not followed.
+func WithGoGit() Option {
+	return func(c *Client) {
+		c.git = goGit{
+			open:       openRepository,
+			loadConfig: config.LoadConfig,
+			exec:       execGit{c: c},
+		}
+	}
+}
+
+// openRepository opens the repository containing dir, which may be a linked
+// worktree sharing the objects of another one.
+func openRepository(dir string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: true,
+	})
+}
+
+// goGit is the Git reading the repository in dir with go-git. exec is the
+// git binary doing what go-git cannot.
+type goGit struct {
+	dir        string
+	open       func(dir string) (*git.Repository, error)
+	loadConfig func(scope config.Scope) (*config.Config, error)
+	exec       execGit
+}
+
+func (g goGit) At(dir string) Git {
+	g.dir = dir
+	g.exec.dir = dir
+	return g
+}
+
+func (g goGit) Commit(ctx context.Context, fileName string, edit bool) error {
+	return g.exec.Commit(ctx, fileName, edit)
+}
+
+func (g goGit) Log(ctx context.Context, opts LogOptions) ([]LogEntry, error) {
+	return g.exec.Log(ctx, opts)
+}
+
+func (g goGit) EditorCommand(ctx context.Context) (string, error) {
+	return g.exec.EditorCommand(ctx)
+}
+
+func (g goGit) Version(ctx context.Context) (string, error) {
+	return g.exec.Version(ctx)
+}
+
+func (g goGit) repository(ctx context.Context) (*git.Repository, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	dir := g.dir
+	if len(dir) == 0 {
+		dir = "."
+	}
+	return g.open(dir)
+}
+
+func (g goGit) HeadCommit(ctx context.Context) (string, error) {
+	r, err := g.repository(ctx)
+	if err != nil {
+		return "", err
+	}
+	head, err := r.Head()
+	if err != nil {
+		// HEAD does not resolve yet on an unborn branch.
+		if err == plumbing.ErrReferenceNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+func (g goGit) HeadMessage(ctx context.Context) (string, error) {
+	r, err := g.repository(ctx)
+	if err != nil {
+		return "", err
+	}
+	commit, err := headCommitObject(r)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(commit.Message, "\n"), nil
+}
+
+func (g goGit) Branch(ctx context.Context) (string, error) {
+	r, err := g.repository(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Read HEAD itself, which names the branch even before its first commit.
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", err
+	}
+	if head.Type() != plumbing.SymbolicReference {
+		return "", nil
+	}
+	return head.Target().Short(), nil
+}
+
+// StagedDiff compares the index with the tree of HEAD. Each changed file is a
+// single hunk spanning the whole file. A submodule shows the commit it points
+// to, like git.
+func (g goGit) StagedDiff(ctx context.Context) (string, error) {
+	r, err := g.repository(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	before, after, gitlinks, err := stagedFiles(r)
+	if err != nil {
+		return "", err
+	}
+
+	var paths []string
+	for path, hash := range before {
+		if after[path] != hash {
+			paths = append(paths, path)
+		}
+	}
+	for path := range after {
+		if _, ok := before[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		src, err := blobContent(r, before, gitlinks, path)
+		if err != nil {
+			return "", err
+		}
+		dst, err := blobContent(r, after, gitlinks, path)
+		if err != nil {
+			return "", err
+		}
+		writeFilePatch(&b, path, before, after, src, dst)
+	}
+	return b.String(), nil
+}
+
+// HasStagedChanges compares the hashes of the index with those of the tree of
+// HEAD, and looks for MERGE_HEAD when they are the same.
+func (g goGit) HasStagedChanges(ctx context.Context) (bool, error) {
+	r, err := g.repository(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	before, after, _, err := stagedFiles(r)
+	if err != nil {
+		return false, err
+	}
+	if len(before) != len(after) {
+		return true, nil
+	}
+	for path, hash := range before {
+		if h, ok := after[path]; !ok || h != hash {
+			return true, nil
+		}
+	}
+
+	_, err = r.Storer.Reference(plumbing.ReferenceName("MERGE_HEAD"))
+	switch err {
+	case nil:
+		return true, nil
+	case plumbing.ErrReferenceNotFound:
+		return false, nil
+	}
+	return false, err
+}
+
+// stagedFiles returns the hashes of the files in the tree of HEAD and in the
+// index, and the commits the submodules of either point to.
+func stagedFiles(r *git.Repository) (before, after map[string]plumbing.Hash, gitlinks map[plumbing.Hash]bool, err error) {
+	before = map[string]plumbing.Hash{}
+	after = map[string]plumbing.Hash{}
+	gitlinks = map[plumbing.Hash]bool{}
+
+	commit, err := headCommitObject(r)
+	switch err {
+	case nil:
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// Tree.Files leaves out submodules, so walk the entries.
+		walker := object.NewTreeWalker(tree, true, nil)
+		defer walker.Close()
+		for {
+			name, entry, err := walker.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if entry.Mode == filemode.Dir {
+				continue
+			}
+			before[name] = entry.Hash
+			if entry.Mode == filemode.Submodule {
+				gitlinks[entry.Hash] = true
+			}
+		}
+	case plumbing.ErrReferenceNotFound:
+	default:
+		return nil, nil, nil, err
+	}
+
+	index, err := r.Storer.Index()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, e := range index.Entries {
+		after[e.Name] = e.Hash
+		if e.Mode == filemode.Submodule {
+			gitlinks[e.Hash] = true
+		}
+	}
+	return before, after, gitlinks, nil
+}
+
+func (g goGit) Config(ctx context.Context, key string) ([]string, error) {
+	r, err := g.repository(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dot := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if dot <= 0 || last == len(key)-1 {
+		return nil, fmt.Errorf("key does not contain a section: %s", key)
+	}
+	section, subsection, name := key[:dot], "", key[last+1:]
+	if dot != last {
+		subsection = key[dot+1 : last]
+	}
+
+	var values []string
+	for _, scope := range []config.Scope{config.SystemScope, config.GlobalScope, config.LocalScope} {
+		var cfg *config.Config
+		if scope == config.LocalScope {
+			cfg, err = r.Storer.Config()
+		} else {
+			cfg, err = g.loadConfig(scope)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range cfg.Raw.Sections {
+			if !s.IsName(section) {
+				continue
+			}
+			if len(subsection) == 0 {
+				values = append(values, s.Options.GetAll(name)...)
+				continue
+			}
+			for _, ss := range s.Subsections {
+				if ss.IsName(subsection) {
+					values = append(values, ss.Options.GetAll(name)...)
+				}
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+	return values, nil
+}
+
+func (g goGit) TopLevel(ctx context.Context) (string, error) {
+	r, err := g.repository(ctx)
+	if err != nil {
+		// Outside of a repository commands run in the current directory.
+		if err == git.ErrRepositoryNotExists {
+			return "", nil
+		}
+		return "", err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		if err == git.ErrIsBareRepository {
+			return "", nil
+		}
+		return "", err
+	}
+	return w.Filesystem.Root(), nil
+}
+
+func (g goGit) HooksDir(ctx context.Context) (string, error) {
+	r, err := g.repository(ctx)
+	if err != nil {
+		if err == git.ErrRepositoryNotExists {
+			return "", ErrNotARepo
+		}
+		return "", err
+	}
+	top, err := g.TopLevel(ctx)
+	if err != nil {
+		return "", err
+	}
+	paths, err := g.Config(ctx, "core.hooksPath")
+	if err != nil {
+		return "", err
+	}
+	if len(paths) != 0 {
+		dir := paths[len(paths)-1]
+		if !filepath.IsAbs(dir) && len(top) != 0 {
+			dir = filepath.Join(top, dir)
+		}
+		return filepath.Abs(dir)
+	}
+
+	s, ok := r.Storer.(*filesystem.Storage)
+	if !ok {
+		return "", fmt.Errorf("the repository is not stored in a directory")
+	}
+	return filepath.Abs(filepath.Join(s.Filesystem().Root(), "hooks"))
+}
+
+func headCommitObject(r *git.Repository) (*object.Commit, error) {
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	return r.CommitObject(head.Hash())
+}
+
+// blobContent returns the content of path in files, "" when it is missing.
+// A submodule has no blob in the repository and reads as the line git shows.
+func blobContent(r *git.Repository, files map[string]plumbing.Hash, gitlinks map[plumbing.Hash]bool, path string) (string, error) {
+	hash, ok := files[path]
+	if !ok {
+		return "", nil
+	}
+	if gitlinks[hash] {
+		return "Subproject commit " + hash.String() + "\n", nil
+	}
+	blob, err := r.BlobObject(hash)
+	if err != nil {
+		return "", err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func writeFilePatch(b *strings.Builder, path string, before, after map[string]plumbing.Hash, src, dst string) {
+	fmt.Fprintf(b, "diff --git a/%s b/%s\n", path, path)
+	from, to := "a/"+path, "b/"+path
+	if _, ok := before[path]; !ok {
+		b.WriteString("new file\n")
+		from = "/dev/null"
+	}
+	if _, ok := after[path]; !ok {
+		b.WriteString("deleted file\n")
+		to = "/dev/null"
+	}
+	fmt.Fprintf(b, "--- %s\n+++ %s\n", from, to)
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(src), hunkRange(dst))
+
+	for _, d := range diff.Do(src, dst) {
+		prefix := " "
+		switch d.Type {
+		case diffmatchpatch.DiffDelete:
+			prefix = "-"
+		case diffmatchpatch.DiffInsert:
+			prefix = "+"
+		}
+		for _, line := range splitLines(d.Text) {
+			b.WriteString(prefix + line + "\n")
+		}
+	}
+}
+
+func hunkRange(content string) string {
+	n := len(splitLines(content))
+	if n == 0 {
+		return "0,0"
+	}
+	return fmt.Sprintf("1,%d", n)
+}
+
+func splitLines(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,330 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// memoryRepo is an in-memory repository on branch master.
+type memoryRepo struct {
+	t    *testing.T
+	repo *git.Repository
+	wt   *git.Worktree
+}
+
+func newMemoryRepo(t *testing.T) *memoryRepo {
+	t.Helper()
+	repo, err := git.Init(memory.NewStorage(), memfs.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &memoryRepo{t: t, repo: repo, wt: wt}
+}
+
+func (r *memoryRepo) stage(name, content string) {
+	r.t.Helper()
+	f, err := r.wt.Filesystem.Create(name)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		r.t.Fatal(err)
+	}
+	if _, err := r.wt.Add(name); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *memoryRepo) remove(name string) {
+	r.t.Helper()
+	if _, err := r.wt.Remove(name); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// link stages a submodule at name, pointing to the commit hash.
+func (r *memoryRepo) link(name, hash string) {
+	r.t.Helper()
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	e, err := idx.Entry(name)
+	if err != nil {
+		e = idx.Add(name)
+	}
+	e.Hash = plumbing.NewHash(hash)
+	e.Mode = filemode.Submodule
+	if err := r.repo.Storer.SetIndex(idx); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func (r *memoryRepo) commit(message string) string {
+	r.t.Helper()
+	hash, err := r.wt.Commit(message, &git.CommitOptions{
+		Author: &object.Signature{Name: "fcm", Email: "fcm@example.com", When: date(1)},
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return hash.String()
+}
+
+func (r *memoryRepo) head() plumbing.Hash {
+	r.t.Helper()
+	head, err := r.repo.Head()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return head.Hash()
+}
+
+// git returns the goGit of the repository. global is the git config outside
+// of the repository.
+func (r *memoryRepo) git(global string) goGit {
+	return goGit{
+		open: func(dir string) (*git.Repository, error) {
+			return r.repo, nil
+		},
+		loadConfig: func(scope config.Scope) (*config.Config, error) {
+			cfg := config.NewConfig()
+			if scope == config.GlobalScope {
+				if err := cfg.Unmarshal([]byte(global)); err != nil {
+					return nil, err
+				}
+			}
+			return cfg, nil
+		},
+	}
+}
+
+func Test_goGitHead(t *testing.T) {
+	r := newMemoryRepo(t)
+	g := r.git("")
+	ctx := context.Background()
+
+	if got, err := g.HeadCommit(ctx); err != nil || got != "" {
+		t.Errorf("HeadCommit() on an unborn branch = %q, %v, want \"\"", got, err)
+	}
+	if got, err := g.Branch(ctx); err != nil || got != "master" {
+		t.Errorf("Branch() on an unborn branch = %q, %v, want master", got, err)
+	}
+
+	r.stage("hoge.txt", "hoge\n")
+	hash := r.commit("Add hoge\n\nWith a body\n")
+	if got, err := g.HeadCommit(ctx); err != nil || got != hash {
+		t.Errorf("HeadCommit() = %q, %v, want %v", got, err, hash)
+	}
+	if got, err := g.HeadMessage(ctx); err != nil || got != "Add hoge\n\nWith a body" {
+		t.Errorf("HeadMessage() = %q, %v", got, err)
+	}
+
+	if err := r.wt.Checkout(&git.CheckoutOptions{Hash: r.head()}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := g.Branch(ctx); err != nil || got != "" {
+		t.Errorf("Branch() on a detached HEAD = %q, %v, want \"\"", got, err)
+	}
+}
+
+func Test_goGitStagedDiff(t *testing.T) {
+	r := newMemoryRepo(t)
+	r.stage("hoge.txt", "hoge\nfuga\n")
+	r.stage("piyo.txt", "piyo\n")
+	r.commit("Add hoge")
+	r.stage("hoge.txt", "hoge\npiyo\n")
+	r.stage("new.txt", "new\n")
+	r.remove("piyo.txt")
+
+	got, err := r.git("").StagedDiff(context.Background())
+	if err != nil {
+		t.Fatalf("StagedDiff() error = %v", err)
+	}
+	want := `diff --git a/hoge.txt b/hoge.txt
+--- a/hoge.txt
++++ b/hoge.txt
+@@ -1,2 +1,2 @@
+ hoge
+-fuga
++piyo
+diff --git a/new.txt b/new.txt
+new file
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,1 @@
++new
+diff --git a/piyo.txt b/piyo.txt
+deleted file
+--- a/piyo.txt
++++ /dev/null
+@@ -1,1 +0,0 @@
+-piyo
+`
+	if got != want {
+		t.Errorf("StagedDiff() got = %q, want %q", got, want)
+	}
+}
+
+func Test_goGitStagedDiffSubmodule(t *testing.T) {
+	r := newMemoryRepo(t)
+	r.stage("hoge.txt", "hoge\n")
+	r.link("lib", "1111111111111111111111111111111111111111")
+	r.commit("Add lib")
+	r.link("lib", "2222222222222222222222222222222222222222")
+	r.link("vendor/fuga", "3333333333333333333333333333333333333333")
+
+	got, err := r.git("").StagedDiff(context.Background())
+	if err != nil {
+		t.Fatalf("StagedDiff() error = %v", err)
+	}
+	want := `diff --git a/lib b/lib
+--- a/lib
++++ b/lib
+@@ -1,1 +1,1 @@
+-Subproject commit 1111111111111111111111111111111111111111
++Subproject commit 2222222222222222222222222222222222222222
+diff --git a/vendor/fuga b/vendor/fuga
+new file
+--- /dev/null
++++ b/vendor/fuga
+@@ -0,0 +1,1 @@
++Subproject commit 3333333333333333333333333333333333333333
+`
+	if got != want {
+		t.Errorf("StagedDiff() got = %q, want %q", got, want)
+	}
+}
+
+func Test_goGitStagedDiffNothingStaged(t *testing.T) {
+	r := newMemoryRepo(t)
+	r.stage("hoge.txt", "hoge\n")
+	r.commit("Add hoge")
+
+	got, err := r.git("").StagedDiff(context.Background())
+	if err != nil || got != "" {
+		t.Errorf("StagedDiff() = %q, %v, want \"\"", got, err)
+	}
+}
+
+func Test_goGitConfig(t *testing.T) {
+	r := newMemoryRepo(t)
+	cfg, err := r.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Raw.Section("fcm").AddOption("template", "Fix {ticket}: ")
+	cfg.Raw.Section("branch").Subsection("main").AddOption("remote", "origin")
+	if err := r.repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	g := r.git("[fcm]\n\ttemplate = Add {file}\n")
+
+	tests := []struct {
+		name    string
+		key     string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "Normal",
+			key:     "fcm.template",
+			want:    []string{"Add {file}", "Fix {ticket}: "},
+			wantErr: false,
+		},
+		{
+			name:    "NormalSubsection",
+			key:     "branch.main.remote",
+			want:    []string{"origin"},
+			wantErr: false,
+		},
+		{
+			name:    "NormalNotSet",
+			key:     "fcm.hoge",
+			want:    nil,
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseNoSection",
+			key:     "template",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := g.Config(context.Background(), tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Config() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Config() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_goGitTopLevel(t *testing.T) {
+	g := goGit{
+		open: func(dir string) (*git.Repository, error) {
+			return nil, git.ErrRepositoryNotExists
+		},
+	}
+	if got, err := g.TopLevel(context.Background()); err != nil || got != "" {
+		t.Errorf("TopLevel() outside of a repository = %q, %v, want \"\"", got, err)
+	}
+
+	r := newMemoryRepo(t)
+	if got, err := r.git("").TopLevel(context.Background()); err != nil || !strings.HasPrefix(got, "/") {
+		t.Errorf("TopLevel() = %q, %v", got, err)
+	}
+}
+
+func Test_goGitCanceled(t *testing.T) {
+	r := newMemoryRepo(t)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if _, err := r.git("").HeadMessage(ctx); err != context.DeadlineExceeded {
+		t.Errorf("HeadMessage() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func Test_openRepositoryWorktree(t *testing.T) {
+	r := newTestRepo(t)
+	r.stage("hoge.txt", "hoge\n")
+	r.git("commit", "-q", "-m", "Add hoge")
+	worktree := filepath.Join(r.dir, "..", filepath.Base(r.dir)+"-worktree")
+	r.git("worktree", "add", "-q", "-b", "fuga", worktree)
+	t.Cleanup(func() {
+		os.RemoveAll(worktree)
+	})
+
+	g := goGit{open: openRepository, loadConfig: config.LoadConfig}.At(worktree)
+	if got, err := g.HeadCommit(context.Background()); err != nil || got != r.git("rev-parse", "HEAD") {
+		t.Errorf("HeadCommit() in a linked worktree = %q, %v", got, err)
+	}
+	if got, err := g.Branch(context.Background()); err != nil || got != "fuga" {
+		t.Errorf("Branch() in a linked worktree = %q, %v, want fuga", got, err)
+	}
+}
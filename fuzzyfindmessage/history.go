@@ -0,0 +1,446 @@
+package fuzzyfindmessage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// HistoryTimeFormat is the layout of the times in ~/.fcm_history, in local
+// time.
+const HistoryTimeFormat = "2006/01/02 15:04:05"
+
+// HistoryEntry is one commit message recorded in ~/.fcm_history.
+type HistoryEntry struct {
+	// Time is when the message was committed. It is zero for lines that
+	// were added to the file by hand without a timestamp.
+	Time time.Time
+	// Message is the commit message as it appears in the Candidate list,
+	// with newlines escaped as "\n".
+	Message string
+	// Repo names the repository the message was imported from, if any.
+	Repo string
+}
+
+// History calls Client.History with the default Client.
+func History() ([]HistoryEntry, error) {
+	return std().History()
+}
+
+// History returns the entries of the history file, oldest first.
+func (c *Client) History() (entries []HistoryEntry, err error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+	unlock, err := c.lockHistory(false)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil {
+			err = unlock()
+			return
+		}
+		unlock()
+	}()
+
+	return c.readHistory()
+}
+
+// SearchHistory calls Client.SearchHistory with the default Client.
+func SearchHistory(query string) ([]HistoryEntry, error) {
+	return std().SearchHistory(query)
+}
+
+// SearchHistory returns the history entries whose message contains every
+// space separated word of query, ignoring case.
+func (c *Client) SearchHistory(query string) ([]HistoryEntry, error) {
+	entries, err := c.History()
+	if err != nil {
+		return nil, err
+	}
+
+	words := strings.Fields(strings.ToLower(query))
+	var matched []HistoryEntry
+	for _, e := range entries {
+		if containsAll(strings.ToLower(e.Message), words) {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+// SelectHistory calls Client.SelectHistory with the default Client.
+func SelectHistory(multi bool) ([]HistoryEntry, error) {
+	return std().SelectHistory(multi)
+}
+
+// SelectHistory lets the user pick history entries with the fuzzy finder,
+// newest first. With multi more than one entry can be selected.
+func (c *Client) SelectHistory(multi bool) ([]HistoryEntry, error) {
+	entries, err := c.History()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	newest := make([]HistoryEntry, len(entries))
+	for i, e := range entries {
+		newest[len(entries)-1-i] = e
+	}
+	items := make([]string, len(newest))
+	for i, e := range newest {
+		items[i] = e.Message
+	}
+	preview := func(i int) string {
+		e := newest[i]
+		if e.Time.IsZero() {
+			return unescapeMessage(e.Message)
+		}
+		return formatHistoryHeader(e) + unescapeMessage(e.Message)
+	}
+
+	var ids []int
+	if multi {
+		ids, err = c.finder.FindMulti(context.Background(), items, preview)
+	} else {
+		var id int
+		id, err = c.finder.Find(context.Background(), items, preview)
+		ids = []int{id}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]HistoryEntry, 0, len(ids))
+	for _, id := range ids {
+		selected = append(selected, newest[id])
+	}
+	return selected, nil
+}
+
+// RemoveHistory calls Client.RemoveHistory with the default Client.
+func RemoveHistory(messages ...string) ([]HistoryEntry, error) {
+	return std().RemoveHistory(messages...)
+}
+
+// RemoveHistory deletes every history entry whose message is one of messages
+// and returns the removed entries.
+func (c *Client) RemoveHistory(messages ...string) (removed []HistoryEntry, err error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+	targets := map[string]bool{}
+	for _, m := range messages {
+		targets[m] = true
+	}
+
+	err = c.rewriteHistory(func(w io.Writer) error {
+		entries, err := c.readHistory()
+		if err != nil {
+			return err
+		}
+
+		kept := entries[:0]
+		for _, e := range entries {
+			if targets[e.Message] {
+				removed = append(removed, e)
+				continue
+			}
+			kept = append(kept, e)
+		}
+		return writeHistory(w, kept)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// EditHistory calls Client.EditHistory with the default Client.
+func EditHistory(message, edited string) error {
+	return std().EditHistory(message, edited)
+}
+
+// EditHistory replaces message with edited in every history entry holding it,
+// keeping the timestamps. An empty edited message removes the entries.
+func (c *Client) EditHistory(message, edited string) error {
+	if err := c.resolvePaths(); err != nil {
+		return err
+	}
+	if len(edited) == 0 {
+		_, err := c.RemoveHistory(message)
+		return err
+	}
+
+	return c.rewriteHistory(func(w io.Writer) error {
+		entries, err := c.readHistory()
+		if err != nil {
+			return err
+		}
+
+		for i := range entries {
+			if entries[i].Message == message {
+				entries[i].Message = edited
+			}
+		}
+		return writeHistory(w, entries)
+	})
+}
+
+// EditMessage calls Client.EditMessage with the default Client.
+func EditMessage(message string) (string, error) {
+	return std().EditMessage(message)
+}
+
+// EditMessage opens message in the editor configured for git and returns the
+// edited message, escaped for the history and template files.
+func (c *Client) EditMessage(message string) (edited string, err error) {
+	f, err := c.createTemplate(message)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err == nil {
+			err = c.osRemove(c.tmpFileName(f))
+			return
+		}
+		c.osRemove(c.tmpFileName(f))
+	}()
+
+	if err := c.runEditor(context.Background(), c.tmpFileName(f)); err != nil {
+		return "", err
+	}
+
+	b, err := c.ioutilReadFile(c.tmpFileName(f))
+	if err != nil {
+		return "", err
+	}
+	return escapeMessage(string(b)), nil
+}
+
+// PruneHistory calls Client.PruneHistory with the default Client.
+func PruneHistory(dryRun bool) ([]HistoryEntry, error) {
+	return std().PruneHistory(dryRun)
+}
+
+// PruneHistory applies the history retention settings of the config file and
+// drops older duplicates of the same message, keeping only the latest one.
+// It returns the entries that were removed. With dryRun the history file is
+// left untouched and the entries that would be removed are returned.
+func (c *Client) PruneHistory(dryRun bool) ([]HistoryEntry, error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+	return c.pruneHistory(dryRun)
+}
+
+func (c *Client) _pruneHistory(dryRun bool) (removed []HistoryEntry, err error) {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := c.lockHistory(!dryRun)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil {
+			err = unlock()
+			return
+		}
+		unlock()
+	}()
+
+	entries, err := c.readHistory()
+	if err != nil {
+		return nil, err
+	}
+
+	kept, removed := pruneEntries(entries, cfg, c.timeNow())
+	if dryRun || len(removed) == 0 {
+		return removed, nil
+	}
+
+	return removed, c.writeFileAtomic(c.historyFilePath, func(w io.Writer) error {
+		return writeHistory(w, kept)
+	})
+}
+
+// _readHistory parses the history file. Callers are expected to hold the
+// history lock.
+func (c *Client) _readHistory() (entries []HistoryEntry, err error) {
+	file, err := c.osOpen(c.historyFilePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer c.fileClose(file)
+
+	return parseHistory(file)
+}
+
+// RepairHistory calls Client.RepairHistory with the default Client.
+func RepairHistory() ([]HistoryEntry, error) {
+	return std().RepairHistory()
+}
+
+// RepairHistory removes the quotes fcm 1.0 and older recorded around every
+// message and returns the entries it repaired, as they were.
+func (c *Client) RepairHistory() (repaired []HistoryEntry, err error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+
+	err = c.rewriteHistory(func(w io.Writer) error {
+		entries, err := c.readHistory()
+		if err != nil {
+			return err
+		}
+
+		for i, e := range entries {
+			if legacyQuoted(e.Message) {
+				repaired = append(repaired, e)
+				entries[i].Message = unquoteLegacy(e.Message)
+			}
+		}
+		return writeHistory(w, entries)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repaired, nil
+}
+
+// legacyQuoted tells whether message was recorded by fcm 1.0 and older,
+// which ran git log --pretty='%B' without a shell and so kept the quotes,
+// e.g. 'Fix typo\n'\n. The newline git ends %B with is always inside the
+// quotes, which tells them from quotes the user wrote.
+func legacyQuoted(message string) bool {
+	return strings.HasPrefix(message, "'") &&
+		(strings.HasSuffix(message, "\\n'") || strings.HasSuffix(message, "\\n'\\n"))
+}
+
+func unquoteLegacy(message string) string {
+	message = strings.TrimSuffix(message, "\\n")
+	message = strings.TrimSuffix(strings.TrimPrefix(message, "'"), "'")
+	return escapeMessage(unescapeMessage(message))
+}
+
+func containsAll(s string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(s, w) {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeMessage turns a commit message into a single Candidate line.
+func escapeMessage(message string) string {
+	message = strings.TrimRight(message, " \t\r\n")
+	return strings.Replace(message, "\n", "\\n", -1)
+}
+
+func unescapeMessage(message string) string {
+	return strings.ReplaceAll(message, "\\n", "\n")
+}
+
+func parseHistory(r io.Reader) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	var t time.Time
+	var repo string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		s := scanner.Text()
+		if len(s) == 0 {
+			continue
+		}
+
+		if s[0:1] == "#" {
+			t, repo = parseHistoryHeader(s)
+			continue
+		}
+		entries = append(entries, HistoryEntry{Time: t, Message: s, Repo: repo})
+		t, repo = time.Time{}, ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// parseHistoryHeader reads a "# <timestamp> [<repo>]" line. Other comments
+// yield a zero time.
+func parseHistoryHeader(s string) (time.Time, string) {
+	s = strings.TrimSpace(s[1:])
+	if len(s) < len(HistoryTimeFormat) {
+		return time.Time{}, ""
+	}
+	t, err := time.ParseInLocation(HistoryTimeFormat, s[:len(HistoryTimeFormat)], time.Local)
+	if err != nil {
+		return time.Time{}, ""
+	}
+	return t, strings.TrimSpace(s[len(HistoryTimeFormat):])
+}
+
+func formatHistoryHeader(e HistoryEntry) string {
+	if len(e.Repo) == 0 {
+		return fmt.Sprintf("# %s\n", e.Time.Format(HistoryTimeFormat))
+	}
+	return fmt.Sprintf("# %s %s\n", e.Time.Format(HistoryTimeFormat), e.Repo)
+}
+
+func writeHistory(w io.Writer, entries []HistoryEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		if !e.Time.IsZero() {
+			if _, err := fmt.Fprint(bw, formatHistoryHeader(e)); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(bw, e.Message); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// pruneEntries keeps the latest occurrence of every message and then applies
+// the age and size limits of cfg. Entries without a timestamp never expire.
+func pruneEntries(entries []HistoryEntry, cfg *Config, now time.Time) (kept, removed []HistoryEntry) {
+	latest := map[string]int{}
+	for i, e := range entries {
+		latest[e.Message] = i
+	}
+
+	for i, e := range entries {
+		switch {
+		case latest[e.Message] != i:
+			removed = append(removed, e)
+		case cfg.HistoryMaxAge > 0 && !e.Time.IsZero() && now.Sub(e.Time) > cfg.HistoryMaxAge:
+			removed = append(removed, e)
+		default:
+			kept = append(kept, e)
+		}
+	}
+
+	if cfg.HistoryMaxEntries > 0 && len(kept) > cfg.HistoryMaxEntries {
+		over := len(kept) - cfg.HistoryMaxEntries
+		removed = append(removed, kept[:over]...)
+		kept = kept[over:]
+	}
+
+	return kept, removed
+}
@@ -0,0 +1,418 @@
+package fuzzyfindmessage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(day int) time.Time {
+	return time.Date(2020, 1, day, 12, 0, 0, 0, time.Local)
+}
+
+func Test_parseHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []HistoryEntry
+	}{
+		{
+			name:    "Normal",
+			content: "# 2020/01/01 12:00:00\nhoge\n# 2020/01/02 12:00:00\nfuga\\n\\nbody\n",
+			want: []HistoryEntry{
+				{Time: date(1), Message: "hoge"},
+				{Time: date(2), Message: "fuga\\n\\nbody"},
+			},
+		},
+		{
+			name:    "NormalWithoutTimestamp",
+			content: "hoge\n\n# not a timestamp\nfuga\n",
+			want: []HistoryEntry{
+				{Message: "hoge"},
+				{Message: "fuga"},
+			},
+		},
+		{
+			name:    "NormalEmpty",
+			content: "",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHistory(strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("parseHistory() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseHistory() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeHistory(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeHistory(&buf, []HistoryEntry{
+		{Time: date(1), Message: "hoge"},
+		{Message: "fuga"},
+	})
+	if err != nil {
+		t.Fatalf("writeHistory() error = %v", err)
+	}
+	if want := "# 2020/01/01 12:00:00\nhoge\nfuga\n"; buf.String() != want {
+		t.Errorf("writeHistory() got = %q, want %q", buf.String(), want)
+	}
+}
+
+func Test_pruneEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		entries     []HistoryEntry
+		cfg         *Config
+		wantKept    []HistoryEntry
+		wantRemoved []HistoryEntry
+	}{
+		{
+			name: "NormalKeepLatestDuplicate",
+			entries: []HistoryEntry{
+				{Time: date(1), Message: "hoge"},
+				{Time: date(2), Message: "fuga"},
+				{Time: date(3), Message: "hoge"},
+			},
+			cfg: &Config{},
+			wantKept: []HistoryEntry{
+				{Time: date(2), Message: "fuga"},
+				{Time: date(3), Message: "hoge"},
+			},
+			wantRemoved: []HistoryEntry{
+				{Time: date(1), Message: "hoge"},
+			},
+		},
+		{
+			name: "NormalMaxAge",
+			entries: []HistoryEntry{
+				{Message: "piyo"},
+				{Time: date(1), Message: "hoge"},
+				{Time: date(9), Message: "fuga"},
+			},
+			cfg: &Config{HistoryMaxAge: 48 * time.Hour},
+			wantKept: []HistoryEntry{
+				{Message: "piyo"},
+				{Time: date(9), Message: "fuga"},
+			},
+			wantRemoved: []HistoryEntry{
+				{Time: date(1), Message: "hoge"},
+			},
+		},
+		{
+			name: "NormalMaxEntries",
+			entries: []HistoryEntry{
+				{Time: date(1), Message: "hoge"},
+				{Time: date(2), Message: "fuga"},
+				{Time: date(3), Message: "piyo"},
+			},
+			cfg: &Config{HistoryMaxEntries: 2},
+			wantKept: []HistoryEntry{
+				{Time: date(2), Message: "fuga"},
+				{Time: date(3), Message: "piyo"},
+			},
+			wantRemoved: []HistoryEntry{
+				{Time: date(1), Message: "hoge"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, removed := pruneEntries(tt.entries, tt.cfg, date(10))
+			if !reflect.DeepEqual(kept, tt.wantKept) {
+				t.Errorf("pruneEntries() kept = %v, want %v", kept, tt.wantKept)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("pruneEntries() removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func Test__pruneHistory(t *testing.T) {
+	content := "# 2020/01/01 12:00:00\nhoge\n# 2020/01/02 12:00:00\nhoge\n"
+	tests := []struct {
+		name       string
+		dryRun     bool
+		loadConfig func() (*Config, error)
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:   "Normal",
+			dryRun: false,
+			loadConfig: func() (*Config, error) {
+				return &Config{}, nil
+			},
+			want:    "# 2020/01/02 12:00:00\nhoge\n",
+			wantErr: false,
+		},
+		{
+			name:   "NormalDryRun",
+			dryRun: true,
+			loadConfig: func() (*Config, error) {
+				return &Config{}, nil
+			},
+			want:    content,
+			wantErr: false,
+		},
+		{
+			name:   "ErrorBecauseLoadConfigReturnError",
+			dryRun: false,
+			loadConfig: func() (*Config, error) {
+				return nil, fmt.Errorf("error")
+			},
+			want:    content,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			if err := ioutil.WriteFile(c.historyFilePath, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			c.loadConfig = tt.loadConfig
+			removed, err := c._pruneHistory(tt.dryRun)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pruneHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(removed) != 1 {
+				t.Errorf("pruneHistory() removed = %v, want 1 entry", removed)
+			}
+			got, err := ioutil.ReadFile(c.historyFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("pruneHistory() history = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+const historyFixture = "# 2020/01/01 12:00:00\nAdd hoge\n# 2020/01/02 12:00:00\nFix fuga\\n\\nbody\nWIP\n"
+
+func writeHistoryFixture(t *testing.T) *Client {
+	t.Helper()
+	c, _ := newTempClient(t)
+	if err := ioutil.WriteFile(c.historyFilePath, []byte(historyFixture), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func readHistoryFixture(t *testing.T, c *Client) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(c.historyFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSearchHistory(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []HistoryEntry
+	}{
+		{
+			name:  "Normal",
+			query: "FUGA body",
+			want: []HistoryEntry{
+				{Time: date(2), Message: "Fix fuga\\n\\nbody"},
+			},
+		},
+		{
+			name:  "NormalNoMatch",
+			query: "piyo",
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := writeHistoryFixture(t)
+			got, err := c.SearchHistory(tt.query)
+			if err != nil {
+				t.Fatalf("SearchHistory() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchHistory() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		multi   bool
+		finder  Finder
+		want    []HistoryEntry
+		wantErr bool
+	}{
+		{
+			name:   "NormalNewestFirst",
+			multi:  false,
+			finder: &mockFinder{},
+			want: []HistoryEntry{
+				{Message: "WIP"},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "NormalMulti",
+			multi:  true,
+			finder: &mockFinder{ids: []int{0, 2}},
+			want: []HistoryEntry{
+				{Message: "WIP"},
+				{Time: date(1), Message: "Add hoge"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseFinderReturnError",
+			multi:   false,
+			finder:  &mockFinder{err: fmt.Errorf("error")},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := writeHistoryFixture(t)
+			c.finder = tt.finder
+			got, err := c.SelectHistory(tt.multi)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectHistory() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveHistory(t *testing.T) {
+	c := writeHistoryFixture(t)
+	removed, err := c.RemoveHistory("WIP", "Add hoge", "piyo")
+	if err != nil {
+		t.Fatalf("RemoveHistory() error = %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("RemoveHistory() removed = %v, want 2 entries", removed)
+	}
+	if got, want := readHistoryFixture(t, c), "# 2020/01/02 12:00:00\nFix fuga\\n\\nbody\n"; got != want {
+		t.Errorf("RemoveHistory() history = %q, want %q", got, want)
+	}
+}
+
+func TestEditHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		edited  string
+		want    string
+	}{
+		{
+			name:    "Normal",
+			message: "Add hoge",
+			edited:  "Add hoge and fuga",
+			want:    "# 2020/01/01 12:00:00\nAdd hoge and fuga\n# 2020/01/02 12:00:00\nFix fuga\\n\\nbody\nWIP\n",
+		},
+		{
+			name:    "NormalEmptyRemoves",
+			message: "WIP",
+			edited:  "",
+			want:    "# 2020/01/01 12:00:00\nAdd hoge\n# 2020/01/02 12:00:00\nFix fuga\\n\\nbody\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := writeHistoryFixture(t)
+			if err := c.EditHistory(tt.message, tt.edited); err != nil {
+				t.Fatalf("EditHistory() error = %v", err)
+			}
+			if got := readHistoryFixture(t, c); got != tt.want {
+				t.Errorf("EditHistory() history = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		runEditor func(ctx context.Context, fileName string) error
+		want      string
+		wantErr   bool
+	}{
+		{
+			name: "Normal",
+			runEditor: func(ctx context.Context, fileName string) error {
+				return ioutil.WriteFile(fileName, []byte("Fix fuga\n\nbody\n"), 0600)
+			},
+			want:    "Fix fuga\\n\\nbody",
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseRunEditorReturnError",
+			runEditor: func(ctx context.Context, fileName string) error {
+				return fmt.Errorf("error")
+			},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTempClient(t)
+			c.runEditor = tt.runEditor
+			got, err := c.EditMessage("Fix fuga")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EditMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("EditMessage() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRepairHistory(t *testing.T) {
+	c, _ := newTempClient(t)
+	history := "# 2020/01/01 12:00:00\n'Add hoge\\n\\n'\\n\n" +
+		"# 2020/01/02 12:00:00\n'Fix fuga\\n\\nbody\\n'\n" +
+		"# 2020/01/03 12:00:00\nDon't panic\n" +
+		"# 2020/01/04 12:00:00\n'Fix' the 'quotes'\n"
+	if err := ioutil.WriteFile(c.historyFilePath, []byte(history), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	repaired, err := c.RepairHistory()
+	if err != nil {
+		t.Fatalf("RepairHistory() error = %v", err)
+	}
+	if len(repaired) != 2 || repaired[0].Message != "'Add hoge\\n\\n'\\n" {
+		t.Errorf("RepairHistory() repaired = %v, want the first 2 entries", repaired)
+	}
+	want := "# 2020/01/01 12:00:00\nAdd hoge\n" +
+		"# 2020/01/02 12:00:00\nFix fuga\\n\\nbody\n" +
+		"# 2020/01/03 12:00:00\nDon't panic\n" +
+		"# 2020/01/04 12:00:00\n'Fix' the 'quotes'\n"
+	if got := readHistoryFixture(t, c); got != want {
+		t.Errorf("RepairHistory() history = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,163 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The git hooks InstallHook writes.
+const (
+	// PrepareCommitMsgHook chooses the message of a plain git commit with
+	// fcm, before the editor of git opens.
+	PrepareCommitMsgHook = "prepare-commit-msg"
+	// CommitMsgHook rejects the messages LintMessage finds problems in.
+	CommitMsgHook = "commit-msg"
+)
+
+// hookMarker is in every hook written by fcm, so that other hooks are left
+// alone.
+const hookMarker = "# Installed by fcm."
+
+var hookScripts = map[string]string{
+	PrepareCommitMsgHook: `#!/bin/sh
+` + hookMarker + ` Remove it with: fcm hook uninstall prepare-commit-msg
+# Leave the messages of -m, -F, --amend and merges alone.
+[ -z "$2" ] || exit 0
+# Without a terminal there is nobody to choose.
+( : </dev/tty ) 2>/dev/null || exit 0
+exec fcm hook run prepare-commit-msg "$@" </dev/tty
+`,
+	CommitMsgHook: `#!/bin/sh
+` + hookMarker + ` Remove it with: fcm hook uninstall commit-msg
+exec fcm hook run commit-msg "$@"
+`,
+}
+
+// HookState tells whether a hook is installed.
+type HookState int
+
+const (
+	// HookMissing is a hook that does not exist.
+	HookMissing HookState = iota
+	// HookInstalled is a hook written by fcm.
+	HookInstalled
+	// HookForeign is a hook of the same name that fcm did not write.
+	HookForeign
+)
+
+func (s HookState) String() string {
+	switch s {
+	case HookMissing:
+		return "missing"
+	case HookInstalled:
+		return "installed"
+	case HookForeign:
+		return "foreign"
+	}
+	return fmt.Sprintf("HookState(%d)", int(s))
+}
+
+// HookStatus returns the state and the path of a hook in the repository of
+// the working directory.
+func (c *Client) HookStatus(ctx context.Context, name string) (HookState, string, error) {
+	if _, ok := hookScripts[name]; !ok {
+		return HookMissing, "", fmt.Errorf("unknown hook %q, expected %s or %s", name, PrepareCommitMsgHook, CommitMsgHook)
+	}
+	dir, err := c.git.HooksDir(ctx)
+	if err != nil {
+		return HookMissing, "", err
+	}
+	path := filepath.Join(dir, name)
+
+	b, err := c.ioutilReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return HookMissing, path, nil
+		}
+		return HookMissing, path, err
+	}
+	if strings.Contains(string(b), hookMarker) {
+		return HookInstalled, path, nil
+	}
+	return HookForeign, path, nil
+}
+
+// InstallHook writes a hook into the repository of the working directory
+// and returns its path. A hook of the same name that fcm did not write is
+// only replaced with force.
+func (c *Client) InstallHook(ctx context.Context, name string, force bool) (path string, err error) {
+	state, path, err := c.HookStatus(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if state == HookForeign && !force {
+		return path, fmt.Errorf("%s exists and was not written by fcm", path)
+	}
+
+	if err := c.osMkdirAll(filepath.Dir(path), 0755); err != nil {
+		return path, err
+	}
+	if state != HookMissing {
+		if err := c.osRemove(path); err != nil {
+			return path, err
+		}
+	}
+	f, err := c.osOpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		return path, err
+	}
+	defer func() {
+		if err == nil {
+			err = c.fileClose(f)
+			return
+		}
+		c.fileClose(f)
+	}()
+	if _, err := c.fileWrite(f, []byte(hookScripts[name])); err != nil {
+		return path, err
+	}
+	return path, nil
+}
+
+// UninstallHook removes a hook written by fcm and returns its path. Other
+// hooks are left alone.
+func (c *Client) UninstallHook(ctx context.Context, name string) (string, error) {
+	state, path, err := c.HookStatus(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	switch state {
+	case HookMissing:
+		return path, nil
+	case HookForeign:
+		return path, fmt.Errorf("%s was not written by fcm", path)
+	}
+	return path, c.osRemove(path)
+}
+
+// PrepareMessage lets the user choose a message and writes it at the top of
+// fileName, the message file git hands to the prepare-commit-msg hook. The
+// comments git wrote into the file are kept. The message is not added to
+// the history, as the hook cannot tell whether the commit is made.
+func (c *Client) PrepareMessage(ctx context.Context, fileName string) error {
+	if err := c.resolvePaths(); err != nil {
+		return err
+	}
+	message, _, err := c.chooseMessage(ctx, []string{promoteKey})
+	if err != nil {
+		return err
+	}
+
+	b, err := c.ioutilReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	return c.writeFileAtomic(fileName, func(w io.Writer) error {
+		_, err := c.fmtFprintf(w, "%s\n%s", unescapeMessage(message), b)
+		return err
+	})
+}
@@ -0,0 +1,127 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestClient_InstallHook(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+
+	state, path, err := r.c.HookStatus(ctx, CommitMsgHook)
+	if err != nil || state != HookMissing {
+		t.Fatalf("HookStatus() = %v, %q, %v, want %v", state, path, err, HookMissing)
+	}
+	if got, err := r.c.InstallHook(ctx, CommitMsgHook, false); err != nil || got != path {
+		t.Fatalf("InstallHook() = %q, %v, want %q", got, err, path)
+	}
+	if state, _, err := r.c.HookStatus(ctx, CommitMsgHook); err != nil || state != HookInstalled {
+		t.Errorf("HookStatus() after InstallHook() = %v, %v, want %v", state, err, HookInstalled)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		t.Errorf("InstallHook() wrote %s with mode %v", path, info.Mode())
+	}
+
+	// Reinstalling replaces the hook of fcm.
+	if _, err := r.c.InstallHook(ctx, CommitMsgHook, false); err != nil {
+		t.Errorf("InstallHook() again error = %v", err)
+	}
+	if _, err := r.c.UninstallHook(ctx, CommitMsgHook); err != nil {
+		t.Fatalf("UninstallHook() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("UninstallHook() left %s: %v", path, err)
+	}
+}
+
+func TestClient_InstallHook_foreign(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	path := filepath.Join(r.dir, ".git", "hooks", PrepareCommitMsgHook)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if state, _, err := r.c.HookStatus(ctx, PrepareCommitMsgHook); err != nil || state != HookForeign {
+		t.Errorf("HookStatus() = %v, %v, want %v", state, err, HookForeign)
+	}
+	if _, err := r.c.InstallHook(ctx, PrepareCommitMsgHook, false); err == nil {
+		t.Errorf("InstallHook() replaced a foreign hook without force")
+	}
+	if _, err := r.c.UninstallHook(ctx, PrepareCommitMsgHook); err == nil {
+		t.Errorf("UninstallHook() removed a foreign hook")
+	}
+	if _, err := r.c.InstallHook(ctx, PrepareCommitMsgHook, true); err != nil {
+		t.Fatalf("InstallHook() with force error = %v", err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "fcm hook run prepare-commit-msg") {
+		t.Errorf("InstallHook() wrote %q", b)
+	}
+}
+
+func TestClient_HookStatus(t *testing.T) {
+	c, _ := newTempClient(t)
+	tests := []struct {
+		name     string
+		hook     string
+		hooksDir func(ctx context.Context) (string, error)
+	}{
+		{
+			name: "ErrorBecauseUnknownHook",
+			hook: "pre-push",
+		},
+		{
+			name: "ErrorBecauseNotARepo",
+			hook: CommitMsgHook,
+			hooksDir: func(ctx context.Context) (string, error) {
+				return "", ErrNotARepo
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.git = &fakeGit{hooksDir: tt.hooksDir}
+			_, _, err := c.HookStatus(context.Background(), tt.hook)
+			if err == nil {
+				t.Errorf("HookStatus() error = %v, wantErr true", err)
+			}
+		})
+	}
+}
+
+func TestClient_PrepareMessage(t *testing.T) {
+	c, dir := newTempClient(t)
+	selectMessage(c, "Add hoge\\n\\nWith a body")
+	fileName := filepath.Join(dir, "COMMIT_EDITMSG")
+	if err := ioutil.WriteFile(fileName, []byte("\n# Please enter the commit message\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.PrepareMessage(context.Background(), fileName); err != nil {
+		t.Fatalf("PrepareMessage() error = %v", err)
+	}
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Add hoge\n\nWith a body\n\n# Please enter the commit message\n"; string(b) != want {
+		t.Errorf("PrepareMessage() wrote %q, want %q", b, want)
+	}
+}
@@ -0,0 +1,186 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// GitLogOptions selects the commits harvested by ImportGitLog.
+type GitLogOptions struct {
+	// Author limits the commits to an author pattern as understood by
+	// `git log --author`. "me" stands for the user.email of each repository.
+	Author string
+	// Since limits the commits to a date as understood by `git log --since`,
+	// e.g. "6.months".
+	Since string
+	// Bodies imports the whole messages instead of the subject lines.
+	Bodies bool
+}
+
+// ImportGitLog calls Client.ImportGitLog with the default Client.
+func ImportGitLog(dirs []string, opts GitLogOptions) ([]HistoryEntry, error) {
+	return std().ImportGitLog(dirs, opts)
+}
+
+// ImportGitLog adds the commit messages of the repositories in dirs to the
+// history, tagged with the repository name. Messages that are already in the
+// history, or that appear more than once, are imported only once.
+// Repositories without commits are skipped. It returns the imported entries.
+func (c *Client) ImportGitLog(dirs []string, opts GitLogOptions) (imported []HistoryEntry, err error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+	var harvested []HistoryEntry
+	for _, dir := range dirs {
+		entries, err := c.harvestGitLog(context.Background(), dir, opts)
+		if err != nil {
+			return nil, err
+		}
+		harvested = append(harvested, entries...)
+	}
+	sort.SliceStable(harvested, func(i, j int) bool {
+		return harvested[i].Time.Before(harvested[j].Time)
+	})
+
+	if err := c.createDefaultFile(c.historyFilePath); err != nil {
+		return nil, err
+	}
+
+	err = c.rewriteHistory(func(w io.Writer) error {
+		entries, err := c.readHistory()
+		if err != nil {
+			return err
+		}
+
+		messages := make([]string, 0, len(entries)+len(harvested))
+		for _, e := range entries {
+			messages = append(messages, e.Message)
+		}
+		known := len(c.removeDuplicate(messages))
+		for _, e := range harvested {
+			messages = append(messages, e.Message)
+		}
+
+		// removeDuplicate keeps first occurrences in order, so whatever
+		// follows the existing messages is new.
+		fresh := map[string]bool{}
+		for _, m := range c.removeDuplicate(messages)[known:] {
+			fresh[m] = true
+		}
+		for _, e := range harvested {
+			if fresh[e.Message] {
+				imported = append(imported, e)
+				fresh[e.Message] = false
+			}
+		}
+
+		// Imported messages are older than what fcm recorded itself, so they
+		// go first to keep the file roughly chronological.
+		return writeHistory(w, append(imported, entries...))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// FindRepositories calls Client.FindRepositories with the default Client.
+func FindRepositories(root string) ([]string, error) {
+	return std().FindRepositories(root)
+}
+
+// FindRepositories returns the git repositories found under root, including
+// root itself. Repositories nested inside other repositories are not searched.
+func (c *Client) FindRepositories(root string) ([]string, error) {
+	var repos []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if c.exists(filepath.Join(path, ".git")) {
+			repos = append(repos, path)
+			return filepath.SkipDir
+		}
+		if path != root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return repos, nil
+}
+
+// harvestGitLog returns the messages of the repository in dir, or none while
+// its branch has no commits. Errors name dir, as fcm import reads several
+// repositories.
+func (c *Client) harvestGitLog(ctx context.Context, dir string, opts GitLogOptions) ([]HistoryEntry, error) {
+	g := c.git.At(dir)
+	top, err := g.TopLevel(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	if len(top) == 0 {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNotARepo)
+	}
+	// git log fails on an unborn branch, which has nothing to import anyway.
+	head, err := g.HeadCommit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	if len(head) == 0 {
+		return nil, nil
+	}
+
+	author := opts.Author
+	if author == "me" {
+		emails, err := g.Config(ctx, "user.email")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+		if len(emails) == 0 || len(emails[len(emails)-1]) == 0 {
+			return nil, fmt.Errorf("%s: user.email is not set, so the commits of \"me\" cannot be told apart", dir)
+		}
+		author = emails[len(emails)-1]
+	}
+	commits, err := g.Log(ctx, LogOptions{Author: author, Since: opts.Since})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+
+	repo := repositoryName(dir)
+	var entries []HistoryEntry
+	for _, commit := range commits {
+		message := commit.Subject
+		if opts.Bodies {
+			message = commit.Message
+		}
+		message = escapeMessage(message)
+		// A leading "#" would turn the message into a comment line.
+		if len(message) == 0 || message[0:1] == "#" {
+			continue
+		}
+		entries = append(entries, HistoryEntry{
+			Time:    commit.Time,
+			Message: message,
+			Repo:    repo,
+		})
+	}
+	return entries, nil
+}
+
+func repositoryName(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return filepath.Base(dir)
+}
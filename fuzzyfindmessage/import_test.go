@@ -0,0 +1,187 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func (r *testRepo) commit(message, author string) {
+	r.t.Helper()
+	r.stage("hoge.txt", message)
+	r.git("commit", "-q", "--author="+author, "-m", message)
+}
+
+func TestImportGitLog(t *testing.T) {
+	tests := []struct {
+		name    string
+		history string
+		opts    GitLogOptions
+		want    []string
+	}{
+		{
+			name:    "NormalSubjects",
+			history: "",
+			opts:    GitLogOptions{},
+			want:    []string{"Add hoge", "Fix fuga", "Update piyo"},
+		},
+		{
+			name:    "NormalBodies",
+			history: "",
+			opts:    GitLogOptions{Bodies: true},
+			want:    []string{"Add hoge", "Fix fuga\\n\\nbecause of piyo", "Update piyo"},
+		},
+		{
+			name:    "NormalAuthorMe",
+			history: "",
+			opts:    GitLogOptions{Author: "me"},
+			want:    []string{"Add hoge", "Update piyo"},
+		},
+		{
+			name:    "NormalSkipKnownMessages",
+			history: "# 2020/01/01 12:00:00\nAdd hoge\n",
+			opts:    GitLogOptions{},
+			want:    []string{"Fix fuga", "Update piyo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			c := r.c
+			r.commit("Add hoge", "fcm <fcm@example.com>")
+			r.commit("Fix fuga\n\nbecause of piyo", "other <other@example.com>")
+			r.commit("Update piyo", "fcm <fcm@example.com>")
+			r.commit("Add hoge", "fcm <fcm@example.com>")
+			if err := ioutil.WriteFile(c.historyFilePath, []byte(tt.history), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			imported, err := c.ImportGitLog([]string{r.dir}, tt.opts)
+			if err != nil {
+				t.Fatalf("ImportGitLog() error = %v", err)
+			}
+			var got []string
+			for _, e := range imported {
+				got = append(got, e.Message)
+				if e.Repo != filepath.Base(r.dir) {
+					t.Errorf("ImportGitLog() repo = %v, want %v", e.Repo, filepath.Base(r.dir))
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ImportGitLog() got = %v, want %v", got, tt.want)
+			}
+
+			entries, err := c.History()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.want)+strings.Count(tt.history, "\n")/2 {
+				t.Errorf("History() has %d entries after import", len(entries))
+			}
+		})
+	}
+}
+
+func TestImportGitLog_repositories(t *testing.T) {
+	tests := []struct {
+		name      string
+		commit    bool
+		userEmail []string
+		dir       func(r *testRepo) string
+		opts      GitLogOptions
+		want      int
+		wantErr   string
+	}{
+		{
+			name:   "NormalUnbornBranch",
+			commit: false,
+			dir: func(r *testRepo) string {
+				return r.dir
+			},
+			opts: GitLogOptions{},
+			want: 0,
+		},
+		{
+			name:      "ErrorBecauseUserEmailIsNotSet",
+			commit:    true,
+			userEmail: []string{},
+			dir: func(r *testRepo) string {
+				return r.dir
+			},
+			opts:    GitLogOptions{Author: "me"},
+			wantErr: "user.email is not set",
+		},
+		{
+			name:   "ErrorBecauseNotARepository",
+			commit: false,
+			dir: func(r *testRepo) string {
+				return filepath.Dir(r.dir)
+			},
+			opts:    GitLogOptions{},
+			wantErr: ErrNotARepo.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			c := r.c
+			if tt.commit {
+				r.commit("Add hoge", "fcm <fcm@example.com>")
+			}
+			if tt.userEmail != nil {
+				c.git = &fakeGit{Git: c.git, config: func(ctx context.Context, key string) ([]string, error) {
+					return tt.userEmail, nil
+				}}
+			}
+			dir := tt.dir(r)
+
+			imported, err := c.ImportGitLog([]string{dir}, tt.opts)
+			if len(tt.wantErr) != 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.HasPrefix(err.Error(), dir+": ") {
+					t.Errorf("ImportGitLog() error = %v, want %q naming %s", err, tt.wantErr, dir)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ImportGitLog() error = %v", err)
+			}
+			if len(imported) != tt.want {
+				t.Errorf("ImportGitLog() imported %d, want %d", len(imported), tt.want)
+			}
+		})
+	}
+}
+
+func TestFindRepositories(t *testing.T) {
+	c := New()
+	root, err := ioutil.TempDir("", "fcm-repos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, dir := range []string{
+		"hoge/.git",
+		"hoge/nested/.git",
+		"work/fuga/.git",
+		"work/piyo",
+		".cache/foo/.git",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := c.FindRepositories(root)
+	if err != nil {
+		t.Fatalf("FindRepositories() error = %v", err)
+	}
+	want := []string{filepath.Join(root, "hoge"), filepath.Join(root, "work", "fuga")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindRepositories() got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+package fuzzyfindmessage
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	includeDirective    = "#include"
+	includeDirDirective = "#include-dir"
+)
+
+// parseDirective recognises "#include <file>" and "#include-dir <dir>" lines.
+// Anything else starting with "#" stays a comment or category header.
+func parseDirective(line string) (directive, path string, ok bool) {
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) != 2 {
+		return "", "", false
+	}
+	switch fields[0] {
+	case includeDirective, includeDirDirective:
+		path = strings.TrimSpace(fields[1])
+		if len(path) == 0 {
+			return "", "", false
+		}
+		return fields[0], path, true
+	}
+	return "", "", false
+}
+
+func isDirective(line string) bool {
+	_, _, ok := parseDirective(line)
+	return ok
+}
+
+// resolvePath expands "~/" and makes path relative to the directory of the
+// file containing the directive.
+func (c *Client) resolvePath(path, baseDir string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = filepath.Join(c.home, path[1:])
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	return filepath.Clean(path)
+}
+
+// _includeTemplates returns the templates of the file or directory named by
+// an include directive found in the file from. Includes are followed
+// recursively; chain holds the files being included to detect cycles.
+func (c *Client) _includeTemplates(directive, path, from string, chain []string) ([]Candidate, error) {
+	path = c.resolvePath(path, filepath.Dir(from))
+	if err := c.confineToRepository(path, chain); err != nil {
+		return nil, fmt.Errorf("%s: %s", from, err)
+	}
+	if directive == includeDirective {
+		return c.readTemplateFile(path, chain)
+	}
+
+	infos, err := c.ioutilReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", from, err)
+	}
+	names := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") || strings.HasSuffix(info.Name(), "~") {
+			continue
+		}
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+
+	var templates []Candidate
+	for _, name := range names {
+		t, err := c.readTemplateFile(filepath.Join(path, name), chain)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t...)
+	}
+	return templates, nil
+}
+
+// _readTemplateFile returns the templates of a template file, resolving its
+// include directives. chain holds the files including it, outermost first.
+func (c *Client) _readTemplateFile(path string, chain []string) (templates []Candidate, err error) {
+	for _, p := range chain {
+		if p == path {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), path)
+		}
+	}
+	if err := c.confineToRepository(path, chain); err != nil {
+		if len(chain) != 0 {
+			return nil, fmt.Errorf("%s: %s", chain[len(chain)-1], err)
+		}
+		return nil, err
+	}
+	chain = append(chain, path)
+
+	file, err := c.osOpen(path)
+	if err != nil {
+		if len(chain) > 1 {
+			return nil, fmt.Errorf("%s: %s", chain[len(chain)-2], err)
+		}
+		return nil, err
+	}
+	defer c.fileClose(file)
+
+	origin := c.displayPath(path)
+	scanner := c.bufioNewScanner(file)
+	for c.scannerScan(scanner) {
+		s := strings.TrimRight(c.scannerText(scanner), "\r")
+		if len(s) == 0 {
+			continue
+		}
+
+		if s[0:1] == "#" {
+			if directive, p, ok := parseDirective(s); ok {
+				t, err := c.includeTemplates(directive, p, path, chain)
+				if err != nil {
+					return nil, err
+				}
+				templates = append(templates, t...)
+			}
+			continue
+		}
+		templates = append(templates, Candidate{Message: s, Origin: origin})
+	}
+
+	return templates, nil
+}
+
+// confineToRepository rejects path when the outermost file of chain, or path
+// itself without one, lies in the repository of the working directory and
+// path resolves outside of it: as the repository may come from anyone, its
+// templates must not read files such as ~/.ssh/id_rsa. The template file of
+// the user is not confined, even in a home directory kept in git.
+func (c *Client) confineToRepository(path string, chain []string) error {
+	root := path
+	if len(chain) != 0 {
+		root = chain[0]
+	}
+	if root == c.exampleFilePath {
+		return nil
+	}
+	top := c.repoTopLevel()
+	if len(top) == 0 || !inDir(root, top) {
+		return nil
+	}
+	return checkInDir(path, top)
+}
+
+// checkInDir returns an error unless path, with symbolic links followed,
+// lies in dir.
+func checkInDir(path, dir string) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		// A missing file fails when it is read.
+		resolved = path
+	}
+	if realDir, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = realDir
+	}
+	if !inDir(resolved, dir) {
+		return fmt.Errorf("%s is outside of the repository %s", path, dir)
+	}
+	return nil
+}
+
+// inDir tells whether the clean path lies in dir, or is dir.
+func inDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// displayPath abbreviates the home directory to "~".
+func (c *Client) displayPath(path string) string {
+	if len(c.home) != 0 && strings.HasPrefix(path, c.home+string(filepath.Separator)) {
+		return "~" + path[len(c.home):]
+	}
+	return path
+}
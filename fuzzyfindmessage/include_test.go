@@ -0,0 +1,280 @@
+package fuzzyfindmessage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseDirective(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		wantDirective string
+		wantPath      string
+		wantOk        bool
+	}{
+		{
+			name:          "NormalInclude",
+			line:          "#include ~/team/fcm/backend.fcm",
+			wantDirective: includeDirective,
+			wantPath:      "~/team/fcm/backend.fcm",
+			wantOk:        true,
+		},
+		{
+			name:          "NormalIncludeDir",
+			line:          "#include-dir ./.fcm.d/ ",
+			wantDirective: includeDirDirective,
+			wantPath:      "./.fcm.d/",
+			wantOk:        true,
+		},
+		{
+			name:   "NormalCategoryHeader",
+			line:   "# include files",
+			wantOk: false,
+		},
+		{
+			name:   "NormalIncludeWithoutPath",
+			line:   "#include ",
+			wantOk: false,
+		},
+		{
+			name:   "NormalOtherComment",
+			line:   "#includes are nice",
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directive, path, ok := parseDirective(tt.line)
+			if directive != tt.wantDirective || path != tt.wantPath || ok != tt.wantOk {
+				t.Errorf("parseDirective() = %q, %q, %v, want %q, %q, %v", directive, path, ok, tt.wantDirective, tt.wantPath, tt.wantOk)
+			}
+		})
+	}
+}
+
+func Test_samplesInclude(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		want        []string
+		wantWarning string
+		wantErr     bool
+	}{
+		{
+			name: "Normal",
+			files: map[string]string{
+				".fcm":                   "# Mine\nMine\n#include team/backend.fcm\n#include-dir ./.fcm.d/\n",
+				"team/backend.fcm":       "# Backend\nBackend\n#include ../company.fcm\n",
+				"company.fcm":            "Company\n",
+				".fcm.d/a.fcm":           "Directory A\n",
+				".fcm.d/b":               "Directory B\n",
+				".fcm.d/.hidden":         "Hidden\n",
+				".fcm.d/nested/skip.fcm": "Nested\n",
+			},
+			want:    []string{"Mine", "Directory B", "Directory A", "Company", "Backend"},
+			wantErr: false,
+		},
+		{
+			name: "NormalHome",
+			files: map[string]string{
+				".fcm":      "#include ~/home.fcm\n",
+				"home.fcm":  "Home\n",
+				"other.fcm": "Other\n",
+			},
+			want:    []string{"Home"},
+			wantErr: false,
+		},
+		{
+			name: "NormalSkipBecauseCycle",
+			files: map[string]string{
+				".fcm":  "#include a.fcm\n",
+				"a.fcm": "A\n#include b.fcm\n",
+				"b.fcm": "B\n#include a.fcm\n",
+			},
+			want:        nil,
+			wantWarning: "include cycle",
+			wantErr:     false,
+		},
+		{
+			name: "NormalSkipBecauseSelfInclude",
+			files: map[string]string{
+				".fcm": "#include .fcm\n",
+			},
+			want:        nil,
+			wantWarning: "include cycle",
+			wantErr:     false,
+		},
+		{
+			name: "NormalSkipBecauseMissingFile",
+			files: map[string]string{
+				".fcm": "#include missing.fcm\n",
+			},
+			want:        nil,
+			wantWarning: "missing.fcm",
+			wantErr:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newTempClient(t)
+			c.home = dir
+			for name, content := range tt.files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stderr := &bytes.Buffer{}
+			c.stderr = stderr
+			candidates, err := c._samples(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("samples() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var got []string
+			for _, s := range candidates {
+				got = append(got, s.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("samples() got = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(stderr.String(), tt.wantWarning) || (len(tt.wantWarning) == 0) != (stderr.Len() == 0) {
+				t.Errorf("samples() stderr = %q, want %q", stderr.String(), tt.wantWarning)
+			}
+		})
+	}
+}
+
+func Test_samplesRepositoryInclude(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		links       map[string]string
+		want        []string
+		wantWarning string
+	}{
+		{
+			name: "Normal",
+			files: map[string]string{
+				"repo/.fcm":          "Repository\n#include docs/team.fcm\n#include-dir docs/more\n",
+				"repo/docs/team.fcm": "Team\n",
+				"repo/docs/more/a":   "More\n",
+			},
+			want:        []string{"Team", "Repository", "More", "Mine"},
+			wantWarning: "",
+		},
+		{
+			name: "NormalSkipBecauseAboveRepository",
+			files: map[string]string{
+				"repo/.fcm":   "Repository\n#include ../outside.fcm\n",
+				"outside.fcm": "Outside\n",
+			},
+			want:        []string{"Mine"},
+			wantWarning: "outside of the repository",
+		},
+		{
+			name: "NormalSkipBecauseHome",
+			files: map[string]string{
+				"repo/.fcm":   "Repository\n#include ~/outside.fcm\n",
+				"outside.fcm": "Outside\n",
+			},
+			want:        []string{"Mine"},
+			wantWarning: "outside of the repository",
+		},
+		{
+			name: "NormalSkipBecauseDirectoryAboveRepository",
+			files: map[string]string{
+				"repo/.fcm":       "Repository\n#include-dir ../outside\n",
+				"outside/secrets": "Outside\n",
+			},
+			want:        []string{"Mine"},
+			wantWarning: "outside of the repository",
+		},
+		{
+			name: "NormalSkipBecauseNestedInclude",
+			files: map[string]string{
+				"repo/.fcm":          "Repository\n#include docs/team.fcm\n",
+				"repo/docs/team.fcm": "Team\n#include ../../outside.fcm\n",
+				"outside.fcm":        "Outside\n",
+			},
+			want:        []string{"Mine"},
+			wantWarning: "outside of the repository",
+		},
+		{
+			name: "NormalSkipBecauseLinkOutOfRepository",
+			files: map[string]string{
+				"repo/.fcm":   "Repository\n#include team.fcm\n",
+				"outside.fcm": "Outside\n",
+			},
+			links:       map[string]string{"repo/team.fcm": "outside.fcm"},
+			want:        []string{"Mine"},
+			wantWarning: "outside of the repository",
+		},
+		{
+			name: "NormalSkipBecauseTemplateFileLinksOutOfRepository",
+			files: map[string]string{
+				"outside.fcm": "Outside\n",
+			},
+			links:       map[string]string{"repo/.fcm": "outside.fcm"},
+			want:        []string{"Mine"},
+			wantWarning: "outside of the repository",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newTempClient(t)
+			c.home = dir
+			repo := filepath.Join(dir, "repo")
+			if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			c.osGetwd = func() (string, error) {
+				return repo, nil
+			}
+			files := map[string]string{".fcm": "Mine\n"}
+			for name, content := range tt.files {
+				files[name] = content
+			}
+			for name, content := range files {
+				path := filepath.Join(dir, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for name, target := range tt.links {
+				if err := os.Symlink(filepath.Join(dir, target), filepath.Join(dir, name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			stderr := &bytes.Buffer{}
+			c.stderr = stderr
+			candidates, err := c._samples(context.Background())
+			if err != nil {
+				t.Fatalf("samples() error = %v", err)
+			}
+			var got []string
+			for _, s := range candidates {
+				got = append(got, s.Message)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("samples() got = %v, want %v", got, tt.want)
+			}
+			if !strings.Contains(stderr.String(), tt.wantWarning) || (len(tt.wantWarning) == 0) != (stderr.Len() == 0) {
+				t.Errorf("samples() stderr = %q, want %q", stderr.String(), tt.wantWarning)
+			}
+		})
+	}
+}
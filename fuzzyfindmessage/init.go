@@ -0,0 +1,194 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// repoConfig is the .fcm_config Init writes, with the settings a repository
+// may change commented out. The gitmoji preset turns on the gitmoji picker.
+const repoConfig = `# Settings of fcm for this repository, read after ~/.fcm_config. Only
+# source (file sources), finder.new_message, editor and gitmoji can be set
+# here.
+#
+# commit the query as a new message when it matches nothing
+#finder.new_message = true
+# edit the message in the terminal instead of the editor of git
+#editor = inline
+# offer the templates of another file, relative to this one
+#source = "file: docs/commit-templates.txt"
+# pick a gitmoji for messages without one, written as a code or an emoji
+#gitmoji = code
+`
+
+// InitOptions are the choices of Init.
+type InitOptions struct {
+	// Preset is the name of the preset the .fcm of the repository starts
+	// with. Empty lets the user choose one with the finder.
+	Preset string
+	// Hooks are the git hooks to install, e.g. PrepareCommitMsgHook.
+	Hooks []string
+	// Force replaces an existing .fcm and .fcm_config.
+	Force bool
+}
+
+// Init calls Client.Init with the default Client.
+func Init(ctx context.Context, opts InitOptions) ([]string, error) {
+	return std().Init(ctx, opts)
+}
+
+// Init writes a .fcm with the templates of a preset and a .fcm_config into
+// the top level directory of the repository of the working directory, for
+// the team to share. fcm offers those templates next to ~/.fcm. Init also
+// installs the hooks of opts and keeps .fcm_history out of the repository
+// with .gitignore, as the history is personal. It returns the files written.
+func (c *Client) Init(ctx context.Context, opts InitOptions) ([]string, error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+	top, err := c.git.TopLevel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(top) == 0 {
+		return nil, ErrNotARepo
+	}
+
+	templatePath := filepath.Join(top, exampleFile)
+	configPath := filepath.Join(top, configFile)
+	for _, path := range []string{templatePath, configPath} {
+		if path == c.exampleFilePath || path == c.configFilePath {
+			return nil, fmt.Errorf("%s is your own file, not one of the repository", path)
+		}
+		if c.exists(path) && !opts.Force {
+			return nil, fmt.Errorf("%s exists already", path)
+		}
+	}
+
+	preset, err := c.choosePreset(ctx, opts.Preset)
+	if err != nil {
+		return nil, err
+	}
+	// Check the hooks before writing anything, so that a bad name leaves
+	// the repository as it was.
+	for _, name := range opts.Hooks {
+		state, path, err := c.HookStatus(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if state == HookForeign {
+			return nil, fmt.Errorf("%s exists and was not written by fcm", path)
+		}
+	}
+
+	if err := c.writeFile(templatePath, strings.Join(preset.Templates, "\n")+"\n"); err != nil {
+		return nil, err
+	}
+	written := []string{templatePath}
+	config := repoConfig
+	if preset.Name == "gitmoji" {
+		config = strings.Replace(config, "#gitmoji = ", "gitmoji = ", 1)
+	}
+	if err := c.writeFile(configPath, config); err != nil {
+		return written, err
+	}
+	written = append(written, configPath)
+
+	for _, name := range opts.Hooks {
+		path, err := c.InstallHook(ctx, name, false)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+
+	ignorePath := filepath.Join(top, ".gitignore")
+	added, err := c.ignoreHistory(ignorePath)
+	if err != nil {
+		return written, err
+	}
+	if added {
+		written = append(written, ignorePath)
+	}
+	return written, nil
+}
+
+// choosePreset returns the preset called name, or the one the user chooses
+// with the finder when name is empty.
+func (c *Client) choosePreset(ctx context.Context, name string) (Preset, error) {
+	if len(name) != 0 {
+		return findPreset(name)
+	}
+
+	items := make([]string, len(presets))
+	for i, p := range presets {
+		items[i] = fmt.Sprintf("%-12s  %s", p.Name, p.Description)
+	}
+	selection, err := selectItem(ctx, c.finder, items, func(i int) string {
+		if i < 0 {
+			return ""
+		}
+		return strings.Join(presets[i].Templates, "\n")
+	}, SelectOptions{})
+	if err != nil {
+		return Preset{}, err
+	}
+	return presets[selection.Index], nil
+}
+
+// ignoreHistory adds the history file to the .gitignore at path unless it is
+// ignored there already, and tells whether it did.
+func (c *Client) ignoreHistory(path string) (added bool, err error) {
+	b, err := c.ioutilReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == historyFile || line == "/"+historyFile {
+			return false, nil
+		}
+	}
+
+	file, err := c.osOpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err == nil {
+			err = c.fileClose(file)
+			return
+		}
+		c.fileClose(file)
+	}()
+
+	entry := "# The history of fcm is personal.\n" + historyFile + "\n"
+	if len(b) != 0 && !strings.HasSuffix(string(b), "\n") {
+		entry = "\n" + entry
+	}
+	if _, err := c.fileWrite(file, []byte(entry)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// writeFile creates or truncates the file at path and writes content to it.
+func (c *Client) writeFile(path, content string) (err error) {
+	file, err := c.osCreate(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = c.fileClose(file)
+			return
+		}
+		c.fileClose(file)
+	}()
+
+	_, err = c.fileWrite(file, []byte(content))
+	return err
+}
@@ -0,0 +1,197 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClient_Init(t *testing.T) {
+	r := newTestRepo(t)
+	ctx := context.Background()
+	if err := ioutil.WriteFile(filepath.Join(r.dir, ".gitignore"), []byte("/bin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := r.c.Init(ctx, InitOptions{Preset: "conventional", Hooks: []string{CommitMsgHook}})
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if len(written) != 4 {
+		t.Errorf("Init() written = %v, want .fcm, .fcm_config, the hook and .gitignore", written)
+	}
+	if state, _, err := r.c.HookStatus(ctx, CommitMsgHook); err != nil || state != HookInstalled {
+		t.Errorf("HookStatus() after Init() = %v, %v, want %v", state, err, HookInstalled)
+	}
+	ignore := readFile(t, filepath.Join(r.dir, ".gitignore"))
+	if want := "/bin\n# The history of fcm is personal.\n.fcm_history\n"; ignore != want {
+		t.Errorf("Init() .gitignore = %q, want %q", ignore, want)
+	}
+
+	samples, err := r.c.Samples(ctx)
+	if err != nil {
+		t.Fatalf("Samples() error = %v", err)
+	}
+	found := false
+	for _, s := range samples {
+		if s.Message == "feat({scope}): {summary}" && s.Origin == filepath.Join(r.dir, exampleFile) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Samples() has no template of the repository: %v", samples)
+	}
+
+	if _, err := r.c.Init(ctx, InitOptions{Preset: "default"}); err == nil {
+		t.Errorf("Init() replaced the files without Force")
+	}
+	if _, err := r.c.Init(ctx, InitOptions{Preset: "default", Force: true}); err != nil {
+		t.Fatalf("Init() with Force error = %v", err)
+	}
+	if got := readFile(t, filepath.Join(r.dir, ".gitignore")); got != ignore {
+		t.Errorf("Init() again .gitignore = %q, want %q", got, ignore)
+	}
+	if got := readFile(t, filepath.Join(r.dir, exampleFile)); !strings.HasPrefix(got, defaultExamples[0]+"\n") {
+		t.Errorf("Init() with Force .fcm = %q", got)
+	}
+}
+
+func TestClient_Init_errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		preset   string
+		hooks    []string
+		hookFile string
+		finder   Finder
+		top      string
+		want     error
+	}{
+		{
+			name:   "ErrorBecauseNotARepository",
+			preset: "default",
+			top:    "",
+			want:   ErrNotARepo,
+		},
+		{
+			name:   "ErrorBecauseUnknownPreset",
+			preset: "angular",
+			top:    "repo",
+		},
+		{
+			name:   "ErrorBecauseUnknownHook",
+			preset: "default",
+			hooks:  []string{CommitMsgHook, "pre-push"},
+			top:    "repo",
+		},
+		{
+			name:     "ErrorBecauseForeignHook",
+			preset:   "default",
+			hooks:    []string{CommitMsgHook},
+			hookFile: "#!/bin/sh\nexit 0\n",
+			top:      "repo",
+		},
+		{
+			name:   "ErrorBecauseAborted",
+			finder: &mockFinder{err: ErrAborted},
+			top:    "repo",
+			want:   ErrAborted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newTempClient(t)
+			top := tt.top
+			if len(top) != 0 {
+				top = filepath.Join(dir, top)
+				if err := os.Mkdir(top, 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			hooksDir := filepath.Join(dir, "hooks")
+			if len(tt.hookFile) != 0 {
+				if err := os.Mkdir(hooksDir, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(hooksDir, CommitMsgHook), []byte(tt.hookFile), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.git = &fakeGit{
+				topLevel: func(ctx context.Context) (string, error) {
+					return top, nil
+				},
+				hooksDir: func(ctx context.Context) (string, error) {
+					return hooksDir, nil
+				},
+			}
+			c.finder = tt.finder
+
+			_, err := c.Init(context.Background(), InitOptions{Preset: tt.preset, Hooks: tt.hooks})
+			if err == nil || tt.want != nil && err != tt.want {
+				t.Fatalf("Init() error = %v, want %v", err, tt.want)
+			}
+			for _, name := range []string{exampleFile, configFile} {
+				if len(top) != 0 && c.exists(filepath.Join(top, name)) {
+					t.Errorf("Init() wrote %s", filepath.Join(top, name))
+				}
+			}
+		})
+	}
+}
+
+func TestClient_Init_choosePreset(t *testing.T) {
+	c, dir := newTempClient(t)
+	top := filepath.Join(dir, "repo")
+	if err := os.MkdirAll(filepath.Join(top, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c.git = &fakeGit{topLevel: func(ctx context.Context) (string, error) {
+		return top, nil
+	}}
+	c.osGetwd = func() (string, error) {
+		return top, nil
+	}
+	f := &mockFinder{id: 2}
+	c.finder = f
+
+	if _, err := c.Init(context.Background(), InitOptions{}); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	if len(f.items) != len(presets) || !strings.HasPrefix(f.items[2], "gitmoji") {
+		t.Errorf("Init() offered %q", f.items)
+	}
+	if got := readFile(t, filepath.Join(top, exampleFile)); !strings.Contains(got, ":sparkles: {summary}\n") {
+		t.Errorf("Init() .fcm = %q, want the gitmoji preset", got)
+	}
+	cfg, err := c.Config()
+	if err != nil {
+		t.Fatalf("Config() error = %v", err)
+	}
+	if cfg.Gitmoji != GitmojiCode {
+		t.Errorf("Config() after Init() Gitmoji = %q, want %q", cfg.Gitmoji, GitmojiCode)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	for _, p := range Presets() {
+		if len(p.Templates) == 0 {
+			t.Errorf("preset %s has no templates", p.Name)
+		}
+		p.Templates[0] = "changed"
+	}
+	if presets[0].Templates[0] == "changed" {
+		t.Errorf("Presets() shares the templates of the presets")
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,76 @@
+package fuzzyfindmessage
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSubjectLength is the longest subject LintMessage accepts, which keeps
+// it readable in git log --oneline and in the subject of patch mails.
+const maxSubjectLength = 72
+
+// scissors is the line of git commit --verbose below which nothing is part
+// of the message.
+const scissors = "# ------------------------ >8 ------------------------"
+
+// LintProblem is a problem of a commit message found by LintMessage.
+type LintProblem struct {
+	// Line is the line of the message, starting at 1.
+	Line int
+	// Message describes the problem.
+	Message string
+}
+
+func (p LintProblem) String() string {
+	return fmt.Sprintf("%d: %s", p.Line, p.Message)
+}
+
+// LintMessage checks a commit message the way git stores it: the lines
+// starting with "#" and everything below the scissors line are ignored. It
+// reports an empty message, a subject that is too long or ends with a
+// period, a missing blank line after the subject and unfilled placeholders
+// such as {ticket}.
+func LintMessage(message string) []LintProblem {
+	type line struct {
+		n    int
+		text string
+	}
+	var lines []line
+	for i, text := range strings.Split(strings.Replace(message, "\r\n", "\n", -1), "\n") {
+		if text == scissors {
+			break
+		}
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		lines = append(lines, line{n: i + 1, text: strings.TrimRight(text, " \t")})
+	}
+	for len(lines) != 0 && len(lines[0].text) == 0 {
+		lines = lines[1:]
+	}
+	for len(lines) != 0 && len(lines[len(lines)-1].text) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return []LintProblem{{Line: 1, Message: "the message is empty"}}
+	}
+
+	var problems []LintProblem
+	subject := lines[0]
+	if n := utf8.RuneCountInString(subject.text); n > maxSubjectLength {
+		problems = append(problems, LintProblem{Line: subject.n, Message: fmt.Sprintf("the subject is %d characters long, keep it within %d", n, maxSubjectLength)})
+	}
+	if strings.HasSuffix(subject.text, ".") && !strings.HasSuffix(subject.text, "...") {
+		problems = append(problems, LintProblem{Line: subject.n, Message: "the subject ends with a period"})
+	}
+	if len(lines) > 1 && len(lines[1].text) != 0 {
+		problems = append(problems, LintProblem{Line: lines[1].n, Message: "separate the subject from the body with a blank line"})
+	}
+	for _, l := range lines {
+		for _, placeholder := range placeholderPattern.FindAllString(l.text, -1) {
+			problems = append(problems, LintProblem{Line: l.n, Message: fmt.Sprintf("the placeholder %s is not filled in", placeholder)})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,71 @@
+package fuzzyfindmessage
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    []LintProblem
+	}{
+		{
+			name:    "Normal",
+			message: "Add hoge\n\nWith a body\n",
+			want:    nil,
+		},
+		{
+			name:    "NormalComments",
+			message: "\nAdd hoge\n# Please enter the commit message {for} your changes.\n\n",
+			want:    nil,
+		},
+		{
+			name:    "NormalScissors",
+			message: "Add hoge\n" + scissors + "\ndiff --git a/{file} b/{file}\n",
+			want:    nil,
+		},
+		{
+			name:    "NormalEllipsis",
+			message: "Add hoge...",
+			want:    nil,
+		},
+		{
+			name:    "ErrorBecauseEmpty",
+			message: "\n# Please enter the commit message\n",
+			want:    []LintProblem{{Line: 1, Message: "the message is empty"}},
+		},
+		{
+			name:    "ErrorBecauseLongSubject",
+			message: strings.Repeat("あ", 73),
+			want:    []LintProblem{{Line: 1, Message: "the subject is 73 characters long, keep it within 72"}},
+		},
+		{
+			name:    "ErrorBecausePeriod",
+			message: "Add hoge.",
+			want:    []LintProblem{{Line: 1, Message: "the subject ends with a period"}},
+		},
+		{
+			name:    "ErrorBecauseNoBlankLine",
+			message: "# comment\nAdd hoge\nWith a body",
+			want:    []LintProblem{{Line: 3, Message: "separate the subject from the body with a blank line"}},
+		},
+		{
+			name:    "ErrorBecausePlaceholders",
+			message: "Fix {ticket}: login\n\nSee {url}",
+			want: []LintProblem{
+				{Line: 1, Message: "the placeholder {ticket} is not filled in"},
+				{Line: 3, Message: "the placeholder {url} is not filled in"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LintMessage(tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LintMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package fuzzyfindmessage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const lockSuffix = ".lock"
+
+// _lockHistory takes an advisory lock that serialises history access between
+// fcm processes. The lock is held on a sibling file rather than on the history
+// itself so that it stays valid while the history is replaced by a rename.
+// Only writers create the lock file, so that reading the history, as fcm
+// doctor does, leaves no file behind; without one, nobody has written yet.
+func (c *Client) _lockHistory(exclusive bool) (unlock func() error, err error) {
+	flag := os.O_RDWR | os.O_CREATE
+	if !exclusive {
+		flag = os.O_RDONLY
+	}
+	f, err := c.osOpenFile(c.historyFilePath+lockSuffix, flag, 0666)
+	if !exclusive && os.IsNotExist(err) {
+		return func() error { return nil }, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		c.fileClose(f)
+		return nil, err
+	}
+
+	return func() error {
+		if err := unlockFile(f); err != nil {
+			c.fileClose(f)
+			return err
+		}
+		return c.fileClose(f)
+	}, nil
+}
+
+// _rewriteHistory replaces the whole history file with the output of write
+// while holding the exclusive history lock.
+func (c *Client) _rewriteHistory(write func(w io.Writer) error) (err error) {
+	unlock, err := c.lockHistory(true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			err = unlock()
+			return
+		}
+		unlock()
+	}()
+
+	return c.writeFileAtomic(c.historyFilePath, write)
+}
+
+// _writeFileAtomic writes into a temporary file next to filePath and renames it
+// over filePath, so readers see either the old or the new content in full.
+func (c *Client) _writeFileAtomic(filePath string, write func(w io.Writer) error) (err error) {
+	f, err := c.ioutilTempFile(filepath.Dir(filePath), filepath.Base(filePath)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := c.tmpFileName(f)
+	defer func() {
+		if err != nil {
+			c.fileClose(f)
+			c.osRemove(tmp)
+		}
+	}()
+
+	if err := write(f); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if info, err := c.osStat(filePath); err == nil {
+		if err := f.Chmod(info.Mode()); err != nil {
+			return err
+		}
+	}
+	if err := c.fileClose(f); err != nil {
+		return err
+	}
+
+	return c.osRename(tmp, filePath)
+}
@@ -0,0 +1,232 @@
+package fuzzyfindmessage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test__lockHistory(t *testing.T) {
+	c, _ := newTempClient(t)
+
+	unlock, err := c._lockHistory(true)
+	if err != nil {
+		t.Fatalf("lockHistory() error = %v", err)
+	}
+
+	locked := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		unlock, err := c._lockHistory(false)
+		close(locked)
+		if err != nil {
+			t.Errorf("lockHistory() error = %v", err)
+			return
+		}
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("shared lock acquired while exclusive lock is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock() error = %v", err)
+	}
+	<-done
+}
+
+func Test__lockHistorySharedWithoutLockFile(t *testing.T) {
+	c, _ := newTempClient(t)
+
+	unlock, err := c._lockHistory(false)
+	if err != nil {
+		t.Fatalf("lockHistory() error = %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("unlock() error = %v", err)
+	}
+	if c.exists(c.historyFilePath + lockSuffix) {
+		t.Errorf("lockHistory(false) created %s", c.historyFilePath+lockSuffix)
+	}
+}
+
+func Test__writeFileAtomic(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  string
+		write   func(w io.Writer) error
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "Normal",
+			before: "hoge\n",
+			write: func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, "fuga")
+				return err
+			},
+			want:    "fuga\n",
+			wantErr: false,
+		},
+		{
+			name:   "ErrorBecauseWriteReturnErrorKeepsOriginal",
+			before: "hoge\n",
+			write: func(w io.Writer) error {
+				fmt.Fprintln(w, "fuga")
+				return fmt.Errorf("error")
+			},
+			want:    "hoge\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, dir := newTempClient(t)
+			if err := ioutil.WriteFile(c.historyFilePath, []byte(tt.before), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if err := c._writeFileAtomic(c.historyFilePath, tt.write); (err != nil) != tt.wantErr {
+				t.Errorf("writeFileAtomic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, err := ioutil.ReadFile(c.historyFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("writeFileAtomic() got = %q, want %q", got, tt.want)
+			}
+			files, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Errorf("writeFileAtomic() left temporary files behind: %d files", len(files))
+			}
+		})
+	}
+}
+
+func Test__rewriteHistory(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name            string
+		lockHistory     func(exclusive bool) (func() error, error)
+		writeFileAtomic func(filePath string, write func(w io.Writer) error) error
+		wantErr         bool
+	}{
+		{
+			name: "Normal",
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return nil }, nil
+			},
+			writeFileAtomic: func(filePath string, write func(w io.Writer) error) error {
+				return nil
+			},
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseLockHistoryReturnError",
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return nil, fmt.Errorf("error")
+			},
+			writeFileAtomic: nil,
+			wantErr:         true,
+		},
+		{
+			name: "ErrorBecauseWriteFileAtomicReturnError",
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return nil }, nil
+			},
+			writeFileAtomic: func(filePath string, write func(w io.Writer) error) error {
+				return fmt.Errorf("error")
+			},
+			wantErr: true,
+		},
+		{
+			name: "ErrorBecauseUnlockReturnError",
+			lockHistory: func(exclusive bool) (func() error, error) {
+				return func() error { return fmt.Errorf("error") }, nil
+			},
+			writeFileAtomic: func(filePath string, write func(w io.Writer) error) error {
+				return nil
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.lockHistory = tt.lockHistory
+			c.writeFileAtomic = tt.writeFileAtomic
+			if err := c._rewriteHistory(func(w io.Writer) error { return nil }); (err != nil) != tt.wantErr {
+				t.Errorf("rewriteHistory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Test_saveHistoryConcurrent appends and rewrites the history from many
+// goroutines at once and checks that every entry survives intact.
+func Test_saveHistoryConcurrent(t *testing.T) {
+	c, _ := newTempClient(t)
+	c.git = &fakeGit{headMessage: func(ctx context.Context) (string, error) {
+		return "Add hoge\nwith a body", nil
+	}}
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c._saveHistory(context.Background()); err != nil {
+				t.Errorf("saveHistory() error = %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			err := c._rewriteHistory(func(w io.Writer) error {
+				b, err := ioutil.ReadFile(c.historyFilePath)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				_, err = w.Write(b)
+				return err
+			})
+			if err != nil {
+				t.Errorf("rewriteHistory() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	f, err := os.Open(c.historyFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	entries := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if !strings.HasPrefix(scanner.Text(), "# ") {
+			t.Fatalf("expected a timestamp line, got %q", scanner.Text())
+		}
+		if !scanner.Scan() || scanner.Text() != "Add hoge\\nwith a body" {
+			t.Fatalf("entry %d is broken: %q", entries, scanner.Text())
+		}
+		entries++
+	}
+	if entries != writers {
+		t.Errorf("history has %d entries, want %d", entries, writers)
+	}
+}
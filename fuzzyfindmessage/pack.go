@@ -0,0 +1,206 @@
+package fuzzyfindmessage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MergeStrategy decides how ImportPack combines a pack with ~/.fcm.
+type MergeStrategy string
+
+const (
+	// MergeAppend adds every template of the pack to its category.
+	MergeAppend MergeStrategy = "append"
+	// MergeReplace replaces the categories contained in the pack with the
+	// pack's version. Other categories are left untouched.
+	MergeReplace MergeStrategy = "replace"
+	// MergeSkipDuplicates adds only the templates that are not in ~/.fcm yet.
+	MergeSkipDuplicates MergeStrategy = "skip"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{[A-Za-z][\w-]*\}`)
+
+// Pack is a portable set of templates that can be shared between users, for
+// example through a dotfiles repository.
+type Pack struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Placeholders lists the placeholders such as "{ticket}" that are used by
+	// the templates.
+	Placeholders []string       `json:"placeholders,omitempty"`
+	Categories   []PackCategory `json:"categories"`
+}
+
+// PackCategory is a category header of the template file and its templates.
+// Templates placed before the first header have an empty Name.
+type PackCategory struct {
+	Name      string   `json:"name"`
+	Templates []string `json:"templates"`
+}
+
+// ExportPack calls Client.ExportPack with the default Client.
+func ExportPack(w io.Writer, meta Pack) error {
+	return std().ExportPack(w, meta)
+}
+
+// ExportPack writes the templates of ~/.fcm as a pack in JSON. The metadata
+// is taken from meta, the categories and placeholders from the template file.
+func (c *Client) ExportPack(w io.Writer, meta Pack) error {
+	if err := c.resolvePaths(); err != nil {
+		return err
+	}
+	if err := c.createDefaultFile(c.exampleFilePath); err != nil {
+		return err
+	}
+	lines, err := c.readTemplateLines()
+	if err != nil {
+		return err
+	}
+
+	pack := meta
+	pack.Categories = nil
+	placeholders := map[string]bool{}
+	for _, l := range lines {
+		// Included files are shared by other means than the pack.
+		if len(strings.TrimSpace(l)) == 0 || isDirective(l) {
+			continue
+		}
+		if name, ok := categoryName(l); ok {
+			pack.Categories = append(pack.Categories, PackCategory{Name: name})
+			continue
+		}
+		if len(pack.Categories) == 0 {
+			pack.Categories = append(pack.Categories, PackCategory{})
+		}
+		last := &pack.Categories[len(pack.Categories)-1]
+		last.Templates = append(last.Templates, l)
+		for _, p := range placeholderPattern.FindAllString(l, -1) {
+			placeholders[p] = true
+		}
+	}
+
+	pack.Placeholders = nil
+	for p := range placeholders {
+		pack.Placeholders = append(pack.Placeholders, p)
+	}
+	sort.Strings(pack.Placeholders)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(pack)
+}
+
+// ImportPack calls Client.ImportPack with the default Client.
+func ImportPack(r io.Reader, strategy MergeStrategy) (*Pack, int, error) {
+	return std().ImportPack(r, strategy)
+}
+
+// ImportPack reads a pack written by ExportPack and merges it into ~/.fcm
+// with strategy. It returns the pack and the number of templates added, which
+// for MergeReplace are those the category did not hold yet. Newlines in the
+// templates are escaped; templates starting with "#", which the template
+// file would read as comments or include directives, are rejected.
+func (c *Client) ImportPack(r io.Reader, strategy MergeStrategy) (*Pack, int, error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, 0, err
+	}
+	var pack Pack
+	if err := json.NewDecoder(r).Decode(&pack); err != nil {
+		return nil, 0, fmt.Errorf("invalid pack: %s", err)
+	}
+	switch strategy {
+	case MergeAppend, MergeReplace, MergeSkipDuplicates:
+	default:
+		return nil, 0, fmt.Errorf("unknown merge strategy %q", strategy)
+	}
+	if err := pack.normalize(); err != nil {
+		return nil, 0, fmt.Errorf("invalid pack: %s", err)
+	}
+
+	if err := c.createDefaultFile(c.exampleFilePath); err != nil {
+		return nil, 0, err
+	}
+	lines, err := c.readTemplateLines()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	known := map[string]bool{}
+	for _, l := range lines {
+		known[l] = true
+	}
+
+	added := 0
+	for _, category := range pack.Categories {
+		templates := category.Templates
+		if strategy == MergeSkipDuplicates {
+			templates = nil
+			for _, t := range category.Templates {
+				if !known[t] {
+					templates = append(templates, t)
+					known[t] = true
+				}
+			}
+		}
+
+		if strategy == MergeReplace {
+			previous := map[string]bool{}
+			if start, end, ok := categoryRange(lines, category.Name); ok {
+				for _, l := range lines[start:end] {
+					previous[l] = true
+				}
+			}
+			for _, t := range templates {
+				if !previous[t] {
+					added++
+				}
+			}
+			lines = replaceCategory(lines, category.Name, templates)
+			continue
+		}
+		for _, t := range templates {
+			lines = appendToCategory(lines, t, category.Name)
+			added++
+		}
+	}
+
+	err = c.writeFileAtomic(c.exampleFilePath, func(w io.Writer) error {
+		return writeLines(w, lines)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &pack, added, nil
+}
+
+// normalize escapes the templates of p as the template file holds them and
+// drops blank ones. It fails on what would not read back as templates and
+// category headers.
+func (p *Pack) normalize() error {
+	for i := range p.Categories {
+		category := &p.Categories[i]
+		if strings.ContainsAny(category.Name, "\r\n") {
+			return fmt.Errorf("category %q spans several lines", category.Name)
+		}
+		templates := make([]string, 0, len(category.Templates))
+		for _, t := range category.Templates {
+			t = escapeMessage(strings.Replace(t, "\r\n", "\n", -1))
+			if len(strings.TrimSpace(t)) == 0 {
+				continue
+			}
+			if strings.HasPrefix(t, "#") {
+				return fmt.Errorf("template %q starts with \"#\"", t)
+			}
+			templates = append(templates, t)
+		}
+		category.Templates = templates
+	}
+	return nil
+}
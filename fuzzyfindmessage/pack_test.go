@@ -0,0 +1,181 @@
+package fuzzyfindmessage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportPack(t *testing.T) {
+	c, _ := newTempClient(t)
+	content := "Top level\n# Add\nAdd {file}\n\n# Fix\nFix {ticket} in {file}\n"
+	if err := ioutil.WriteFile(c.exampleFilePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExportPack(&buf, Pack{Name: "team", Version: "1.0.0", Author: "fcm"}); err != nil {
+		t.Fatalf("ExportPack() error = %v", err)
+	}
+
+	_, _, err := c.ImportPack(strings.NewReader(buf.String()), MergeSkipDuplicates)
+	if err != nil {
+		t.Fatalf("ImportPack() error = %v", err)
+	}
+	got, err := ioutil.ReadFile(c.exampleFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("exported pack did not round trip: %q", got)
+	}
+
+	want := `{
+  "name": "team",
+  "version": "1.0.0",
+  "author": "fcm",
+  "placeholders": [
+    "{file}",
+    "{ticket}"
+  ],
+  "categories": [
+    {
+      "name": "",
+      "templates": [
+        "Top level"
+      ]
+    },
+    {
+      "name": "Add",
+      "templates": [
+        "Add {file}"
+      ]
+    },
+    {
+      "name": "Fix",
+      "templates": [
+        "Fix {ticket} in {file}"
+      ]
+    }
+  ]
+}
+`
+	if buf.String() != want {
+		t.Errorf("ExportPack() got = %s, want %s", buf.String(), want)
+	}
+}
+
+func TestImportPack(t *testing.T) {
+	pack := `{
+  "name": "team",
+  "categories": [
+    {"name": "Add", "templates": ["Add hoge", "Add piyo"]},
+    {"name": "Remove", "templates": ["Remove hoge"]}
+  ]
+}`
+	tests := []struct {
+		name      string
+		pack      string
+		strategy  MergeStrategy
+		want      string
+		wantAdded int
+		wantErr   bool
+	}{
+		{
+			name:      "NormalAppend",
+			pack:      pack,
+			strategy:  MergeAppend,
+			want:      "# Add\nAdd hoge\nAdd hoge\nAdd piyo\n\n# Fix\nFix fuga\n# Remove\nRemove hoge\n",
+			wantAdded: 3,
+			wantErr:   false,
+		},
+		{
+			name:      "NormalSkipDuplicates",
+			pack:      pack,
+			strategy:  MergeSkipDuplicates,
+			want:      "# Add\nAdd hoge\nAdd piyo\n\n# Fix\nFix fuga\n# Remove\nRemove hoge\n",
+			wantAdded: 2,
+			wantErr:   false,
+		},
+		{
+			name:      "NormalReplace",
+			pack:      `{"name": "team", "categories": [{"name": "Add", "templates": ["Add piyo"]}]}`,
+			strategy:  MergeReplace,
+			want:      "# Add\nAdd piyo\n\n# Fix\nFix fuga\n",
+			wantAdded: 1,
+			wantErr:   false,
+		},
+		{
+			name:      "NormalReplaceCountsNewTemplates",
+			pack:      `{"name": "team", "categories": [{"name": "Add", "templates": ["Add hoge", "Add piyo"]}]}`,
+			strategy:  MergeReplace,
+			want:      "# Add\nAdd hoge\nAdd piyo\n\n# Fix\nFix fuga\n",
+			wantAdded: 1,
+			wantErr:   false,
+		},
+		{
+			name:      "NormalEscapeNewlines",
+			pack:      `{"name": "team", "categories": [{"name": "Fix", "templates": ["Fix piyo\n\nbody\n", "  "]}]}`,
+			strategy:  MergeAppend,
+			want:      "# Add\nAdd hoge\n\n# Fix\nFix fuga\nFix piyo\\n\\nbody\n",
+			wantAdded: 1,
+			wantErr:   false,
+		},
+		{
+			name:      "ErrorBecauseTemplateStartsWithHash",
+			pack:      `{"name": "team", "categories": [{"name": "Fix", "templates": ["#include ~/.ssh/id_rsa"]}]}`,
+			strategy:  MergeAppend,
+			want:      templateFixture,
+			wantAdded: 0,
+			wantErr:   true,
+		},
+		{
+			name:      "ErrorBecauseCategorySpansLines",
+			pack:      `{"name": "team", "categories": [{"name": "Fix\n#include ~/.ssh/id_rsa", "templates": ["Fix piyo"]}]}`,
+			strategy:  MergeAppend,
+			want:      templateFixture,
+			wantAdded: 0,
+			wantErr:   true,
+		},
+		{
+			name:      "ErrorBecauseInvalidJSON",
+			pack:      "hoge",
+			strategy:  MergeAppend,
+			want:      templateFixture,
+			wantAdded: 0,
+			wantErr:   true,
+		},
+		{
+			name:      "ErrorBecauseUnknownStrategy",
+			pack:      pack,
+			strategy:  "hoge",
+			want:      templateFixture,
+			wantAdded: 0,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := writeTemplateFixture(t)
+			got, added, err := c.ImportPack(strings.NewReader(tt.pack), tt.strategy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ImportPack() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Name != "team" {
+				t.Errorf("ImportPack() pack = %+v", got)
+			}
+			if added != tt.wantAdded {
+				t.Errorf("ImportPack() added = %v, want %v", added, tt.wantAdded)
+			}
+			content, err := ioutil.ReadFile(c.exampleFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(string(content), tt.want) {
+				t.Errorf("ImportPack() template = %q, want %q", content, tt.want)
+			}
+		})
+	}
+}
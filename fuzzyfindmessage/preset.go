@@ -0,0 +1,85 @@
+package fuzzyfindmessage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Preset is a set of templates the .fcm of a repository can start with.
+type Preset struct {
+	Name        string
+	Description string
+	// Templates are the lines of the template file, category headers
+	// included.
+	Templates []string
+}
+
+var presets = []Preset{
+	{
+		Name:        "default",
+		Description: "the templates of a new ~/.fcm, English messages in Japanese categories",
+		Templates:   defaultExamples[:],
+	},
+	{
+		Name:        "conventional",
+		Description: "Conventional Commits, a type and an optional scope before the summary",
+		Templates:   conventionalCommitsTemplates,
+	},
+	{
+		Name:        "gitmoji",
+		Description: "gitmoji, an emoji telling the intention before the summary",
+		Templates:   gitmojiTemplates(),
+	},
+}
+
+var conventionalCommitsTemplates = []string{
+	"# feat: a new feature",
+	"feat: {summary}",
+	"feat({scope}): {summary}",
+	"feat!: {summary}\\n\\nBREAKING CHANGE: {description}",
+	"# fix: a bug fix",
+	"fix: {summary}",
+	"fix({scope}): {summary}",
+	"# docs: documentation only",
+	"docs: {summary}",
+	"docs: update README.md",
+	"# style: formatting, no change of the meaning",
+	"style: {summary}",
+	"# refactor: neither a fix nor a feature",
+	"refactor: {summary}",
+	"refactor({scope}): {summary}",
+	"# perf: a performance improvement",
+	"perf: {summary}",
+	"# test: adding or correcting tests",
+	"test: {summary}",
+	"# build: the build system and the dependencies",
+	"build: {summary}",
+	"build(deps): bump {dependency} from {from} to {to}",
+	"# ci: the CI configuration",
+	"ci: {summary}",
+	"# chore: anything else",
+	"chore: {summary}",
+	"# revert: a reverted commit",
+	"revert: {summary}\\n\\nThis reverts commit {commit}.",
+}
+
+// Presets returns the presets of fcm init.
+func Presets() []Preset {
+	all := make([]Preset, len(presets))
+	for i, p := range presets {
+		p.Templates = append([]string(nil), p.Templates...)
+		all[i] = p
+	}
+	return all
+}
+
+func findPreset(name string) (Preset, error) {
+	names := make([]string, len(presets))
+	for i, p := range presets {
+		if p.Name == name {
+			return p, nil
+		}
+		names[i] = p.Name
+	}
+	return Preset{}, fmt.Errorf("unknown preset %q, expected one of %s", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,286 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+)
+
+// testRepo is a throwaway git repository used by tests that need real git
+// behaviour instead of the mocked command seams.
+type testRepo struct {
+	t   *testing.T
+	dir string
+	// c keeps its files in the .git directory of the repository.
+	c *Client
+}
+
+// newTestRepo creates an empty repository and makes it the working directory.
+// Everything is undone when the test finishes.
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "fcm-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	editor, hasEditor := os.LookupEnv("GIT_EDITOR")
+	// `git commit -e` must not wait for a human.
+	os.Setenv("GIT_EDITOR", "true")
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+		if hasEditor {
+			os.Setenv("GIT_EDITOR", editor)
+		} else {
+			os.Unsetenv("GIT_EDITOR")
+		}
+	})
+
+	c := New(
+		WithTemplateFile(filepath.Join(dir, ".git", exampleFile)),
+		WithHistoryFile(filepath.Join(dir, ".git", historyFile)),
+		WithConfigFile(filepath.Join(dir, ".git", configFile)),
+	)
+	r := &testRepo{t: t, dir: dir, c: c}
+	r.git("init", "-q")
+	r.git("config", "user.name", "fcm")
+	r.git("config", "user.email", "fcm@example.com")
+	r.git("config", "commit.gpgsign", "false")
+	return r
+}
+
+// newTempClient returns a Client whose history, template and config files
+// are in a fresh temporary directory, which is returned as well. Templates
+// from the user's git config, environment and repository are ignored.
+func newTempClient(t *testing.T) (*Client, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "fcm-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	c := New(
+		WithHistoryFile(filepath.Join(dir, historyFile)),
+		WithTemplateFile(filepath.Join(dir, exampleFile)),
+		WithConfigFile(filepath.Join(dir, configFile)),
+	)
+	// Keep the fcm.template of the git config of the user out of the tests.
+	c.git = &fakeGit{Git: c.git, config: func(ctx context.Context, key string) ([]string, error) {
+		return nil, nil
+	}}
+	c.osGetenv = func(key string) string {
+		return ""
+	}
+	// Keep the .fcm and .fcm_config of the repository of fcm out as well.
+	c.osGetwd = func() (string, error) {
+		return dir, nil
+	}
+	return c, dir
+}
+
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// goGit returns the go-git Git of the repository, which ignores the git
+// config outside of it.
+func (r *testRepo) goGit() Git {
+	return goGit{
+		open: func(dir string) (*git.Repository, error) {
+			return git.PlainOpen(r.dir)
+		},
+		loadConfig: func(scope config.Scope) (*config.Config, error) {
+			return config.NewConfig(), nil
+		},
+		exec: execGit{c: r.c},
+	}
+}
+
+func (r *testRepo) stage(name, content string) {
+	r.t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(r.dir, name), []byte(content), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+	r.git("add", name)
+}
+
+func (r *testRepo) history() string {
+	r.t.Helper()
+	b, err := ioutil.ReadFile(r.c.historyFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		r.t.Fatal(err)
+	}
+	return string(b)
+}
+
+func selectMessage(c *Client, message string) {
+	c.samples = func(ctx context.Context) ([]Candidate, error) {
+		return []Candidate{{Message: message}}, nil
+	}
+	c.finder = &mockFinder{}
+}
+
+func TestCommit_repository(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(r *testRepo)
+		message     string
+		wantErr     bool
+		wantErrIs   error
+		wantHistory bool
+	}{
+		{
+			name: "NormalFirstCommit",
+			setup: func(r *testRepo) {
+				r.stage("hoge.txt", "hoge")
+			},
+			message:     "Add hoge",
+			wantErr:     false,
+			wantHistory: true,
+		},
+		{
+			name: "NormalMultiLineMessage",
+			setup: func(r *testRepo) {
+				r.stage("hoge.txt", "hoge")
+			},
+			message:     "Add hoge\\n\\nWith a body",
+			wantErr:     false,
+			wantHistory: true,
+		},
+		{
+			name: "ErrorBecauseNothingStaged",
+			setup: func(r *testRepo) {
+				r.stage("hoge.txt", "hoge")
+				r.git("commit", "-q", "-m", "Previous message")
+			},
+			message:     "Add fuga",
+			wantErr:     true,
+			wantErrIs:   ErrNothingStaged,
+			wantHistory: false,
+		},
+		{
+			name: "NormalMergeWithTheTreeOfHead",
+			setup: func(r *testRepo) {
+				r.stage("hoge.txt", "hoge")
+				r.git("commit", "-q", "-m", "Previous message")
+				r.git("checkout", "-q", "-b", "topic")
+				r.stage("fuga.txt", "fuga")
+				r.git("commit", "-q", "-m", "Add fuga")
+				r.git("checkout", "-q", "-")
+				r.git("merge", "-q", "-s", "ours", "--no-commit", "topic")
+			},
+			message:     "Merge topic",
+			wantErr:     false,
+			wantHistory: true,
+		},
+		{
+			name: "ErrorBecauseMessageEmptiedInEditor",
+			setup: func(r *testRepo) {
+				r.stage("hoge.txt", "hoge")
+				os.Setenv("GIT_EDITOR", ": >")
+			},
+			message:     "Add hoge",
+			wantErr:     true,
+			wantErrIs:   ErrAborted,
+			wantHistory: false,
+		},
+		{
+			name: "NormalHookSwallowsCommit",
+			setup: func(r *testRepo) {
+				r.stage("hoge.txt", "hoge")
+				r.git("commit", "-q", "-m", "Previous message")
+				r.stage("fuga.txt", "fuga")
+				r.c.git = &fakeGit{Git: r.c.git, commit: func(ctx context.Context, fileName string, edit bool) error {
+					return nil
+				}}
+			},
+			message:     "Add fuga",
+			wantErr:     false,
+			wantHistory: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRepo(t)
+			tt.setup(r)
+			selectMessage(r.c, tt.message)
+			err := r.c.Commit()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Commit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErrIs != nil && err != tt.wantErrIs {
+				t.Errorf("Commit() error = %v, want %v", err, tt.wantErrIs)
+			}
+			got := r.history()
+			if strings.Contains(got, "Previous message") {
+				t.Errorf("history recorded a stale message: %q", got)
+			}
+			if strings.Contains(got, tt.message+"\n") != tt.wantHistory {
+				t.Errorf("history = %q, wantHistory %v", got, tt.wantHistory)
+			}
+		})
+	}
+}
+
+func TestCommit_notARepository(t *testing.T) {
+	r := newTestRepo(t)
+	selectMessage(r.c, "Add hoge")
+	if err := os.Chdir(os.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	// Keep git from finding a repository above the temporary directory.
+	os.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(os.TempDir()))
+	defer os.Unsetenv("GIT_CEILING_DIRECTORIES")
+
+	if err := r.c.Commit(); err != ErrNotARepo {
+		t.Errorf("Commit() error = %v, want %v", err, ErrNotARepo)
+	}
+}
+
+func TestCommitContext_repository(t *testing.T) {
+	r := newTestRepo(t)
+	r.stage("hoge.txt", "hoge")
+	selectMessage(r.c, "Add hoge")
+	// The editor outlives the context, so git has to be killed.
+	os.Setenv("GIT_EDITOR", "sleep 5; true")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := r.c.CommitContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("CommitContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("CommitContext() returned after %s", elapsed)
+	}
+	if got := r.history(); len(got) != 0 {
+		t.Errorf("history = %q, want none", got)
+	}
+}
@@ -0,0 +1,388 @@
+package fuzzyfindmessage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	templatesEnv      = "FCM_TEMPLATES"
+	templateConfigKey = "fcm.template"
+)
+
+var (
+	sourcesMu         sync.Mutex
+	registeredSources []Source
+)
+
+// Candidate is a message offered in the fuzzy finder.
+type Candidate struct {
+	// Message is the template, on a single line with newlines escaped as "\n".
+	Message string
+	// Origin tells where the candidate came from, e.g. a file name. It is
+	// shown in the preview window.
+	Origin string
+}
+
+// Source provides candidates for the fuzzy finder.
+type Source interface {
+	Candidates() ([]Candidate, error)
+}
+
+// ContextSource is a Source whose candidates can be canceled. CommitContext
+// calls CandidatesContext instead of Candidates on such sources.
+type ContextSource interface {
+	Source
+	CandidatesContext(ctx context.Context) ([]Candidate, error)
+}
+
+// SourceFunc adapts an ordinary function to Source.
+type SourceFunc func() ([]Candidate, error)
+
+// Candidates calls f.
+func (f SourceFunc) Candidates() ([]Candidate, error) {
+	return f()
+}
+
+// FileSource offers the templates of a template file. Include directives in
+// the file are followed.
+type FileSource struct {
+	Path string
+}
+
+// Candidates reads the file with the default Client.
+func (s FileSource) Candidates() ([]Candidate, error) {
+	return std().candidates(context.Background(), s)
+}
+
+func (c *Client) fileCandidates(s FileSource) ([]Candidate, error) {
+	path := s.Path
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return c.readTemplateFile(path, nil)
+}
+
+// HistorySource offers the messages recorded in ~/.fcm_history.
+type HistorySource struct{}
+
+// Candidates reads the history with the default Client.
+func (s HistorySource) Candidates() ([]Candidate, error) {
+	return std().candidates(context.Background(), s)
+}
+
+// GitLogSource offers the commit messages of a repository.
+type GitLogSource struct {
+	// Dir is the repository. The empty string means the current directory.
+	Dir     string
+	Options GitLogOptions
+}
+
+// Candidates runs git log with the default Client.
+func (s GitLogSource) Candidates() ([]Candidate, error) {
+	return s.CandidatesContext(context.Background())
+}
+
+// CandidatesContext runs git log with the default Client. Canceling ctx
+// kills git.
+func (s GitLogSource) CandidatesContext(ctx context.Context) ([]Candidate, error) {
+	return std().candidates(ctx, s)
+}
+
+func (c *Client) gitLogCandidates(ctx context.Context, s GitLogSource) ([]Candidate, error) {
+	dir := s.Dir
+	if len(dir) == 0 {
+		dir = "."
+	}
+	entries, err := c.harvestGitLog(ctx, dir, s.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]Candidate, 0, len(entries))
+	for _, e := range entries {
+		candidates = append(candidates, Candidate{Message: e.Message, Origin: "git log of " + e.Repo})
+	}
+	return candidates, nil
+}
+
+// CommandSource offers every non-empty line the command prints on stdout.
+// A line holding a JSON object such as {"message": "...", "origin": "..."}
+// is read as a candidate with its own origin.
+type CommandSource struct {
+	Name string
+	Args []string
+	// Dir is the working directory of the command. The empty string means
+	// the current directory.
+	Dir string
+	// Timeout kills the command if it runs longer. 0 means no limit.
+	Timeout time.Duration
+}
+
+// Candidates runs the command with the default Client.
+func (s CommandSource) Candidates() ([]Candidate, error) {
+	return s.CandidatesContext(context.Background())
+}
+
+// CandidatesContext runs the command with the default Client. Canceling ctx
+// kills the command.
+func (s CommandSource) CandidatesContext(ctx context.Context) ([]Candidate, error) {
+	return std().candidates(ctx, s)
+}
+
+func (c *Client) commandCandidates(parent context.Context, s CommandSource) ([]Candidate, error) {
+	ctx := parent
+	if s.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, s.Timeout)
+		defer cancel()
+	}
+
+	cmd := c.execCommandContext(ctx, s.Name, s.Args...)
+	cmd.Dir = s.Dir
+
+	// Killing the command leaves its children, which may hold stdout open
+	// and keep Output from returning, so stop waiting when ctx is done.
+	type result struct {
+		out []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := c.commandOutput(cmd)
+		done <- result{out: out, err: err}
+	}()
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+	}
+
+	origin := s.commandLine()
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s: timed out after %s", origin, s.Timeout)
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%s: %s", origin, r.err)
+	}
+	out := r.out
+
+	var candidates []Candidate
+	for _, l := range strings.Split(string(out), "\n") {
+		l = strings.TrimSpace(l)
+		if len(l) == 0 {
+			continue
+		}
+
+		candidate := Candidate{Message: l, Origin: origin}
+		if strings.HasPrefix(l, "{") {
+			var j struct {
+				Message string `json:"message"`
+				Origin  string `json:"origin"`
+			}
+			if err := json.Unmarshal([]byte(l), &j); err != nil {
+				return nil, fmt.Errorf("%s: %s", origin, err)
+			}
+			candidate.Message = escapeMessage(j.Message)
+			if len(j.Origin) != 0 {
+				candidate.Origin = j.Origin
+			}
+		}
+		if len(candidate.Message) != 0 {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates, nil
+}
+
+// commandLine returns the command and its arguments separated by spaces.
+func (s CommandSource) commandLine() string {
+	return strings.Join(append([]string{s.Name}, s.Args...), " ")
+}
+
+// candidates runs the built-in sources with the files, commands and streams
+// of c. Other sources are left to themselves.
+func (c *Client) candidates(ctx context.Context, s Source) ([]Candidate, error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	switch s := s.(type) {
+	case FileSource:
+		return c.fileCandidates(s)
+	case HistorySource:
+		return c.historyTemplates()
+	case GitLogSource:
+		return c.gitLogCandidates(ctx, s)
+	case CommandSource:
+		return c.commandCandidates(ctx, s)
+	case ContextSource:
+		return s.CandidatesContext(ctx)
+	}
+	return s.Candidates()
+}
+
+// parseSource reads a "source" entry of the config file:
+//
+//	exec: <command line>   lines printed by a shell command
+//	file: <path>           a template file
+//
+// Commands run in the top level directory of the repository.
+func (c *Client) parseSource(ctx context.Context, spec string, timeout time.Duration) (Source, error) {
+	kv := strings.SplitN(spec, ":", 2)
+	if len(kv) != 2 || len(strings.TrimSpace(kv[1])) == 0 {
+		return nil, fmt.Errorf("invalid source %q", spec)
+	}
+	value := strings.TrimSpace(kv[1])
+
+	switch strings.TrimSpace(kv[0]) {
+	case "exec":
+		dir, err := c.git.TopLevel(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return CommandSource{Name: "sh", Args: []string{"-c", value}, Dir: dir, Timeout: timeout}, nil
+	case "file":
+		return FileSource{Path: c.resolvePath(value, ".")}, nil
+	}
+	return nil, fmt.Errorf("invalid source %q: unknown type %q", spec, kv[0])
+}
+
+// RegisterSource adds a source that is consulted by every Client after the
+// built-in ones: ~/.fcm, the .fcm of the repository, $FCM_TEMPLATES, git
+// config fcm.template, the history and the sources of the config files. Use
+// WithSources for a single Client.
+func RegisterSource(s Source) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	registeredSources = append(registeredSources, s)
+}
+
+func (c *Client) _sources(ctx context.Context) ([]Source, error) {
+	cfg, err := c.loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	all := []Source{
+		FileSource{Path: c.exampleFilePath},
+		SourceFunc(c.envTemplates),
+		SourceFunc(func() ([]Candidate, error) {
+			return c.configTemplates(ctx)
+		}),
+		HistorySource{},
+	}
+	if path := c.repoFile(exampleFile); len(path) != 0 && c.exists(path) {
+		all = append(all[:1], append([]Source{FileSource{Path: path}}, all[1:]...)...)
+	}
+	for _, spec := range cfg.Sources {
+		s, err := c.parseSource(ctx, spec, cfg.SourceTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", c.configFilePath, err)
+		}
+		all = append(all, s)
+	}
+
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	all = append(all, registeredSources...)
+	return append(all, c.extraSources...), nil
+}
+
+// preview is shown next to the candidate list in the fuzzy finder.
+func (c Candidate) preview() string {
+	if len(c.Origin) == 0 {
+		return fmt.Sprintln(unescapeMessage(c.Message))
+	}
+	return fmt.Sprintf("%s\n\n(from %s)\n", unescapeMessage(c.Message), c.Origin)
+}
+
+// _envTemplates reads the template files and directories listed in
+// $FCM_TEMPLATES, separated like $PATH.
+func (c *Client) _envTemplates() ([]Candidate, error) {
+	var templates []Candidate
+	for _, path := range filepath.SplitList(c.osGetenv(templatesEnv)) {
+		if len(path) == 0 {
+			continue
+		}
+		path = c.resolvePath(path, ".")
+
+		directive := includeDirective
+		if info, err := c.osStat(path); err == nil && info.IsDir() {
+			directive = includeDirDirective
+		}
+		t, err := c.includeTemplates(directive, path, templatesEnv, nil)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, t...)
+	}
+	return templates, nil
+}
+
+// _configTemplates returns the fcm.template values of the git config, which
+// lets repositories and includeIf'd config files carry their own templates.
+// A value may span lines, so it is escaped as the history entries are.
+func (c *Client) _configTemplates(ctx context.Context) ([]Candidate, error) {
+	values, err := c.git.Config(ctx, templateConfigKey)
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]Candidate, 0, len(values))
+	for _, v := range values {
+		if v = escapeMessage(v); v == "" {
+			continue
+		}
+		templates = append(templates, Candidate{Message: v, Origin: "git config " + templateConfigKey})
+	}
+	return templates, nil
+}
+
+func (c *Client) _historyTemplates() ([]Candidate, error) {
+	entries, err := c.History()
+	if err != nil {
+		return nil, err
+	}
+
+	origin := c.displayPath(c.historyFilePath)
+	templates := make([]Candidate, 0, len(entries))
+	for _, e := range entries {
+		candidate := Candidate{Message: e.Message, Origin: origin}
+		if len(e.Repo) != 0 {
+			candidate.Origin = fmt.Sprintf("%s, imported from %s", origin, e.Repo)
+		}
+		templates = append(templates, candidate)
+	}
+	return templates, nil
+}
+
+// fromHistory tells whether cand was read from the history file.
+func (c *Client) fromHistory(cand Candidate) bool {
+	origin := c.displayPath(c.historyFilePath)
+	return cand.Origin == origin || strings.HasPrefix(cand.Origin, origin+", imported from ")
+}
+
+// removeDuplicateCandidates keeps the first Candidate of every message.
+func removeDuplicateCandidates(candidates []Candidate) []Candidate {
+	results := make([]Candidate, 0, len(candidates))
+	m := map[string]bool{}
+	for _, c := range candidates {
+		if !m[c.Message] {
+			m[c.Message] = true
+			results = append(results, c)
+		}
+	}
+	return results
+}
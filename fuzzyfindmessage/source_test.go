@@ -0,0 +1,460 @@
+package fuzzyfindmessage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_candidatePreview(t *testing.T) {
+	tests := []struct {
+		name      string
+		Candidate Candidate
+		want      string
+	}{
+		{
+			name:      "Normal",
+			Candidate: Candidate{Message: "Fix hoge\\n\\nbody", Origin: "~/.fcm"},
+			want:      "Fix hoge\n\nbody\n\n(from ~/.fcm)\n",
+		},
+		{
+			name:      "NormalWithoutOrigin",
+			Candidate: Candidate{Message: "Fix hoge"},
+			want:      "Fix hoge\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.Candidate.preview(); got != tt.want {
+				t.Errorf("preview() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test__envTemplates(t *testing.T) {
+	c, dir := newTempClient(t)
+	files := map[string]string{
+		"team.fcm":       "# Team\nTeam\n",
+		"templates/a":    "Directory A\n",
+		"templates/b":    "Directory B\n",
+		"unused/unused":  "Unused\n",
+		"templates/.bak": "Hidden\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.osGetenv = func(key string) string {
+		if key != templatesEnv {
+			return ""
+		}
+		return filepath.Join(dir, "team.fcm") + string(os.PathListSeparator) + string(os.PathListSeparator) + filepath.Join(dir, "templates")
+	}
+
+	got, err := c._envTemplates()
+	if err != nil {
+		t.Fatalf("envTemplates() error = %v", err)
+	}
+	want := []Candidate{
+		{Message: "Team", Origin: filepath.Join(dir, "team.fcm")},
+		{Message: "Directory A", Origin: filepath.Join(dir, "templates", "a")},
+		{Message: "Directory B", Origin: filepath.Join(dir, "templates", "b")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("envTemplates() got = %v, want %v", got, want)
+	}
+}
+
+func Test__configTemplates(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name         string
+		gitConfigAll func(ctx context.Context, key string) ([]string, error)
+		want         []Candidate
+		wantErr      bool
+	}{
+		{
+			name: "Normal",
+			gitConfigAll: func(ctx context.Context, key string) ([]string, error) {
+				return []string{"hoge", "fuga"}, nil
+			},
+			want: []Candidate{
+				{Message: "hoge", Origin: "git config fcm.template"},
+				{Message: "fuga", Origin: "git config fcm.template"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "NormalMultiLineValue",
+			gitConfigAll: func(ctx context.Context, key string) ([]string, error) {
+				return []string{"Add hoge\n\nBody\n", "  \n"}, nil
+			},
+			want: []Candidate{
+				{Message: "Add hoge\\n\\nBody", Origin: "git config fcm.template"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseGitConfigAllReturnError",
+			gitConfigAll: func(ctx context.Context, key string) ([]string, error) {
+				return nil, fmt.Errorf("error")
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.git = &fakeGit{config: tt.gitConfigAll}
+			got, err := c._configTemplates(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("configTemplates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("configTemplates() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test__configTemplatesRepository(t *testing.T) {
+	r := newTestRepo(t)
+	c := r.c
+	r.git("config", "--add", templateConfigKey, "Fix {ticket}")
+	r.git("config", "--add", templateConfigKey, "Add {file}")
+
+	got, err := c._configTemplates(context.Background())
+	if err != nil {
+		t.Fatalf("configTemplates() error = %v", err)
+	}
+	if len(got) < 2 || got[len(got)-2].Message != "Fix {ticket}" || got[len(got)-1].Message != "Add {file}" {
+		t.Errorf("configTemplates() got = %v", got)
+	}
+}
+
+func Test__historyTemplates(t *testing.T) {
+	c, _ := newTempClient(t)
+	c.home = filepath.Dir(c.historyFilePath)
+	content := "# 2020/01/01 12:00:00\nhoge\n# 2020/01/02 12:00:00 project\nfuga\n"
+	if err := ioutil.WriteFile(c.historyFilePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c._historyTemplates()
+	if err != nil {
+		t.Fatalf("historyTemplates() error = %v", err)
+	}
+	want := []Candidate{
+		{Message: "hoge", Origin: "~/.fcm_history"},
+		{Message: "fuga", Origin: "~/.fcm_history, imported from project"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("historyTemplates() got = %v, want %v", got, want)
+	}
+}
+
+func Test_removeDuplicateCandidates(t *testing.T) {
+	got := removeDuplicateCandidates([]Candidate{
+		{Message: "hoge", Origin: "a"},
+		{Message: "hoge", Origin: "b"},
+	})
+	if want := []Candidate{{Message: "hoge", Origin: "a"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removeDuplicateCandidates() = %v, want %v", got, want)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	c, dir := newTempClient(t)
+	path := filepath.Join(dir, "export.txt")
+	if err := ioutil.WriteFile(path, []byte("# Tickets\nPROJ-1 Fix login\r\n\nPROJ-2 Add logout\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.candidates(context.Background(), FileSource{Path: path})
+	if err != nil {
+		t.Fatalf("Candidates() error = %v", err)
+	}
+	want := []Candidate{
+		{Message: "PROJ-1 Fix login", Origin: path},
+		{Message: "PROJ-2 Add logout", Origin: path},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Candidates() got = %v, want %v", got, want)
+	}
+}
+
+func TestGitLogSource(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("Add hoge", "fcm <fcm@example.com>")
+
+	got, err := r.c.candidates(context.Background(), GitLogSource{})
+	if err != nil {
+		t.Fatalf("Candidates() error = %v", err)
+	}
+	want := []Candidate{{Message: "Add hoge", Origin: "git log of " + filepath.Base(r.dir)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Candidates() got = %v, want %v", got, want)
+	}
+}
+
+func TestCommandSource(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name          string
+		commandOutput func(cmd *exec.Cmd) ([]byte, error)
+		want          []Candidate
+		wantErr       bool
+	}{
+		{
+			name: "NormalJSONLines",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte(`{"message": "Fix PROJ-1\n\nbody", "origin": "sprint"}` + "\n" + `{"message": "Add PROJ-2"}` + "\nhoge\n"), nil
+			},
+			want: []Candidate{
+				{Message: "Fix PROJ-1\\n\\nbody", Origin: "sprint"},
+				{Message: "Add PROJ-2", Origin: "suggest --all"},
+				{Message: "hoge", Origin: "suggest --all"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseInvalidJSON",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("{hoge\n"), nil
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "Normal",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return []byte("hoge\n\nfuga\n"), nil
+			},
+			want: []Candidate{
+				{Message: "hoge", Origin: "suggest --all"},
+				{Message: "fuga", Origin: "suggest --all"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseCommandReturnError",
+			commandOutput: func(cmd *exec.Cmd) ([]byte, error) {
+				return nil, fmt.Errorf("error")
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.execCommandContext = func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+				return &exec.Cmd{}
+			}
+			c.commandOutput = tt.commandOutput
+			got, err := c.candidates(context.Background(), CommandSource{Name: "suggest", Args: []string{"--all"}})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Candidates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Candidates() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandSourceTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	_, err := CommandSource{Name: "sh", Args: []string{"-c", "exec sleep 5"}, Timeout: 50 * time.Millisecond}.Candidates()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Candidates() error = %v, want a timeout", err)
+	}
+}
+
+func TestCommandSourceTimeoutChildHoldsStdout(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	// sleep outlives the killed sh and keeps stdout open.
+	start := time.Now()
+	_, err := CommandSource{Name: "sh", Args: []string{"-c", "sleep 5; echo hoge"}, Timeout: 100 * time.Millisecond}.Candidates()
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Candidates() error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("Candidates() returned after %s, want right after the timeout", elapsed)
+	}
+}
+
+func TestCommandSourceCanceled(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := CommandSource{Name: "sh", Args: []string{"-c", "exec sleep 5"}, Timeout: time.Minute}.CandidatesContext(ctx)
+	if err != context.DeadlineExceeded {
+		t.Errorf("CandidatesContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+type contextSource struct {
+	ctx context.Context
+}
+
+func (s *contextSource) Candidates() ([]Candidate, error) {
+	return nil, fmt.Errorf("Candidates() must not be called")
+}
+
+func (s *contextSource) CandidatesContext(ctx context.Context) ([]Candidate, error) {
+	s.ctx = ctx
+	return []Candidate{{Message: "hoge"}}, nil
+}
+
+func Test_candidatesContextSource(t *testing.T) {
+	c := New()
+	s := &contextSource{}
+	ctx := context.WithValue(context.Background(), s, "hoge")
+
+	got, err := c.candidates(ctx, s)
+	if err != nil {
+		t.Fatalf("candidates() error = %v", err)
+	}
+	if want := []Candidate{{Message: "hoge"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("candidates() got = %v, want %v", got, want)
+	}
+	if s.ctx != ctx {
+		t.Errorf("candidates() did not pass the context")
+	}
+}
+
+func Test_parseSource(t *testing.T) {
+	c := New()
+	tests := []struct {
+		name        string
+		spec        string
+		gitTopLevel func(ctx context.Context) (string, error)
+		want        Source
+		wantErr     bool
+	}{
+		{
+			name: "NormalExec",
+			spec: "exec: ./scripts/suggest.sh --all",
+			gitTopLevel: func(ctx context.Context) (string, error) {
+				return "/repo", nil
+			},
+			want:    CommandSource{Name: "sh", Args: []string{"-c", "./scripts/suggest.sh --all"}, Dir: "/repo", Timeout: time.Second},
+			wantErr: false,
+		},
+		{
+			name:    "NormalFile",
+			spec:    "file:/tmp/tracker.txt",
+			want:    FileSource{Path: "/tmp/tracker.txt"},
+			wantErr: false,
+		},
+		{
+			name: "ErrorBecauseGitTopLevelReturnError",
+			spec: "exec: hoge",
+			gitTopLevel: func(ctx context.Context) (string, error) {
+				return "", fmt.Errorf("error")
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseUnknownType",
+			spec:    "http: example.com",
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name:    "ErrorBecauseEmpty",
+			spec:    "exec:",
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.git = &fakeGit{topLevel: tt.gitTopLevel}
+			got, err := c.parseSource(context.Background(), tt.spec, time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSource() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_samplesConfigSource(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+	c, _ := newTempClient(t)
+	content := "source = exec: echo Broken; exit 1\n" +
+		"source = exec: echo From script; echo '{\"message\": \"From JSON\"}'\n"
+	if err := ioutil.WriteFile(c.configFilePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	stderr := &bytes.Buffer{}
+	c.stderr = stderr
+
+	got, err := c._samples(context.Background())
+	if err != nil {
+		t.Fatalf("samples() error = %v", err)
+	}
+	found := 0
+	for _, candidate := range got {
+		if candidate.Message == "From script" || candidate.Message == "From JSON" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("samples() did not include the exec source: %v", got)
+	}
+	if want := "Skipped a source: sh -c echo Broken; exit 1: exit status 1\n"; stderr.String() != want {
+		t.Errorf("samples() stderr = %q, want %q", stderr.String(), want)
+	}
+}
+
+func TestRegisterSource(t *testing.T) {
+	c, _ := newTempClient(t)
+	defer func() {
+		registeredSources = nil
+	}()
+
+	RegisterSource(SourceFunc(func() ([]Candidate, error) {
+		return []Candidate{{Message: "From tracker", Origin: "tracker"}}, nil
+	}))
+
+	got, err := c._samples(context.Background())
+	if err != nil {
+		t.Fatalf("samples() error = %v", err)
+	}
+	for _, candidate := range got {
+		if candidate.Message == "From tracker" && candidate.Origin == "tracker" {
+			return
+		}
+	}
+	t.Errorf("samples() did not include the registered source: %v", got)
+}
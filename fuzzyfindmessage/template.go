@@ -0,0 +1,229 @@
+package fuzzyfindmessage
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	ticketPattern = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-[0-9]+\b|#[0-9]+\b`)
+	filePattern   = regexp.MustCompile(`\b(?:[\w-]+/)*[\w-]+\.[A-Za-z][A-Za-z0-9]{0,4}\b`)
+
+	// sourceExtensions tell file names from words such as "e.g." and
+	// "os.Open" when there is no directory in front of them.
+	sourceExtensions = map[string]bool{
+		"c": true, "cc": true, "conf": true, "cpp": true, "cs": true, "css": true,
+		"go": true, "h": true, "hpp": true, "html": true, "ini": true, "java": true,
+		"js": true, "json": true, "jsx": true, "kt": true, "lock": true, "md": true,
+		"mod": true, "php": true, "proto": true, "py": true, "rb": true, "rs": true,
+		"scss": true, "sh": true, "sql": true, "sum": true, "swift": true, "toml": true,
+		"ts": true, "tsx": true, "txt": true, "vue": true, "xml": true, "yaml": true,
+		"yml": true,
+	}
+)
+
+// Categories calls Client.Categories with the default Client.
+func Categories() ([]string, error) {
+	return std().Categories()
+}
+
+// Categories returns the category headers ("# ..." lines) of ~/.fcm in order.
+func (c *Client) Categories() ([]string, error) {
+	if err := c.resolvePaths(); err != nil {
+		return nil, err
+	}
+	lines, err := c.readTemplateLines()
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []string
+	for _, l := range lines {
+		if name, ok := categoryName(l); ok {
+			categories = append(categories, name)
+		}
+	}
+	return c.removeDuplicate(categories), nil
+}
+
+// SelectCategory calls Client.SelectCategory with the default Client.
+func SelectCategory() (string, error) {
+	return std().SelectCategory()
+}
+
+// SelectCategory lets the user pick a category of ~/.fcm with the fuzzy finder.
+func (c *Client) SelectCategory() (string, error) {
+	categories, err := c.Categories()
+	if err != nil {
+		return "", err
+	}
+	if len(categories) == 0 {
+		return "", nil
+	}
+
+	id, err := c.finder.Find(context.Background(), categories, nil)
+	if err != nil {
+		return "", err
+	}
+	return categories[id], nil
+}
+
+// PromoteMessage calls Client.PromoteMessage with the default Client.
+func PromoteMessage(message, category string, generalize bool) (string, error) {
+	return std().PromoteMessage(message, category, generalize)
+}
+
+// PromoteMessage copies a message, typically taken from the history, into
+// ~/.fcm at the end of category. The category header is appended when it
+// does not exist yet, and an empty category adds the message to the
+// templates above the first header. With generalize, ticket IDs and file
+// names are replaced with the {ticket} and {file} placeholders first. The
+// promoted line is returned.
+func (c *Client) PromoteMessage(message, category string, generalize bool) (string, error) {
+	if err := c.resolvePaths(); err != nil {
+		return "", err
+	}
+	if generalize {
+		message = generalizeMessage(message)
+	}
+
+	if err := c.createDefaultFile(c.exampleFilePath); err != nil {
+		return "", err
+	}
+	lines, err := c.readTemplateLines()
+	if err != nil {
+		return "", err
+	}
+
+	lines = insertIntoCategory(lines, message, category)
+	err = c.writeFileAtomic(c.exampleFilePath, func(w io.Writer) error {
+		return writeLines(w, lines)
+	})
+	if err != nil {
+		return "", err
+	}
+	return message, nil
+}
+
+func (c *Client) readTemplateLines() ([]string, error) {
+	b, err := c.ioutilReadFile(c.exampleFilePath)
+	if err != nil {
+		return nil, err
+	}
+	s := strings.TrimRight(string(b), "\n")
+	if len(s) == 0 {
+		return nil, nil
+	}
+	return strings.Split(s, "\n"), nil
+}
+
+func categoryName(line string) (string, bool) {
+	if len(line) == 0 || line[0:1] != "#" || isDirective(line) {
+		return "", false
+	}
+	return strings.TrimSpace(line[1:]), true
+}
+
+// insertIntoCategory adds message at the end of category unless the
+// category holds it already.
+func insertIntoCategory(lines []string, message, category string) []string {
+	if start, end, ok := categoryRange(lines, category); ok {
+		for _, l := range lines[start:end] {
+			if l == message {
+				return lines
+			}
+		}
+	}
+	return appendToCategory(lines, message, category)
+}
+
+func writeLines(w io.Writer, lines []string) error {
+	bw := bufio.NewWriter(w)
+	for _, l := range lines {
+		if _, err := fmt.Fprintln(bw, l); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// categoryRange returns the lines [start, end) holding the templates of
+// category, without the header and the blank lines before the next header or
+// include directive. The uncategorized templates at the top of the file have
+// the empty name.
+func categoryRange(lines []string, category string) (start, end int, ok bool) {
+	start = -1
+	if len(category) == 0 {
+		start = 0
+	} else {
+		for i, l := range lines {
+			if c, isHeader := categoryName(l); isHeader && c == category {
+				start = i + 1
+				break
+			}
+		}
+		if start == -1 {
+			return 0, 0, false
+		}
+	}
+
+	end = len(lines)
+	for i := start; i < len(lines); i++ {
+		if _, isHeader := categoryName(lines[i]); isHeader || isDirective(lines[i]) {
+			end = i
+			break
+		}
+	}
+	for end > start && len(strings.TrimSpace(lines[end-1])) == 0 {
+		end--
+	}
+	return start, end, true
+}
+
+// appendToCategory adds template at the end of category, unlike
+// insertIntoCategory even when the category holds it already.
+func appendToCategory(lines []string, template, category string) []string {
+	_, end, ok := categoryRange(lines, category)
+	if !ok {
+		return append(lines, "# "+category, template)
+	}
+
+	inserted := make([]string, 0, len(lines)+1)
+	inserted = append(inserted, lines[:end]...)
+	inserted = append(inserted, template)
+	return append(inserted, lines[end:]...)
+}
+
+func replaceCategory(lines []string, category string, templates []string) []string {
+	start, end, ok := categoryRange(lines, category)
+	if !ok {
+		return append(append(lines, "# "+category), templates...)
+	}
+
+	replaced := make([]string, 0, len(lines)-(end-start)+len(templates))
+	replaced = append(replaced, lines[:start]...)
+	replaced = append(replaced, templates...)
+	return append(replaced, lines[end:]...)
+}
+
+// generalizeMessage replaces ticket IDs (ABC-123, #123) and file names with
+// placeholders so that a concrete message can serve as a template. Each line
+// of the escaped message is generalized on its own.
+func generalizeMessage(message string) string {
+	lines := strings.Split(unescapeMessage(message), "\n")
+	for i, l := range lines {
+		l = ticketPattern.ReplaceAllString(l, "{ticket}")
+		lines[i] = filePattern.ReplaceAllStringFunc(l, func(name string) string {
+			ext := strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+			if strings.Contains(name, "/") || sourceExtensions[ext] {
+				return "{file}"
+			}
+			return name
+		})
+	}
+	return escapeMessage(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,169 @@
+package fuzzyfindmessage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+const templateFixture = "# Add\nAdd hoge\n\n# Fix\nFix fuga\n"
+
+func writeTemplateFixture(t *testing.T) *Client {
+	t.Helper()
+	c, _ := newTempClient(t)
+	if err := ioutil.WriteFile(c.exampleFilePath, []byte(templateFixture), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCategories(t *testing.T) {
+	c := writeTemplateFixture(t)
+	got, err := c.Categories()
+	if err != nil {
+		t.Fatalf("Categories() error = %v", err)
+	}
+	if want := []string{"Add", "Fix"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Categories() got = %v, want %v", got, want)
+	}
+}
+
+func TestSelectCategory(t *testing.T) {
+	tests := []struct {
+		name    string
+		finder  Finder
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Normal",
+			finder:  &mockFinder{id: 1},
+			want:    "Fix",
+			wantErr: false,
+		},
+		{
+			name:    "ErrorBecauseFinderReturnError",
+			finder:  &mockFinder{err: fmt.Errorf("error")},
+			want:    "",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := writeTemplateFixture(t)
+			c.finder = tt.finder
+			got, err := c.SelectCategory()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectCategory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SelectCategory() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromoteMessage(t *testing.T) {
+	tests := []struct {
+		name       string
+		message    string
+		category   string
+		generalize bool
+		want       string
+	}{
+		{
+			name:     "NormalExistingCategory",
+			message:  "Add fuga",
+			category: "Add",
+			want:     "# Add\nAdd hoge\nAdd fuga\n\n# Fix\nFix fuga\n",
+		},
+		{
+			name:     "NormalLastCategory",
+			message:  "Fix piyo",
+			category: "Fix",
+			want:     "# Add\nAdd hoge\n\n# Fix\nFix fuga\nFix piyo\n",
+		},
+		{
+			name:     "NormalNewCategory",
+			message:  "Remove piyo",
+			category: "Remove",
+			want:     templateFixture + "# Remove\nRemove piyo\n",
+		},
+		{
+			name:     "NormalNoCategory",
+			message:  "Remove piyo",
+			category: "",
+			want:     "Remove piyo\n" + templateFixture,
+		},
+		{
+			name:     "NormalAlreadyInCategory",
+			message:  "Add hoge",
+			category: "Add",
+			want:     templateFixture,
+		},
+		{
+			name:       "NormalGeneralize",
+			message:    "Fix PROJ-42 crash in cmd/fcm/main.go",
+			category:   "Fix",
+			generalize: true,
+			want:       "# Add\nAdd hoge\n\n# Fix\nFix fuga\nFix {ticket} crash in {file}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := writeTemplateFixture(t)
+			if _, err := c.PromoteMessage(tt.message, tt.category, tt.generalize); err != nil {
+				t.Fatalf("PromoteMessage() error = %v", err)
+			}
+			got, err := ioutil.ReadFile(c.exampleFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("PromoteMessage() template = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_generalizeMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{
+			name:    "NormalTicket",
+			message: "Fix ABC-123 and #45",
+			want:    "Fix {ticket} and {ticket}",
+		},
+		{
+			name:    "NormalFile",
+			message: "Update README.md and docs/api.yaml",
+			want:    "Update {file} and {file}",
+		},
+		{
+			name:    "NormalNothingToReplace",
+			message: "Add docs for app.getLocale()",
+			want:    "Add docs for app.getLocale()",
+		},
+		{
+			name:    "NormalMultiLine",
+			message: "Fix crash\\n\\nmain.go: check nil\\nPROJ-7",
+			want:    "Fix crash\\n\\n{file}: check nil\\n{ticket}",
+		},
+		{
+			name:    "NormalNotFileNames",
+			message: "Wrap os.Open errors, e.g. in config/loader",
+			want:    "Wrap os.Open errors, e.g. in config/loader",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generalizeMessage(tt.message); got != tt.want {
+				t.Errorf("generalizeMessage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
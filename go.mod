@@ -3,6 +3,11 @@ module github.com/wataboru/git-fuzzy-find-commit-message
 go 1.14
 
 require (
+	github.com/go-git/go-billy/v5 v5.3.1
+	github.com/go-git/go-git/v5 v5.4.2
 	github.com/ktr0731/go-fuzzyfinder v0.2.1
+	github.com/mattn/go-runewidth v0.0.9
+	github.com/nsf/termbox-go v0.0.0-20200418040025-38ba6e5628f1
+	github.com/sergi/go-diff v1.1.0
 	github.com/tcnksm/ghr v0.13.0 // indirect
 )